@@ -0,0 +1,260 @@
+package syslog
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+const (
+	batchMaxMessagesDefault = 1000
+	batchMaxBytesDefault    = 1 << 20
+	batchMaxAgeDefault      = time.Second
+	batchRetryDelayDefault  = 100 * time.Millisecond
+)
+
+// BatchSink receives the messages in batches, e.g. to insert them in a
+// database or to send them to a bulk API
+type BatchSink interface {
+	Flush(batch []Message) error
+}
+
+// The BatchSinkFunc type is an adapter to allow the use of ordinary functions
+// as batch sinks
+type BatchSinkFunc func(batch []Message) error
+
+// Flush calls f(batch)
+func (f BatchSinkFunc) Flush(batch []Message) error {
+	return f(batch)
+}
+
+// BatchStats are the counters of a BatchHandler
+type BatchStats struct {
+	// Batches flushed successfully
+	Batches uint64
+	// Messages flushed successfully
+	Messages uint64
+	// Failed flushes, retried or not
+	Failures uint64
+	// Flushes retried after a failure
+	Retries uint64
+	// Messages dropped, because their batch failed too many times or because
+	// the handler was closed
+	Dropped uint64
+}
+
+// BatchHandler accumulates the messages, and flushes them to a BatchSink when
+// the batch reaches a number of messages, a size in bytes, the sum of the
+// message lengths, or an age, 1000 messages, 1 MiB and 1 second by default.
+//
+// Up to a number of batches are flushed concurrently, 1 by default, Handle
+// blocking when they are all in progress. When there are several, the batches
+// can be flushed out of order.
+//
+// A failed flush is retried after a delay doubling with every retry, up to a
+// number of retries, 0 by default. The batch is dropped then, and reported to
+// the error handler.
+//
+// Close flushes the pending messages. It is called by Server.Wait when set to
+// close the handler, see Server.SetCloseHandlerOnStop
+type BatchHandler struct {
+	stats BatchStats // first for 64-bit alignment of atomic operations
+
+	sink        BatchSink
+	maxMessages int
+	maxBytes    int64
+	maxAge      time.Duration
+	maxRetries  int
+	retryDelay  time.Duration
+	onError     func(err error, batch []Message)
+	flushing    chan struct{}
+
+	mu         sync.Mutex
+	batch      []Message
+	batchBytes int64
+	generation uint64 // of the batch, not to flush a later one on age
+	timer      *time.Timer
+	closed     bool
+	wait       sync.WaitGroup // flushes in progress
+}
+
+// NewBatchHandler returns a new BatchHandler, flushing the messages to sink
+func NewBatchHandler(sink BatchSink) *BatchHandler {
+	return &BatchHandler{
+		sink:        sink,
+		maxMessages: batchMaxMessagesDefault,
+		maxBytes:    batchMaxBytesDefault,
+		maxAge:      batchMaxAgeDefault,
+		retryDelay:  batchRetryDelayDefault,
+		flushing:    make(chan struct{}, 1),
+	}
+}
+
+// Sets the number of messages flushing a batch, no limit if 0
+func (h *BatchHandler) SetMaxMessages(n int) {
+	h.maxMessages = n
+}
+
+// Sets the size in bytes flushing a batch, no limit if 0
+func (h *BatchHandler) SetMaxBytes(n int64) {
+	h.maxBytes = n
+}
+
+// Sets the age flushing a batch, counted from its first message, no limit if 0
+func (h *BatchHandler) SetMaxAge(d time.Duration) {
+	h.maxAge = d
+}
+
+// Sets the number of batches flushed concurrently, at least 1
+func (h *BatchHandler) SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	h.flushing = make(chan struct{}, n)
+}
+
+// Sets the number of retries of a failed flush before dropping the batch, and
+// the delay before the first retry
+func (h *BatchHandler) SetRetryPolicy(maxRetries int, delay time.Duration) {
+	h.maxRetries = maxRetries
+	h.retryDelay = delay
+}
+
+// Sets the function notified of the batches dropped, with the error of their
+// last flush
+func (h *BatchHandler) SetErrorHandler(onError func(err error, batch []Message)) {
+	h.onError = onError
+}
+
+// Stats returns the counters of the handler
+func (h *BatchHandler) Stats() BatchStats {
+	return BatchStats{
+		Batches:  atomic.LoadUint64(&h.stats.Batches),
+		Messages: atomic.LoadUint64(&h.stats.Messages),
+		Failures: atomic.LoadUint64(&h.stats.Failures),
+		Retries:  atomic.LoadUint64(&h.stats.Retries),
+		Dropped:  atomic.LoadUint64(&h.stats.Dropped),
+	}
+}
+
+func (h *BatchHandler) Handle(logParts format.LogParts, messageLength int64, err error) {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		atomic.AddUint64(&h.stats.Dropped, 1)
+		return
+	}
+
+	h.batch = append(h.batch, Message{LogParts: logParts, Length: messageLength, Err: err})
+	h.batchBytes += messageLength
+	if len(h.batch) == 1 && h.maxAge > 0 {
+		generation := h.generation
+		h.timer = time.AfterFunc(h.maxAge, func() { h.flushAged(generation) })
+	}
+
+	var batch []Message
+	if (h.maxMessages > 0 && len(h.batch) >= h.maxMessages) ||
+		(h.maxBytes > 0 && h.batchBytes >= h.maxBytes) {
+		batch = h.take()
+	}
+	h.mu.Unlock()
+
+	if batch != nil {
+		h.flush(batch)
+	}
+}
+
+// Flush flushes the pending messages, without waiting for the flush to end
+func (h *BatchHandler) Flush() {
+	h.mu.Lock()
+	batch := h.take()
+	h.mu.Unlock()
+
+	if batch != nil {
+		h.flush(batch)
+	}
+}
+
+func (h *BatchHandler) flushAged(generation uint64) {
+	h.mu.Lock()
+	var batch []Message
+	if generation == h.generation {
+		batch = h.take()
+	}
+	h.mu.Unlock()
+
+	if batch != nil {
+		h.flush(batch)
+	}
+}
+
+// Takes the current batch, if not empty, and registers its flush. Must be
+// called with mu held
+func (h *BatchHandler) take() []Message {
+	if len(h.batch) == 0 {
+		return nil
+	}
+	batch := h.batch
+	h.batch = nil
+	h.batchBytes = 0
+	h.generation++
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+	h.wait.Add(1)
+	return batch
+}
+
+// Flushes a batch taken, from a goroutine once a flush slot is available
+func (h *BatchHandler) flush(batch []Message) {
+	h.flushing <- struct{}{}
+	go func() {
+		defer func() {
+			<-h.flushing
+			h.wait.Done()
+		}()
+		h.flushWithRetries(batch)
+	}()
+}
+
+func (h *BatchHandler) flushWithRetries(batch []Message) {
+	delay := h.retryDelay
+	for retry := 0; ; retry++ {
+		err := h.sink.Flush(batch)
+		if err == nil {
+			atomic.AddUint64(&h.stats.Batches, 1)
+			atomic.AddUint64(&h.stats.Messages, uint64(len(batch)))
+			return
+		}
+		atomic.AddUint64(&h.stats.Failures, 1)
+
+		if retry >= h.maxRetries {
+			atomic.AddUint64(&h.stats.Dropped, uint64(len(batch)))
+			if h.onError != nil {
+				h.onError(err, batch)
+			}
+			return
+		}
+		atomic.AddUint64(&h.stats.Retries, 1)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// Close stops accepting messages, flushes the pending ones, and waits for the
+// flushes to end
+func (h *BatchHandler) Close() error {
+	h.mu.Lock()
+	h.closed = true
+	batch := h.take()
+	h.mu.Unlock()
+
+	if batch != nil {
+		h.flush(batch)
+	}
+	h.wait.Wait()
+	return nil
+}
@@ -0,0 +1,203 @@
+package syslog
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+type BatchSuite struct{}
+
+var _ = Suite(&BatchSuite{})
+
+type sinkRecorder struct {
+	mu      sync.Mutex
+	batches [][]Message
+	flushed chan struct{}
+	err     error
+}
+
+func newSinkRecorder() *sinkRecorder {
+	return &sinkRecorder{flushed: make(chan struct{}, 16)}
+}
+
+func (s *sinkRecorder) Flush(batch []Message) error {
+	s.mu.Lock()
+	s.batches = append(s.batches, batch)
+	err := s.err
+	s.mu.Unlock()
+	s.flushed <- struct{}{}
+	return err
+}
+
+func (s *sinkRecorder) sizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sizes := []int{}
+	for _, b := range s.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+func waitFlushed(c *C, sink *sinkRecorder) {
+	select {
+	case <-sink.flushed:
+	case <-time.After(5 * time.Second):
+		c.Fatal("no batch flushed")
+	}
+}
+
+func (s *BatchSuite) TestMaxMessages(c *C) {
+	sink := newSinkRecorder()
+	handler := NewBatchHandler(sink)
+	handler.SetMaxMessages(2)
+	handler.SetMaxAge(0)
+
+	parseErr := errors.New("parse error")
+	handler.Handle(format.LogParts{"n": 1}, 10, nil)
+	handler.Handle(format.LogParts{"n": 2}, 20, parseErr)
+	waitFlushed(c, sink)
+	handler.Handle(format.LogParts{"n": 3}, 10, nil)
+	c.Check(sink.sizes(), DeepEquals, []int{2})
+	c.Check(sink.batches[0][1], DeepEquals, Message{format.LogParts{"n": 2}, 20, parseErr})
+
+	c.Assert(handler.Close(), IsNil)
+	c.Check(sink.sizes(), DeepEquals, []int{2, 1})
+	c.Check(handler.Stats(), Equals, BatchStats{Batches: 2, Messages: 3})
+
+	handler.Handle(format.LogParts{"n": 4}, 10, nil)
+	c.Check(handler.Stats().Dropped, Equals, uint64(1))
+}
+
+func (s *BatchSuite) TestMaxBytes(c *C) {
+	sink := newSinkRecorder()
+	handler := NewBatchHandler(sink)
+	handler.SetMaxBytes(100)
+	handler.SetMaxAge(0)
+
+	handler.Handle(format.LogParts{}, 60, nil)
+	handler.Handle(format.LogParts{}, 30, nil)
+	c.Check(sink.sizes(), DeepEquals, []int{})
+	handler.Handle(format.LogParts{}, 10, nil)
+	waitFlushed(c, sink)
+	c.Check(sink.sizes(), DeepEquals, []int{3})
+	c.Assert(handler.Close(), IsNil)
+}
+
+func (s *BatchSuite) TestMaxAge(c *C) {
+	sink := newSinkRecorder()
+	handler := NewBatchHandler(sink)
+	handler.SetMaxAge(20 * time.Millisecond)
+
+	start := time.Now()
+	handler.Handle(format.LogParts{}, 10, nil)
+	handler.Handle(format.LogParts{}, 10, nil)
+	waitFlushed(c, sink)
+	c.Check(time.Since(start) >= 20*time.Millisecond, Equals, true)
+	c.Check(sink.sizes(), DeepEquals, []int{2})
+
+	handler.Flush()
+	handler.Handle(format.LogParts{}, 10, nil)
+	handler.Flush()
+	waitFlushed(c, sink)
+	c.Check(sink.sizes(), DeepEquals, []int{2, 1})
+	c.Assert(handler.Close(), IsNil)
+}
+
+func (s *BatchSuite) TestRetries(c *C) {
+	sink := newSinkRecorder()
+	sink.err = errors.New("unavailable")
+	handler := NewBatchHandler(sink)
+	handler.SetRetryPolicy(2, time.Millisecond)
+	var dropped []Message
+	var droppedErr error
+	handler.SetErrorHandler(func(err error, batch []Message) {
+		droppedErr = err
+		dropped = batch
+	})
+
+	handler.Handle(format.LogParts{"n": 1}, 10, nil)
+	handler.Handle(format.LogParts{"n": 2}, 10, nil)
+	c.Assert(handler.Close(), IsNil)
+
+	c.Check(sink.sizes(), DeepEquals, []int{2, 2, 2})
+	c.Check(droppedErr, ErrorMatches, "unavailable")
+	c.Check(dropped, HasLen, 2)
+	c.Check(handler.Stats(), Equals, BatchStats{Failures: 3, Retries: 2, Dropped: 2})
+}
+
+func (s *BatchSuite) TestConcurrency(c *C) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	release := make(chan struct{})
+	sink := BatchSinkFunc(func(batch []Message) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		<-release
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+	handler := NewBatchHandler(sink)
+	handler.SetMaxMessages(1)
+	handler.SetConcurrency(2)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			handler.Handle(format.LogParts{}, 10, nil)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		c.Fatal("third flush not blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-done
+	c.Assert(handler.Close(), IsNil)
+
+	c.Check(maxRunning, Equals, 2)
+	c.Check(handler.Stats().Batches, Equals, uint64(3))
+}
+
+func (s *BatchSuite) TestServerShutdown(c *C) {
+	sink := newSinkRecorder()
+	handler := NewBatchHandler(sink)
+	server := NewServer()
+	server.SetFormat(RFC5424)
+	server.SetHandler(handler)
+	server.SetCloseHandlerOnStop(true)
+	c.Assert(server.ListenUDP("127.0.0.1:0"), IsNil)
+	c.Assert(server.Boot(), IsNil)
+
+	conn, err := net.Dial("udp", server.connections[0].LocalAddr().String())
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	_, err = conn.Write([]byte("<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed"))
+	c.Assert(err, IsNil)
+	for {
+		handler.mu.Lock()
+		pending := len(handler.batch)
+		handler.mu.Unlock()
+		if pending > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	c.Assert(server.Kill(), IsNil)
+	server.Wait()
+	c.Check(sink.sizes(), DeepEquals, []int{1})
+}
@@ -0,0 +1,159 @@
+package syslog
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// CharsetPolicy selects how the text fields of messages are decoded, for the
+// outputs to receive valid UTF-8 from devices sending legacy charsets
+type CharsetPolicy int
+
+const (
+	// Leave the fields as received (default)
+	CharsetRaw CharsetPolicy = iota
+	// Assume UTF-8, replacing the invalid sequences with U+FFFD
+	CharsetUTF8
+	// Decode the fields that are not valid UTF-8 with the decoder of the
+	// source, see SetCharsetDecoder, falls back to CharsetUTF8
+	CharsetLegacy
+	// Escape the control characters and the invalid bytes as # followed by
+	// their octal value, like rsyslog does, e.g. #033 for ESC
+	CharsetEscape
+)
+
+// CharsetDecoder converts text in a legacy charset to UTF-8. The Bytes method
+// of the decoders of golang.org/x/text can be used, e.g.
+// japanese.ShiftJIS.NewDecoder().Bytes
+type CharsetDecoder func(b []byte) ([]byte, error)
+
+// Latin1 decodes ISO-8859-1 text
+var Latin1 CharsetDecoder = decodeLatin1
+
+func decodeLatin1(b []byte) ([]byte, error) {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return []byte(string(runes)), nil
+}
+
+// The decoder of the sources in a network
+type charsetNetwork struct {
+	network *net.IPNet
+	decoder CharsetDecoder
+}
+
+// Sets the policy used to decode the text fields of messages, defaults to
+// CharsetRaw
+func (s *Server) SetCharsetPolicy(policy CharsetPolicy) {
+	s.charsetPolicy = policy
+}
+
+// Sets the decoder used by the CharsetLegacy policy for the clients in network,
+// an IP address or a CIDR like "10.1.0.0/16". The most specific network of a
+// client applies
+func (s *Server) SetCharsetDecoder(network string, decoder CharsetDecoder) error {
+	_, ipNet, err := net.ParseCIDR(network)
+	if err != nil {
+		ip := net.ParseIP(network)
+		if ip == nil {
+			return fmt.Errorf("invalid network %q", network)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		ipNet = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	}
+
+	s.charsetDecoders = append(s.charsetDecoders, charsetNetwork{network: ipNet, decoder: decoder})
+	sort.SliceStable(s.charsetDecoders, func(i, j int) bool {
+		ones, _ := s.charsetDecoders[i].network.Mask.Size()
+		otherOnes, _ := s.charsetDecoders[j].network.Mask.Size()
+		return ones > otherOnes
+	})
+	return nil
+}
+
+// Returns the decoder of the client, nil if none
+func (s *Server) charsetDecoder(client string) CharsetDecoder {
+	ip := net.ParseIP(clientIP(client))
+	if ip == nil {
+		return nil
+	}
+	for _, n := range s.charsetDecoders {
+		if n.network.Contains(ip) {
+			return n.decoder
+		}
+	}
+	return nil
+}
+
+// Decodes the text fields of a message according to the charset policy
+func (s *Server) decodeCharset(logParts format.LogParts, client string) {
+	var decoder CharsetDecoder
+	if s.charsetPolicy == CharsetLegacy {
+		decoder = s.charsetDecoder(client)
+	}
+	// The MSG of RFC5424 messages starting with a BOM is declared as UTF-8
+	declaredUTF8, _ := logParts["message_utf8"].(bool)
+
+	for key, value := range logParts {
+		text, ok := value.(string)
+		if !ok {
+			continue
+		}
+		switch s.charsetPolicy {
+		case CharsetUTF8:
+			logParts[key] = strings.ToValidUTF8(text, string(utf8.RuneError))
+		case CharsetLegacy:
+			logParts[key] = decodeLegacy(text, decoder, declaredUTF8 && key == "message")
+		case CharsetEscape:
+			logParts[key] = escapeCharset(text)
+		}
+	}
+}
+
+// Decodes text that is not valid UTF-8 with the decoder, if any and unless the
+// text is declared as UTF-8, replacing the invalid sequences otherwise
+func decodeLegacy(text string, decoder CharsetDecoder, declaredUTF8 bool) string {
+	if utf8.ValidString(text) {
+		return text
+	}
+	if decoder != nil && !declaredUTF8 {
+		if decoded, err := decoder([]byte(text)); err == nil {
+			return strings.ToValidUTF8(string(decoded), string(utf8.RuneError))
+		}
+	}
+	return strings.ToValidUTF8(text, string(utf8.RuneError))
+}
+
+// Escapes the control characters and the invalid bytes of text as # followed
+// by their 3 digits octal value
+func escapeCharset(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == utf8.RuneError && size <= 1, r < 0x20, r == 0x7f:
+			if b.Len() == 0 && i > 0 {
+				b.WriteString(text[:i])
+			}
+			fmt.Fprintf(&b, "#%03o", text[i])
+			size = 1
+		case b.Len() > 0:
+			b.WriteString(text[i : i+size])
+		}
+		i += size
+	}
+	if b.Len() == 0 {
+		return text
+	}
+	return b.String()
+}
@@ -0,0 +1,67 @@
+package syslog
+
+import (
+	"errors"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+type CharsetSuite struct{}
+
+var _ = Suite(&CharsetSuite{})
+
+func (s *CharsetSuite) parse(c *C, server *Server, f format.Format, line string, client string) format.LogParts {
+	handler := new(HandlerMock)
+	server.SetFormat(f)
+	server.SetHandler(handler)
+	server.parser([]byte(line), client, "")
+	return handler.LastLogParts
+}
+
+func (s *CharsetSuite) TestRaw(c *C) {
+	logParts := s.parse(c, NewServer(), RFC3164, "<13>May  1 20:51:40 host prog: caf\xe9", "10.0.0.1:514")
+	c.Check(logParts["content"], Equals, "caf\xe9")
+}
+
+func (s *CharsetSuite) TestUTF8(c *C) {
+	server := NewServer()
+	server.SetCharsetPolicy(CharsetUTF8)
+	logParts := s.parse(c, server, RFC3164, "<13>May  1 20:51:40 host prog: caf\xe9 ok \xc3\xa9", "10.0.0.1:514")
+	c.Check(logParts["content"], Equals, "caf� ok é")
+}
+
+func (s *CharsetSuite) TestLegacy(c *C) {
+	server := NewServer()
+	server.SetCharsetPolicy(CharsetLegacy)
+	c.Assert(server.SetCharsetDecoder("10.0.0.0/8", func(b []byte) ([]byte, error) {
+		return nil, errors.New("invalid")
+	}), IsNil)
+	c.Assert(server.SetCharsetDecoder("10.1.0.0/16", Latin1), IsNil)
+	c.Assert(server.SetCharsetDecoder("2001:db8::1", Latin1), IsNil)
+	c.Check(server.SetCharsetDecoder("10.1.0.0/33", Latin1), ErrorMatches, `invalid network "10.1.0.0/33"`)
+
+	line := "<13>May  1 20:51:40 host prog: caf\xe9"
+	c.Check(s.parse(c, server, RFC3164, line, "10.1.2.3:514")["content"], Equals, "café")
+	c.Check(s.parse(c, server, RFC3164, line, "[2001:db8::1]:514")["content"], Equals, "café")
+	// Failed decoding and no decoder
+	c.Check(s.parse(c, server, RFC3164, line, "10.2.0.1:514")["content"], Equals, "caf�")
+	c.Check(s.parse(c, server, RFC3164, line, "192.0.2.1:514")["content"], Equals, "caf�")
+	// Valid UTF-8 is left as is
+	c.Check(s.parse(c, server, RFC3164, "<13>May  1 20:51:40 host prog: café", "10.1.2.3:514")["content"], Equals, "café")
+
+	// Never decoded when declared as UTF-8
+	line = "<165>1 2003-10-11T22:14:15.003Z host app - - - \xef\xbb\xbfcaf\xe9"
+	logParts := s.parse(c, server, RFC5424, line, "10.1.2.3:514")
+	c.Check(logParts["message"], Equals, "caf�")
+	c.Check(logParts["message_utf8"], Equals, true)
+}
+
+func (s *CharsetSuite) TestEscape(c *C) {
+	server := NewServer()
+	server.SetCharsetPolicy(CharsetEscape)
+	logParts := s.parse(c, server, RFC3164, "<13>May  1 20:51:40 host prog: \x1b[31mred\x7f caf\xe9 é", "10.0.0.1:514")
+	c.Check(logParts["content"], Equals, "#033[31mred#177 caf#351 é")
+	c.Check(escapeCharset("\tplain"), Equals, "#011plain")
+	c.Check(escapeCharset("plain"), Equals, "plain")
+}
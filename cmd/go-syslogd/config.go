@@ -0,0 +1,217 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	drainTimeoutDefault = 10 * time.Second
+	readTimeoutDefault  = 5 * time.Minute
+)
+
+// Config is the content of the configuration file
+type Config struct {
+	Listeners []ListenerConfig `yaml:"listeners"`
+	Outputs   []OutputConfig   `yaml:"outputs"`
+	// Rules file routing the messages to the outputs by name, every output
+	// receiving every message if empty
+	Rules string `yaml:"rules"`
+	// Address of the health endpoint, disabled if empty. Not reloaded
+	Health string `yaml:"health"`
+	// Adds the facility_name and severity_name fields
+	NameFields bool `yaml:"name_fields"`
+	// Hostname of the messages without one: empty, source_ip, reverse_dns or
+	// tls_peer, source_ip by default
+	HostnamePolicy string `yaml:"hostname_policy"`
+	// Idle time after which the stream connections are closed
+	ReadTimeout time.Duration `yaml:"read_timeout"`
+	// Time given to the listeners to drain on shutdown
+	DrainTimeout time.Duration `yaml:"drain_timeout"`
+
+	hostnamePolicy syslog.HostnamePolicy
+}
+
+// ListenerConfig configures a listener
+type ListenerConfig struct {
+	// udp, tcp, tls or unixgram
+	Transport string `yaml:"transport"`
+	Address   string `yaml:"address"`
+	// rfc3164, rfc5424, rfc6587, cisco or automatic, the default
+	Format string     `yaml:"format"`
+	TLS    *TLSConfig `yaml:"tls"`
+
+	format    format.Format
+	tlsConfig *tls.Config
+}
+
+// TLSConfig configures a tls listener
+type TLSConfig struct {
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+	// CA certificates of the clients, which must present a certificate when
+	// set, its CN being the tls_peer field. Without it, any client is accepted
+	ClientCA string `yaml:"client_ca"`
+}
+
+// OutputConfig configures an output
+type OutputConfig struct {
+	// Name of the output, as used by the rules
+	Name string `yaml:"name"`
+	// stdout, file or forward
+	Type string `yaml:"type"`
+	// json, ecs, otel, rfc3164 or rfc5424, json by default for stdout,
+	// rfc3164 for file and rfc5424 for forward
+	Encoder string `yaml:"encoder"`
+	// Template of the messages, replacing the encoder
+	Template string `yaml:"template"`
+
+	// Path template of the files of file outputs
+	Path         string        `yaml:"path"`
+	MaxSize      int64         `yaml:"max_size"`
+	RotateEvery  time.Duration `yaml:"rotate_every"`
+	MaxBackups   int           `yaml:"max_backups"`
+	MaxAge       time.Duration `yaml:"max_age"`
+	Compress     bool          `yaml:"compress"`
+	SyncInterval time.Duration `yaml:"sync_interval"`
+
+	// Network, udp, tcp or tls, and address of the collector of forward
+	// outputs
+	Network  string `yaml:"network"`
+	Address  string `yaml:"address"`
+	Insecure bool   `yaml:"insecure"`
+}
+
+var formats = map[string]format.Format{
+	"rfc3164":   syslog.RFC3164,
+	"rfc5424":   syslog.RFC5424,
+	"rfc6587":   syslog.RFC6587,
+	"cisco":     syslog.Cisco,
+	"automatic": syslog.Automatic,
+}
+
+var hostnamePolicies = map[string]syslog.HostnamePolicy{
+	"empty":       syslog.HostnameEmpty,
+	"source_ip":   syslog.HostnameSourceIP,
+	"reverse_dns": syslog.HostnameReverseDNS,
+	"tls_peer":    syslog.HostnameTLSPeer,
+}
+
+// Loads and validates a configuration file
+func loadConfig(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return config, nil
+}
+
+func (c *Config) validate() error {
+	if len(c.Listeners) == 0 {
+		return fmt.Errorf("no listeners")
+	}
+	if len(c.Outputs) == 0 {
+		return fmt.Errorf("no outputs")
+	}
+
+	for i := range c.Listeners {
+		if err := c.Listeners[i].validate(); err != nil {
+			return fmt.Errorf("listener %d: %v", i+1, err)
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, o := range c.Outputs {
+		if o.Name == "" {
+			return fmt.Errorf("output %d: no name", i+1)
+		}
+		if names[o.Name] {
+			return fmt.Errorf("output %d: duplicate name %q", i+1, o.Name)
+		}
+		names[o.Name] = true
+	}
+
+	policy, ok := hostnamePolicies[c.HostnamePolicy]
+	if c.HostnamePolicy == "" {
+		policy, ok = syslog.HostnameSourceIP, true
+	}
+	if !ok {
+		return fmt.Errorf("unknown hostname policy %q", c.HostnamePolicy)
+	}
+	c.hostnamePolicy = policy
+
+	if c.ReadTimeout == 0 {
+		c.ReadTimeout = readTimeoutDefault
+	}
+	if c.DrainTimeout == 0 {
+		c.DrainTimeout = drainTimeoutDefault
+	}
+	return nil
+}
+
+func (l *ListenerConfig) validate() error {
+	switch l.Transport {
+	case "udp", "tcp", "unixgram":
+		if l.TLS != nil {
+			return fmt.Errorf("tls settings on a %s listener", l.Transport)
+		}
+	case "tls":
+		if l.TLS == nil {
+			return fmt.Errorf("no tls settings")
+		}
+		tlsConfig, err := l.TLS.load()
+		if err != nil {
+			return err
+		}
+		l.tlsConfig = tlsConfig
+	default:
+		return fmt.Errorf("unknown transport %q", l.Transport)
+	}
+	if l.Address == "" {
+		return fmt.Errorf("no address")
+	}
+
+	if l.Format == "" {
+		l.Format = "automatic"
+	}
+	var ok bool
+	if l.format, ok = formats[l.Format]; !ok {
+		return fmt.Errorf("unknown format %q", l.Format)
+	}
+	return nil
+}
+
+func (t *TLSConfig) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(t.Cert, t.Key)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if t.ClientCA != "" {
+		pem, err := ioutil.ReadFile(t.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", t.ClientCA)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ConfigSuite struct{}
+
+var _ = Suite(&ConfigSuite{})
+
+func writeConfig(c *C, dir string, name string, content string) string {
+	filename := filepath.Join(dir, name)
+	c.Assert(ioutil.WriteFile(filename, []byte(content), 0644), IsNil)
+	return filename
+}
+
+func (s *ConfigSuite) TestLoad(c *C) {
+	filename := writeConfig(c, c.MkDir(), "config.yml", `
+listeners:
+  - transport: udp
+    address: 127.0.0.1:5514
+  - transport: tcp
+    address: 127.0.0.1:5514
+    format: rfc6587
+outputs:
+  - name: console
+    type: stdout
+  - name: archive
+    type: file
+    path: /tmp/{{hostname}}.log
+    rotate_every: 24h
+hostname_policy: reverse_dns
+`)
+	config, err := loadConfig(filename)
+	c.Assert(err, IsNil)
+	c.Check(config.Listeners, HasLen, 2)
+	c.Check(config.Listeners[0].format, Equals, syslog.Automatic)
+	c.Check(config.Listeners[1].format, Equals, syslog.RFC6587)
+	c.Check(config.Outputs[1].RotateEvery, Equals, 24*time.Hour)
+	c.Check(config.hostnamePolicy, Equals, syslog.HostnameReverseDNS)
+	c.Check(config.DrainTimeout, Equals, drainTimeoutDefault)
+	c.Check(check(filename), IsNil)
+}
+
+func (s *ConfigSuite) TestInvalid(c *C) {
+	dir := c.MkDir()
+	writeConfig(c, dir, "rules.yml", "rules:\n  - actions:\n      - route: nowhere\n")
+	listener := "listeners:\n  - {transport: udp, address: \"127.0.0.1:5514\"}\n"
+	output := "outputs:\n  - {name: console, type: stdout}\n"
+
+	fixtures := map[string]string{
+		output:   "no listeners",
+		listener: "no outputs",
+		"listeners:\n  - {transport: sctp, address: x}\n" + output:                      `listener 1: unknown transport "sctp"`,
+		"listeners:\n  - {transport: udp, address: x, format: json}\n" + output:         `listener 1: unknown format "json"`,
+		"listeners:\n  - {transport: tls, address: x}\n" + output:                       "listener 1: no tls settings",
+		"listeners:\n  - {transport: udp, address: x, tls: {cert: a}}\n" + output:       "listener 1: tls settings on a udp listener",
+		listener + "outputs:\n  - {name: a, type: stdout}\n  - {name: a, type: stdout}": `output 2: duplicate name "a"`,
+		listener + output + "hostname_policy: dns\n":                                    `unknown hostname policy "dns"`,
+		listener + output + "unknown: true\n":                                           ".*field unknown not found.*",
+	}
+	for content, expected := range fixtures {
+		_, err := loadConfig(writeConfig(c, dir, "config.yml", content))
+		c.Check(err, ErrorMatches, "(?s).*: "+expected, Commentf("%s", content))
+	}
+
+	checks := map[string]string{
+		listener + "outputs:\n  - {name: a, type: syslog}\n":                    `output a: unknown type "syslog"`,
+		listener + "outputs:\n  - {name: a, type: file}\n":                      "output a: no path",
+		listener + "outputs:\n  - {name: a, type: stdout, encoder: xml}\n":      `output a: unknown encoder "xml"`,
+		listener + "outputs:\n  - {name: a, type: forward, network: tcp}\n":     "output a: no address",
+		listener + "outputs:\n  - {name: a, type: stdout, template: \"{{x\"}\n": "output a: .*",
+		listener + output + "rules: " + filepath.Join(dir, "rules.yml") + "\n":  ".*nowhere.*",
+	}
+	for content, expected := range checks {
+		c.Check(check(writeConfig(c, dir, "config.yml", content)), ErrorMatches, expected, Commentf("%s", content))
+	}
+}
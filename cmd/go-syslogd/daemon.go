@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// Counters of the daemon, as reported by the health endpoint
+type stats struct {
+	Messages    uint64 `json:"messages"`
+	ParseErrors uint64 `json:"parse_errors"`
+	Reloads     uint64 `json:"reloads"`
+}
+
+type daemon struct {
+	stats stats // first for 64-bit alignment of atomic operations
+
+	filename string
+	stdout   io.Writer
+	started  time.Time
+
+	// Held for reading while handling a message, so that the outputs are not
+	// closed under it when reloading
+	mu              sync.RWMutex
+	config          *Config
+	pipeline        *pipeline
+	servers         []*syslog.Server
+	draining        bool
+	lastReloadError string
+}
+
+func newDaemon(filename string, stdout io.Writer) *daemon {
+	return &daemon{filename: filename, stdout: stdout}
+}
+
+// Loads the configuration, and starts the listeners
+func (d *daemon) start() error {
+	config, err := loadConfig(d.filename)
+	if err != nil {
+		return err
+	}
+	p, err := newPipeline(config, d.stdout)
+	if err != nil {
+		return err
+	}
+	servers, err := d.startServers(config)
+	if err != nil {
+		p.close()
+		return err
+	}
+
+	d.mu.Lock()
+	d.config, d.pipeline, d.servers = config, p, servers
+	d.started = time.Now()
+	d.mu.Unlock()
+	return nil
+}
+
+// Starts a server per listener, as the format is set per server
+func (d *daemon) startServers(config *Config) ([]*syslog.Server, error) {
+	var servers []*syslog.Server
+	for i, l := range config.Listeners {
+		server := syslog.NewServer()
+		server.SetFormat(l.format)
+		server.SetHandler(syslog.HandlerFunc(d.handle))
+		server.SetNameFields(config.NameFields)
+		server.SetHostnamePolicy(config.hostnamePolicy)
+		server.SetTimeout(int64(config.ReadTimeout / time.Millisecond))
+
+		var err error
+		switch l.Transport {
+		case "udp":
+			err = server.ListenUDP(l.Address)
+		case "tcp":
+			err = server.ListenTCP(l.Address)
+		case "tls":
+			if l.tlsConfig.ClientCAs == nil {
+				// No client certificate to name the peer after
+				server.SetTlsPeerNameFunc(nil)
+			}
+			err = server.ListenTCPTLS(l.Address, l.tlsConfig)
+		case "unixgram":
+			err = server.ListenUnixgram(l.Address)
+		}
+		if err == nil {
+			err = server.Boot()
+		}
+		if err != nil {
+			server.Kill()
+			stopServers(servers, config.DrainTimeout)
+			return nil, fmt.Errorf("listener %d: %v", i+1, err)
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
+
+// Stops the servers, waiting for them up to timeout
+func stopServers(servers []*syslog.Server, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		for _, server := range servers {
+			server.Kill()
+		}
+		for _, server := range servers {
+			server.Wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (d *daemon) handle(logParts format.LogParts, messageLength int64, err error) {
+	atomic.AddUint64(&d.stats.Messages, 1)
+	if err != nil {
+		atomic.AddUint64(&d.stats.ParseErrors, 1)
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.pipeline != nil {
+		d.pipeline.handler.Handle(logParts, messageLength, err)
+	}
+}
+
+// Loads the configuration again and applies it, the listeners being restarted
+// only if their settings changed. On error, the previous configuration stays
+// in use
+func (d *daemon) reload() error {
+	err := d.doReload()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	atomic.AddUint64(&d.stats.Reloads, 1)
+	d.lastReloadError = ""
+	if err != nil {
+		d.lastReloadError = err.Error()
+	}
+	return err
+}
+
+func (d *daemon) doReload() error {
+	config, err := loadConfig(d.filename)
+	if err != nil {
+		return err
+	}
+	p, err := newPipeline(config, d.stdout)
+	if err != nil {
+		return err
+	}
+
+	d.mu.RLock()
+	old := d.config
+	d.mu.RUnlock()
+
+	var servers []*syslog.Server
+	restart := !sameListeners(old, config)
+	if restart {
+		// The listeners may use the same addresses, they must be closed first
+		d.mu.RLock()
+		oldServers := d.servers
+		d.mu.RUnlock()
+		if !stopServers(oldServers, old.DrainTimeout) {
+			log.Printf("listeners not drained after %v", old.DrainTimeout)
+		}
+		if servers, err = d.startServers(config); err != nil {
+			p.close()
+			if restored, rerr := d.startServers(old); rerr == nil {
+				d.mu.Lock()
+				d.servers = restored
+				d.mu.Unlock()
+			} else {
+				log.Printf("previous listeners not restored: %v", rerr)
+			}
+			return err
+		}
+	}
+
+	d.mu.Lock()
+	oldPipeline := d.pipeline
+	d.config, d.pipeline = config, p
+	if restart {
+		d.servers = servers
+	}
+	d.mu.Unlock()
+
+	oldPipeline.close()
+	return nil
+}
+
+// Tells whether the listeners, and the server settings, are the same in both
+// configurations
+func sameListeners(a *Config, b *Config) bool {
+	if len(a.Listeners) != len(b.Listeners) {
+		return false
+	}
+	for i, la := range a.Listeners {
+		lb := b.Listeners[i]
+		if la.Transport != lb.Transport || la.Address != lb.Address || la.Format != lb.Format ||
+			!reflect.DeepEqual(la.TLS, lb.TLS) {
+			return false
+		}
+		// Renewed certificates
+		if la.tlsConfig != nil && !reflect.DeepEqual(la.tlsConfig.Certificates, lb.tlsConfig.Certificates) {
+			return false
+		}
+	}
+	return a.NameFields == b.NameFields &&
+		a.HostnamePolicy == b.HostnamePolicy &&
+		a.ReadTimeout == b.ReadTimeout
+}
+
+// Stops the listeners, waiting for the messages in progress up to the drain
+// timeout, and closes the outputs
+func (d *daemon) shutdown() {
+	d.mu.Lock()
+	d.draining = true
+	servers, timeout := d.servers, d.config.DrainTimeout
+	d.mu.Unlock()
+
+	if !stopServers(servers, timeout) {
+		log.Printf("listeners not drained after %v", timeout)
+	}
+
+	d.mu.Lock()
+	p := d.pipeline
+	d.pipeline = nil
+	d.mu.Unlock()
+	p.close()
+}
+
+// Health endpoint, answering 200 with the counters while running, and 503 once
+// draining
+func (d *daemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.RLock()
+	status := struct {
+		Status          string  `json:"status"`
+		Uptime          float64 `json:"uptime_seconds"`
+		Listeners       int     `json:"listeners"`
+		Outputs         int     `json:"outputs"`
+		LastReloadError string  `json:"last_reload_error,omitempty"`
+		stats
+	}{
+		Status:          "ok",
+		Uptime:          time.Since(d.started).Seconds(),
+		Listeners:       len(d.servers),
+		LastReloadError: d.lastReloadError,
+		stats: stats{
+			Messages:    atomic.LoadUint64(&d.stats.Messages),
+			ParseErrors: atomic.LoadUint64(&d.stats.ParseErrors),
+			Reloads:     atomic.LoadUint64(&d.stats.Reloads),
+		},
+	}
+	if d.pipeline != nil {
+		status.Outputs = len(d.pipeline.outputs)
+	}
+	code := http.StatusOK
+	if d.draining {
+		status.Status = "draining"
+		code = http.StatusServiceUnavailable
+	}
+	d.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+type DaemonSuite struct{}
+
+var _ = Suite(&DaemonSuite{})
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func freeUDPAddress(c *C) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	return conn.LocalAddr().String()
+}
+
+func send(c *C, addr string, msg string) {
+	conn, err := net.Dial("udp", addr)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	_, err = conn.Write([]byte(msg))
+	c.Assert(err, IsNil)
+}
+
+func waitFor(c *C, cond func() bool) {
+	for start := time.Now(); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			c.Fatal("timeout")
+		}
+	}
+}
+
+func health(d *daemon) (int, map[string]interface{}) {
+	rec := httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+	var status map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &status)
+	return rec.Code, status
+}
+
+func (s *DaemonSuite) TestDaemon(c *C) {
+	dir := c.MkDir()
+	addr := freeUDPAddress(c)
+	filename := writeConfig(c, dir, "config.yml", `
+listeners:
+  - {transport: udp, address: "`+addr+`", format: rfc5424}
+outputs:
+  - {name: console, type: stdout}
+`)
+
+	var stdout syncBuffer
+	d := newDaemon(filename, &stdout)
+	c.Assert(d.start(), IsNil)
+
+	send(c, addr, "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed")
+	waitFor(c, func() bool { return strings.Contains(stdout.String(), "'su root' failed") })
+	var logParts map[string]interface{}
+	c.Assert(json.Unmarshal([]byte(stdout.String()), &logParts), IsNil)
+	c.Check(logParts["app_name"], Equals, "su")
+
+	code, status := health(d)
+	c.Check(code, Equals, http.StatusOK)
+	c.Check(status["status"], Equals, "ok")
+	c.Check(status["messages"], Equals, float64(1))
+	c.Check(status["listeners"], Equals, float64(1))
+
+	// Outputs only
+	logFile := filepath.Join(dir, "{{app_name}}.log")
+	writeConfig(c, dir, "config.yml", `
+listeners:
+  - {transport: udp, address: "`+addr+`", format: rfc5424}
+outputs:
+  - {name: archive, type: file, path: "`+logFile+`", encoder: rfc5424}
+`)
+	servers := d.servers
+	c.Assert(d.reload(), IsNil)
+	c.Check(d.servers, DeepEquals, servers)
+	send(c, addr, "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com sshd - - - accepted")
+	waitFor(c, func() bool {
+		data, _ := ioutil.ReadFile(filepath.Join(dir, "sshd.log"))
+		return strings.Contains(string(data), "accepted")
+	})
+
+	// Invalid configuration, kept running
+	writeConfig(c, dir, "config.yml", "listeners: []\n")
+	c.Check(d.reload(), ErrorMatches, ".*no listeners")
+	_, status = health(d)
+	c.Check(status["last_reload_error"], Matches, ".*no listeners")
+	c.Check(status["outputs"], Equals, float64(1))
+
+	// New listener
+	addr2 := freeUDPAddress(c)
+	writeConfig(c, dir, "config.yml", `
+listeners:
+  - {transport: udp, address: "`+addr2+`", format: rfc3164}
+outputs:
+  - {name: console, type: stdout, encoder: rfc3164}
+`)
+	c.Assert(d.reload(), IsNil)
+	send(c, addr2, "<34>Oct 11 22:14:15 mymachine cron: job done")
+	waitFor(c, func() bool { return strings.Contains(stdout.String(), "cron: job done") })
+
+	d.shutdown()
+	code, status = health(d)
+	c.Check(code, Equals, http.StatusServiceUnavailable)
+	c.Check(status["status"], Equals, "draining")
+	c.Check(status["reloads"], Equals, float64(3))
+}
+
+// Writes a self-signed certificate for 127.0.0.1 and its key to dir
+func writeCertificate(c *C, dir string) (certFile string, keyFile string, cert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "syslogd"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+	cert, err = x509.ParseCertificate(der)
+	c.Assert(err, IsNil)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	c.Assert(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600), IsNil)
+	return certFile, keyFile, cert
+}
+
+func (s *DaemonSuite) TestTLSWithoutClientCA(c *C) {
+	dir := c.MkDir()
+	certFile, keyFile, cert := writeCertificate(c, dir)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	addr := listener.Addr().String()
+	listener.Close()
+	filename := writeConfig(c, dir, "config.yml", `
+listeners:
+  - transport: tls
+    address: "`+addr+`"
+    format: rfc5424
+    tls: {cert: "`+certFile+`", key: "`+keyFile+`"}
+outputs:
+  - {name: console, type: stdout}
+`)
+
+	var stdout syncBuffer
+	d := newDaemon(filename, &stdout)
+	c.Assert(d.start(), IsNil)
+	defer d.shutdown()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: pool})
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	_, err = conn.Write([]byte("<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed\n"))
+	c.Assert(err, IsNil)
+	waitFor(c, func() bool { return strings.Contains(stdout.String(), "'su root' failed") })
+}
+
+func (s *DaemonSuite) TestForwarderDown(c *C) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	logs := &syncBuffer{}
+	log.SetOutput(logs)
+	defer log.SetOutput(os.Stderr)
+
+	h, err := newOutput(OutputConfig{Name: "fwd", Type: "forward", Network: "tcp", Address: addr}, nil)
+	c.Assert(err, IsNil)
+	logParts := format.LogParts{"hostname": "host", "app_name": "app", "message": "msg"}
+	start := time.Now()
+	for i := 0; i < 5000; i++ {
+		h.Handle(logParts, 3, nil)
+	}
+	// Neither held up by the connections nor logging every message
+	c.Assert(time.Since(start) < time.Second, Equals, true)
+	c.Assert(logs.String(), Equals, "")
+
+	c.Assert(h.(io.Closer).Close(), IsNil)
+	c.Assert(logs.String(), Matches, "(?s).*output fwd: 5000 messages dropped: .*connection refused\n")
+}
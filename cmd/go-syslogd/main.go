@@ -0,0 +1,113 @@
+// Command go-syslogd is a syslog server built on go-syslog, configured with a
+// YAML file:
+//
+//	listeners:
+//	  - transport: udp            # udp, tcp, tls or unixgram
+//	    address: 0.0.0.0:514
+//	    format: automatic         # rfc3164, rfc5424, rfc6587, cisco or automatic
+//	  - transport: tls
+//	    address: 0.0.0.0:6514
+//	    format: rfc6587
+//	    tls:
+//	      cert: /etc/go-syslogd/server.crt
+//	      key: /etc/go-syslogd/server.key
+//	      client_ca: /etc/go-syslogd/clients.crt
+//	outputs:
+//	  - name: console
+//	    type: stdout
+//	    encoder: json             # json, ecs, otel, rfc3164 or rfc5424
+//	  - name: archive
+//	    type: file
+//	    path: /var/log/remote/{{hostname}}/{{app_name,tag}}.log
+//	    rotate_every: 24h
+//	    max_backups: 30
+//	    compress: true
+//	  - name: central
+//	    type: forward
+//	    network: tcp              # udp, tcp or tls
+//	    address: collector.example.com:601
+//	rules: /etc/go-syslogd/rules.yml
+//	health: 127.0.0.1:8514
+//	name_fields: true
+//
+// Every output receives every message, unless a rules file routes them by
+// output name, see the rules package.
+//
+// SIGHUP reloads the configuration, the listeners being restarted only if
+// their settings changed, and SIGINT or SIGTERM stops the listeners and drains
+// the messages in progress before closing the outputs. The health endpoint
+// answers /health with the counters of the server as JSON.
+//
+// Usage:
+//
+//	go-syslogd [-config file] [-check-config]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	filename := flag.String("config", "/etc/go-syslogd/go-syslogd.yml", "configuration file")
+	checkConfig := flag.Bool("check-config", false, "check the configuration file and exit")
+	flag.Parse()
+	log.SetPrefix("go-syslogd: ")
+
+	if *checkConfig {
+		if err := check(*filename); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("configuration OK")
+		return
+	}
+
+	d := newDaemon(*filename, os.Stdout)
+	if err := d.start(); err != nil {
+		log.Fatal(err)
+	}
+	if d.config.Health != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/health", d)
+		go func() {
+			log.Fatal(http.ListenAndServe(d.config.Health, mux))
+		}()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	for sig := range signals {
+		if sig == syscall.SIGHUP {
+			if err := d.reload(); err != nil {
+				log.Printf("reload: %v", err)
+			} else {
+				log.Printf("configuration reloaded")
+			}
+			continue
+		}
+		log.Printf("%v, draining", sig)
+		d.shutdown()
+		return
+	}
+}
+
+// Checks a configuration file, building the outputs and rules without
+// starting anything
+func check(filename string) error {
+	config, err := loadConfig(filename)
+	if err != nil {
+		return err
+	}
+	p, err := newPipeline(config, os.Stdout)
+	if err != nil {
+		return err
+	}
+	p.close()
+	return nil
+}
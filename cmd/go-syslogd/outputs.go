@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/encoder"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/middleware"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/rules"
+)
+
+const (
+	forwardTimeout = 5 * time.Second
+	// Time during which no connection is attempted after a failed one
+	forwardRetryInterval = 5 * time.Second
+	// Interval at which the number of dropped messages is logged
+	forwardReportInterval = time.Minute
+	forwardQueueSize      = 1024
+)
+
+// The handlers of the outputs, and the handler routing the messages to them
+type pipeline struct {
+	handler syslog.Handler
+	outputs map[string]syslog.Handler
+}
+
+// Builds the outputs of a configuration, and the rules routing to them
+func newPipeline(config *Config, stdout io.Writer) (*pipeline, error) {
+	p := &pipeline{outputs: make(map[string]syslog.Handler)}
+	var handlers []syslog.Handler
+	for _, o := range config.Outputs {
+		h, err := newOutput(o, stdout)
+		if err != nil {
+			p.close()
+			return nil, fmt.Errorf("output %s: %v", o.Name, err)
+		}
+		p.outputs[o.Name] = h
+		handlers = append(handlers, h)
+	}
+
+	if config.Rules == "" {
+		p.handler = middleware.Tee(handlers...)
+		return p, nil
+	}
+	ruleset, err := rules.Load(config.Rules, p.outputs)
+	if err != nil {
+		p.close()
+		return nil, err
+	}
+	p.handler = ruleset
+	return p, nil
+}
+
+func (p *pipeline) close() {
+	for name, h := range p.outputs {
+		if closer, ok := h.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Printf("output %s: %v", name, err)
+			}
+		}
+	}
+}
+
+func newOutput(o OutputConfig, stdout io.Writer) (syslog.Handler, error) {
+	var enc syslog.Encoder
+	var err error
+	switch o.Type {
+	case "stdout":
+		if enc, err = newEncoder(o, "json"); err != nil {
+			return nil, err
+		}
+		return &writerOutput{w: stdout, encoder: enc}, nil
+	case "file":
+		if enc, err = newEncoder(o, "rfc3164"); err != nil {
+			return nil, err
+		}
+		if o.Path == "" {
+			return nil, fmt.Errorf("no path")
+		}
+		h, err := syslog.NewFileHandler(o.Path)
+		if err != nil {
+			return nil, err
+		}
+		h.SetEncoder(enc)
+		h.SetMaxSize(o.MaxSize)
+		h.SetRotationInterval(o.RotateEvery)
+		h.SetRetention(o.MaxBackups, o.MaxAge)
+		h.SetCompression(o.Compress)
+		if o.SyncInterval > 0 {
+			h.SetSyncPolicy(syslog.SyncInterval, o.SyncInterval)
+		}
+		h.SetErrorHandler(func(err error) { log.Printf("output %s: %v", o.Name, err) })
+		return h, nil
+	case "forward":
+		if enc, err = newEncoder(o, "rfc5424"); err != nil {
+			return nil, err
+		}
+		switch o.Network {
+		case "udp", "tcp", "tls":
+		default:
+			return nil, fmt.Errorf("unknown network %q", o.Network)
+		}
+		if o.Address == "" {
+			return nil, fmt.Errorf("no address")
+		}
+		return newForwarder(o, enc), nil
+	}
+	return nil, fmt.Errorf("unknown type %q", o.Type)
+}
+
+func newEncoder(o OutputConfig, defaultEncoder string) (syslog.Encoder, error) {
+	if o.Template != "" {
+		if o.Encoder != "" {
+			return nil, fmt.Errorf("both an encoder and a template")
+		}
+		return syslog.ParseTemplate(o.Template)
+	}
+
+	name := o.Encoder
+	if name == "" {
+		name = defaultEncoder
+	}
+	switch name {
+	case "json":
+		return &encoder.JSONLines{}, nil
+	case "ecs":
+		return &encoder.ECS{}, nil
+	case "otel":
+		return &encoder.OTel{}, nil
+	case "rfc3164":
+		return syslog.TemplateRFC3164, nil
+	case "rfc5424":
+		return syslog.TemplateRFC5424, nil
+	}
+	return nil, fmt.Errorf("unknown encoder %q", name)
+}
+
+// Writes the encoded messages to a writer
+type writerOutput struct {
+	mu      sync.Mutex
+	w       io.Writer
+	encoder syslog.Encoder
+}
+
+func (o *writerOutput) Handle(logParts format.LogParts, messageLength int64, err error) {
+	data, err := o.encoder.Encode(logParts)
+	if err != nil {
+		log.Printf("stdout: %v", err)
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.w.Write(data)
+}
+
+// Forwards the messages to another collector, framed with octet counting over
+// tcp and tls. The messages are sent from a goroutine, so that a collector down
+// does not hold up the listeners. The messages that cannot be queued or sent
+// are dropped, their number being logged periodically
+type forwarder struct {
+	dropped  uint64 // first for 64-bit alignment of atomic operations
+	name     string
+	network  string
+	addr     string
+	insecure bool
+	encoder  syslog.Encoder
+	queue    chan []byte
+	done     chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+
+	// Used by the goroutine only, until done is closed
+	conn        net.Conn
+	lastFailure time.Time
+	dialErr     error
+	lastErr     error
+	closeErr    error
+}
+
+func newForwarder(o OutputConfig, enc syslog.Encoder) *forwarder {
+	f := &forwarder{
+		name:     o.Name,
+		network:  o.Network,
+		addr:     o.Address,
+		insecure: o.Insecure,
+		encoder:  enc,
+		queue:    make(chan []byte, forwardQueueSize),
+		done:     make(chan struct{}),
+	}
+	go f.run()
+	return f
+}
+
+func (f *forwarder) Handle(logParts format.LogParts, messageLength int64, err error) {
+	data, err := f.encoder.Encode(logParts)
+	if err != nil {
+		log.Printf("output %s: %v", f.name, err)
+		return
+	}
+	data = bytes.TrimRight(data, "\n")
+	if f.network != "udp" {
+		data = format.AppendOctetCounted(nil, data)
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		return
+	}
+	select {
+	case f.queue <- data:
+	default:
+		atomic.AddUint64(&f.dropped, 1)
+	}
+}
+
+func (f *forwarder) run() {
+	defer close(f.done)
+	ticker := time.NewTicker(forwardReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case data, ok := <-f.queue:
+			if !ok {
+				f.report()
+				if f.conn != nil {
+					f.closeErr = f.conn.Close()
+				}
+				return
+			}
+			if err := f.send(data); err != nil {
+				f.lastErr = err
+				atomic.AddUint64(&f.dropped, 1)
+			}
+		case <-ticker.C:
+			f.report()
+		}
+	}
+}
+
+// Logs the number of messages dropped since the last report
+func (f *forwarder) report() {
+	dropped := atomic.SwapUint64(&f.dropped, 0)
+	if dropped == 0 {
+		return
+	}
+	if f.lastErr != nil {
+		log.Printf("output %s: %d messages dropped: %v", f.name, dropped, f.lastErr)
+	} else {
+		log.Printf("output %s: %d messages dropped", f.name, dropped)
+	}
+	f.lastErr = nil
+}
+
+func (f *forwarder) send(data []byte) error {
+	var err error
+	// Connecting again once if the connection was lost
+	for attempt := 0; attempt < 2; attempt++ {
+		if f.conn == nil {
+			if !f.lastFailure.IsZero() && time.Since(f.lastFailure) < forwardRetryInterval {
+				return f.dialErr
+			}
+			if err = f.dial(); err != nil {
+				f.lastFailure = time.Now()
+				f.dialErr = err
+				return err
+			}
+		}
+		f.conn.SetWriteDeadline(time.Now().Add(forwardTimeout))
+		if _, err = f.conn.Write(data); err == nil {
+			return nil
+		}
+		f.conn.Close()
+		f.conn = nil
+	}
+	return err
+}
+
+func (f *forwarder) dial() error {
+	dialer := &net.Dialer{Timeout: forwardTimeout}
+	var err error
+	if f.network == "tls" {
+		f.conn, err = tls.DialWithDialer(dialer, "tcp", f.addr, &tls.Config{InsecureSkipVerify: f.insecure})
+	} else {
+		f.conn, err = dialer.Dial(f.network, f.addr)
+	}
+	if err != nil {
+		f.conn = nil
+	}
+	return err
+}
+
+// Close stops accepting messages, and waits for the queued ones to be sent or
+// dropped
+func (f *forwarder) Close() error {
+	f.mu.Lock()
+	if !f.closed {
+		f.closed = true
+		close(f.queue)
+	}
+	f.mu.Unlock()
+
+	<-f.done
+	return f.closeErr
+}
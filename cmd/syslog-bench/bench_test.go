@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"math/rand"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type BenchSuite struct{}
+
+var _ = Suite(&BenchSuite{})
+
+func newGenerator(c *C, kindMix string, framingMix string) *generator {
+	k, err := parseMix(kindMix, kinds)
+	c.Assert(err, IsNil)
+	f, err := parseMix(framingMix, framings)
+	c.Assert(err, IsNil)
+	return &generator{run: "r1", hostname: "bench.example.com", kinds: k, framings: f}
+}
+
+func (s *BenchSuite) TestParseMix(c *C) {
+	m, err := parseMix("rfc3164=1, rfc5424=3", kinds)
+	c.Assert(err, IsNil)
+	c.Check(m.names, DeepEquals, []string{"rfc3164", "rfc5424"})
+	c.Check(m.total, Equals, 4)
+
+	m, err = parseMix("octet", framings)
+	c.Assert(err, IsNil)
+	c.Check(m.pick(rand.New(rand.NewSource(1))), Equals, "octet")
+
+	_, err = parseMix("json=1", kinds)
+	c.Check(err, ErrorMatches, `unknown name "json".*`)
+	_, err = parseMix("octet=x", framings)
+	c.Check(err, ErrorMatches, `invalid weight in "octet=x"`)
+	_, err = parseMix("octet=0", framings)
+	c.Check(err, ErrorMatches, `no weight in "octet=0"`)
+}
+
+func (s *BenchSuite) TestMessages(c *C) {
+	now := time.Date(2026, time.October, 19, 10, 0, 0, 123456789, time.UTC)
+	for _, kind := range kinds {
+		g := newGenerator(c, kind, "newline=1,octet=1")
+		g.size = 200
+		rng := rand.New(rand.NewSource(1))
+
+		var stream []byte
+		for seq := 0; seq < 10; seq++ {
+			msg := g.message(rng, 3, seq, now)
+			c.Check(len(msg), Equals, 200)
+			stream = g.frame(rng, stream, msg)
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(stream))
+		scanner.Split(syslog.Automatic.GetSplitFunc())
+		seq := 0
+		for ; scanner.Scan(); seq++ {
+			c.Check(format.DetectFormat(scanner.Bytes()), Equals, kind)
+			p := syslog.Automatic.GetParser(scanner.Bytes())
+			c.Assert(p.Parse(), IsNil)
+			logParts := p.Dump()
+			text, _ := logParts["message"].(string)
+			if kind == "rfc3164" {
+				text = logParts["content"].(string)
+			}
+			t, ok := parseTracking(text)
+			c.Assert(ok, Equals, true)
+			c.Check(t, Equals, tracking{run: "r1", conn: 3, seq: seq, sent: time.Unix(0, now.UnixNano())})
+		}
+		c.Check(seq, Equals, 10)
+	}
+}
+
+func (s *BenchSuite) TestReceiver(c *C) {
+	r := newReceiver()
+	now := time.Unix(100, 0)
+	r.now = func() time.Time { return now }
+	g := newGenerator(c, "rfc5424", "octet")
+	rng := rand.New(rand.NewSource(1))
+
+	deliver := func(conn int, seq int, sent time.Time) {
+		p := syslog.RFC5424.GetParser(g.message(rng, conn, seq, sent))
+		err := p.Parse()
+		r.Handle(p.Dump(), 0, err)
+	}
+	for _, seq := range []int{0, 1, 3, 2, 2, 5} {
+		deliver(0, seq, now.Add(-time.Duration(seq+1)*time.Millisecond))
+	}
+	deliver(1, 0, now.Add(-10*time.Millisecond))
+	r.Handle(format.LogParts{"message": "not tracked"}, 0, nil)
+
+	rep := r.report(0)
+	c.Check(rep.received, Equals, 8)
+	c.Check(rep.unique, Equals, 6)
+	c.Check(rep.duplicates, Equals, 1)
+	c.Check(rep.reordered, Equals, 1)
+	c.Check(rep.lost, Equals, 1) // 4
+	c.Check(rep.untracked, Equals, 1)
+	c.Check(rep.latency["p50"], Equals, 3*time.Millisecond)
+	c.Check(rep.latency["max"], Equals, 10*time.Millisecond)
+	c.Check(rep.String(), Matches, "(?s).*lost 1, duplicated 1, reordered 1.*latency p50 3ms .*")
+
+	c.Check(r.report(10).lost, Equals, 4)
+}
+
+func freeAddress(c *C, network string) string {
+	if network == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		c.Assert(err, IsNil)
+		defer conn.Close()
+		return conn.LocalAddr().String()
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// Writes a self-signed certificate and its key to dir
+func writeCertificate(c *C, dir string) (certFile string, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	c.Assert(err, IsNil)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "bench"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, template, template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	c.Assert(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600), IsNil)
+	return certFile, keyFile
+}
+
+func (s *BenchSuite) TestSendReceive(c *C) {
+	certFile, keyFile := writeCertificate(c, c.MkDir())
+	for _, network := range []string{"udp", "tcp", "tls"} {
+		addr := freeAddress(c, network)
+		r := newReceiver()
+		server := syslog.NewServer()
+		server.SetFormat(syslog.Automatic)
+		server.SetHandler(r)
+		c.Assert(listenOn(server, network+"://"+addr, certFile, keyFile), IsNil)
+		c.Assert(server.Boot(), IsNil)
+
+		report, err := send(&sendConfig{
+			network:   network,
+			addr:      addr,
+			tlsConfig: &tls.Config{InsecureSkipVerify: true},
+			conns:     2,
+			rate:      2000,
+			count:     51,
+			gen:       newGenerator(c, "rfc3164=1,rfc5424=1", "octet=1,newline=1"),
+		}, nil)
+		c.Assert(err, IsNil)
+		c.Check(report.sent, Equals, uint64(51))
+		c.Check(report.errors, Equals, uint64(0))
+		c.Check(report.elapsed >= 12*time.Millisecond, Equals, true, Commentf("%v", report.elapsed))
+
+		for start := time.Now(); r.Received() < 51 && time.Since(start) < 5*time.Second; {
+			time.Sleep(5 * time.Millisecond)
+		}
+		server.Kill()
+		server.Wait()
+		rep := r.report(51)
+		c.Check(rep.unique, Equals, 51, Commentf("%s", network))
+		c.Check(rep.lost, Equals, 0)
+		c.Check(rep.duplicates, Equals, 0)
+		c.Check(rep.parseErrors, Equals, 0)
+	}
+}
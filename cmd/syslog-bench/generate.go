@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// Kinds of messages and framings, sorted
+var (
+	kinds    = []string{"rfc3164", "rfc5424"}
+	framings = []string{"newline", "octet"}
+)
+
+// RFC5424 timestamps have up to 6 fractional digits
+const rfc5424TimestampLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+// The tracking fields embedded in the messages, read back by the receiver
+var trackingRegexp = regexp.MustCompile(`bench run=(\S+) conn=(\d+) seq=(\d+) ts=(\d+)`)
+
+// A weighted choice among names, e.g. from "rfc3164=1,rfc5424=3"
+type mix struct {
+	names   []string
+	weights []int
+	total   int
+}
+
+func parseMix(s string, allowed []string) (*mix, error) {
+	m := &mix{}
+	for _, item := range strings.Split(s, ",") {
+		name, weight := strings.TrimSpace(item), 1
+		if i := strings.IndexByte(name, '='); i >= 0 {
+			var err error
+			if weight, err = strconv.Atoi(name[i+1:]); err != nil || weight < 0 {
+				return nil, fmt.Errorf("invalid weight in %q", item)
+			}
+			name = name[:i]
+		}
+		if i := sort.SearchStrings(allowed, name); i == len(allowed) || allowed[i] != name {
+			return nil, fmt.Errorf("unknown name %q, expected one of %s", name, strings.Join(allowed, ", "))
+		}
+		m.names = append(m.names, name)
+		m.weights = append(m.weights, weight)
+		m.total += weight
+	}
+	if m.total == 0 {
+		return nil, fmt.Errorf("no weight in %q", s)
+	}
+	return m, nil
+}
+
+func (m *mix) pick(rng *rand.Rand) string {
+	n := rng.Intn(m.total)
+	for i, w := range m.weights {
+		if n < w {
+			return m.names[i]
+		}
+		n -= w
+	}
+	return m.names[len(m.names)-1]
+}
+
+// Generates the messages of a run
+type generator struct {
+	run      string
+	hostname string
+	size     int
+	kinds    *mix
+	framings *mix
+}
+
+// Returns the message of the given sequence number, sent by a connection at
+// now, padded up to the size of the generator
+func (g *generator) message(rng *rand.Rand, conn int, seq int, now time.Time) []byte {
+	text := fmt.Sprintf("bench run=%s conn=%d seq=%d ts=%d", g.run, conn, seq, now.UnixNano())
+	priority := 8*(16+rng.Intn(8)) + rng.Intn(8)
+
+	var msg string
+	switch g.kinds.pick(rng) {
+	case "rfc3164":
+		msg = fmt.Sprintf("<%d>%s %s bench[%d]: %s", priority, now.Format(time.Stamp), g.hostname, conn, text)
+	default:
+		msg = fmt.Sprintf(`<%d>1 %s %s bench %d - [bench@32473 run="%s" conn="%d" seq="%d"] %s`,
+			priority, now.Format(rfc5424TimestampLayout), g.hostname, conn, g.run, conn, seq, text)
+	}
+	if pad := g.size - len(msg); pad > 0 {
+		msg += " " + strings.Repeat("x", pad-1)
+	}
+	return []byte(msg)
+}
+
+// Frames a message for a stream connection
+func (g *generator) frame(rng *rand.Rand, dst []byte, msg []byte) []byte {
+	if g.framings.pick(rng) == "octet" {
+		return format.AppendOctetCounted(dst, msg)
+	}
+	dst = append(dst, msg...)
+	return append(dst, '\n')
+}
+
+// The tracking fields of a received message
+type tracking struct {
+	run  string
+	conn int
+	seq  int
+	sent time.Time
+}
+
+func parseTracking(text string) (tracking, bool) {
+	m := trackingRegexp.FindStringSubmatch(text)
+	if m == nil {
+		return tracking{}, false
+	}
+	conn, _ := strconv.Atoi(m[2])
+	seq, _ := strconv.Atoi(m[3])
+	ts, _ := strconv.ParseInt(m[4], 10, 64)
+	return tracking{run: m[1], conn: conn, seq: seq, sent: time.Unix(0, ts)}, true
+}
@@ -0,0 +1,221 @@
+// Command syslog-bench is a reproducible syslog traffic source, to size
+// collectors and validate server changes, along with a companion receiver.
+//
+// The send mode sends a mix of RFC3164 and RFC5424 messages, the latter with
+// structured data, over UDP, TCP, TLS or unixgram, framed with octet counting
+// or new lines over streams, at a target rate or as fast as possible, across
+// several connections:
+//
+//	syslog-bench send -network tcp -addr 127.0.0.1:601 -conns 4 -rate 10000 -duration 30s \
+//		-mix rfc3164=1,rfc5424=3 -framing octet=1,newline=1
+//
+// Every message embeds a run ID, its connection, a sequence number and its send
+// time, from which the receive mode, listening with a go-syslog server and the
+// automatic format, reports loss, duplication, reordering and end-to-end
+// latency percentiles. The clocks of both hosts must be in sync for the
+// latencies to be meaningful:
+//
+//	syslog-bench receive -listen udp://0.0.0.0:514,tcp://0.0.0.0:601 -idle 5s
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "send":
+		err = sendMain(os.Args[2:])
+	case "receive":
+		err = receiveMain(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "syslog-bench:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: syslog-bench send|receive [flags], see -h of each mode")
+	os.Exit(2)
+}
+
+// Returns a channel closed on SIGINT or SIGTERM
+func interrupted() <-chan struct{} {
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+	return stop
+}
+
+func sendMain(args []string) error {
+	flags := flag.NewFlagSet("send", flag.ExitOnError)
+	network := flags.String("network", "udp", "network: udp, tcp, tls or unixgram")
+	addr := flags.String("addr", "127.0.0.1:514", "address of the collector, or path of its socket")
+	conns := flags.Int("conns", 1, "number of connections")
+	rate := flags.Float64("rate", 0, "messages per second in total, as fast as possible if 0")
+	count := flags.Int("count", 0, "messages to send in total, unlimited if 0")
+	duration := flags.Duration("duration", 0, "time to send for, unlimited if 0")
+	mixFlag := flags.String("mix", "rfc3164=1,rfc5424=1", "weights of the message formats")
+	framingFlag := flags.String("framing", "octet=1", "weights of the framings over streams: octet and newline")
+	size := flags.Int("size", 0, "minimum size of the messages, padded up to it")
+	hostname := flags.String("hostname", "bench.example.com", "hostname of the messages")
+	run := flags.String("run", "", "run ID, random if empty")
+	seed := flags.Int64("seed", 1, "seed of the random choices, for reproducible runs")
+	ca := flags.String("ca", "", "CA certificates of the collector, for tls")
+	insecure := flags.Bool("insecure", false, "skip the verification of the collector certificate, for tls")
+	flags.Parse(args)
+
+	if *conns < 1 {
+		return fmt.Errorf("at least 1 connection needed")
+	}
+	if *count == 0 && *duration == 0 {
+		return fmt.Errorf("a count or a duration is needed")
+	}
+	kindMix, err := parseMix(*mixFlag, kinds)
+	if err != nil {
+		return fmt.Errorf("-mix: %v", err)
+	}
+	framingMix, err := parseMix(*framingFlag, framings)
+	if err != nil {
+		return fmt.Errorf("-framing: %v", err)
+	}
+	if *run == "" {
+		*run = strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+
+	c := &sendConfig{
+		network:  *network,
+		addr:     *addr,
+		conns:    *conns,
+		rate:     *rate,
+		count:    *count,
+		duration: *duration,
+		seed:     *seed,
+		gen: &generator{
+			run:      *run,
+			hostname: *hostname,
+			size:     *size,
+			kinds:    kindMix,
+			framings: framingMix,
+		},
+	}
+	if *network == "tls" {
+		c.tlsConfig = &tls.Config{InsecureSkipVerify: *insecure}
+		if *ca != "" {
+			pem, err := ioutil.ReadFile(*ca)
+			if err != nil {
+				return err
+			}
+			c.tlsConfig.RootCAs = x509.NewCertPool()
+			if !c.tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("no certificates in %s", *ca)
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "run %s\n", *run)
+	report, err := send(c, interrupted())
+	if err != nil {
+		return err
+	}
+	fmt.Println(report)
+	return nil
+}
+
+func receiveMain(args []string) error {
+	flags := flag.NewFlagSet("receive", flag.ExitOnError)
+	listen := flags.String("listen", "udp://127.0.0.1:514", "comma separated listeners: udp://, tcp://, tls:// or unixgram:// followed by an address")
+	cert := flags.String("cert", "", "certificate, for tls")
+	key := flags.String("key", "", "key of the certificate, for tls")
+	expect := flags.Int("expect", 0, "messages expected, to count the lost ones at the end of the run")
+	idle := flags.Duration("idle", 0, "stop once no message was received for this time, after the first one")
+	duration := flags.Duration("duration", 0, "time to receive for, until interrupted if 0")
+	flags.Parse(args)
+
+	r := newReceiver()
+	server := syslog.NewServer()
+	server.SetFormat(syslog.Automatic)
+	server.SetHandler(r)
+	for _, l := range strings.Split(*listen, ",") {
+		if err := listenOn(server, strings.TrimSpace(l), *cert, *key); err != nil {
+			return err
+		}
+	}
+	if err := server.Boot(); err != nil {
+		return err
+	}
+
+	stop := interrupted()
+	var deadline <-chan time.Time
+	if *duration > 0 {
+		deadline = time.After(*duration)
+	}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+wait:
+	for {
+		select {
+		case <-stop:
+			break wait
+		case <-deadline:
+			break wait
+		case <-ticker.C:
+			if *idle > 0 && r.Idle() >= *idle {
+				break wait
+			}
+		}
+	}
+	server.Kill()
+	server.Wait()
+
+	fmt.Println(r.report(*expect))
+	return nil
+}
+
+func listenOn(server *syslog.Server, listener string, cert string, key string) error {
+	i := strings.Index(listener, "://")
+	if i < 0 {
+		return fmt.Errorf("invalid listener %q", listener)
+	}
+	addr := listener[i+3:]
+	switch listener[:i] {
+	case "udp":
+		return server.ListenUDP(addr)
+	case "tcp":
+		return server.ListenTCP(addr)
+	case "unixgram":
+		return server.ListenUnixgram(addr)
+	case "tls":
+		certificate, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return err
+		}
+		// The clients are not asked for a certificate to name them after
+		server.SetTlsPeerNameFunc(nil)
+		return server.ListenTCPTLS(addr, &tls.Config{Certificates: []tls.Certificate{certificate}})
+	}
+	return fmt.Errorf("invalid listener %q", listener)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// Latencies kept for the percentiles, sampled beyond
+const latencySamplesMax = 1 << 20
+
+// Sequence numbers received on a connection of a run
+type connTracking struct {
+	seen      []uint64 // bitset of the sequence numbers
+	unique    int
+	maxSeq    int
+	reordered int
+}
+
+// Receiver collects the tracking fields of the messages, and reports loss,
+// duplication, reordering and latency
+type receiver struct {
+	mu          sync.Mutex
+	conns       map[string]*connTracking // by run and connection
+	received    int
+	duplicates  int
+	untracked   int
+	parseErrors int
+	latencies   []time.Duration
+	latencyN    int // latencies observed, sampled or not
+	rng         *rand.Rand
+	first       time.Time
+	last        time.Time
+	now         func() time.Time
+}
+
+func newReceiver() *receiver {
+	return &receiver{
+		conns: make(map[string]*connTracking),
+		rng:   rand.New(rand.NewSource(1)),
+		now:   time.Now,
+	}
+}
+
+func (r *receiver) Handle(logParts format.LogParts, messageLength int64, err error) {
+	now := r.now()
+	text, _ := logParts["message"].(string)
+	if text == "" {
+		text, _ = logParts["content"].(string)
+	}
+	t, ok := parseTracking(text)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.received == 0 {
+		r.first = now
+	}
+	r.last = now
+	r.received++
+	if err != nil {
+		r.parseErrors++
+	}
+	if !ok {
+		r.untracked++
+		return
+	}
+
+	key := fmt.Sprintf("%s/%d", t.run, t.conn)
+	c := r.conns[key]
+	if c == nil {
+		c = &connTracking{maxSeq: -1}
+		r.conns[key] = c
+	}
+	word, bit := t.seq/64, uint64(1)<<uint(t.seq%64)
+	for len(c.seen) <= word {
+		c.seen = append(c.seen, 0)
+	}
+	if c.seen[word]&bit != 0 {
+		r.duplicates++
+		return
+	}
+	c.seen[word] |= bit
+	c.unique++
+	if t.seq < c.maxSeq {
+		c.reordered++
+	} else {
+		c.maxSeq = t.seq
+	}
+
+	// Reservoir sampling of the latencies
+	latency := now.Sub(t.sent)
+	r.latencyN++
+	if len(r.latencies) < latencySamplesMax {
+		r.latencies = append(r.latencies, latency)
+	} else if i := r.rng.Intn(r.latencyN); i < latencySamplesMax {
+		r.latencies[i] = latency
+	}
+}
+
+// Received returns the number of messages received
+func (r *receiver) Received() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.received
+}
+
+// Idle returns the time since the last message, 0 if none was received
+func (r *receiver) Idle() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.received == 0 {
+		return 0
+	}
+	return r.now().Sub(r.last)
+}
+
+// Result of the receive mode
+type receiveReport struct {
+	received    int
+	unique      int
+	duplicates  int
+	reordered   int
+	lost        int
+	untracked   int
+	parseErrors int
+	elapsed     time.Duration
+	latency     map[string]time.Duration // by percentile, e.g. p99
+}
+
+var percentiles = []struct {
+	name string
+	q    float64
+}{{"p50", 0.5}, {"p90", 0.9}, {"p99", 0.99}, {"p99.9", 0.999}, {"max", 1}}
+
+// Returns the report, the lost messages being those missing below the
+// highest sequence number of each connection, or from expected if not 0
+func (r *receiver) report(expected int) *receiveReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rep := &receiveReport{
+		received:    r.received,
+		duplicates:  r.duplicates,
+		untracked:   r.untracked,
+		parseErrors: r.parseErrors,
+		elapsed:     r.last.Sub(r.first),
+		latency:     make(map[string]time.Duration),
+	}
+	for _, c := range r.conns {
+		rep.unique += c.unique
+		rep.reordered += c.reordered
+		rep.lost += c.maxSeq + 1 - c.unique
+	}
+	if expected > 0 {
+		rep.lost = expected - rep.unique
+	}
+
+	if len(r.latencies) > 0 {
+		sorted := make([]time.Duration, len(r.latencies))
+		copy(sorted, r.latencies)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		for _, p := range percentiles {
+			rep.latency[p.name] = sorted[int(p.q*float64(len(sorted)-1))]
+		}
+	}
+	return rep
+}
+
+func (r *receiveReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "received %d messages in %v", r.received, r.elapsed.Round(time.Millisecond))
+	if seconds := r.elapsed.Seconds(); seconds > 0 {
+		fmt.Fprintf(&b, ": %.0f msg/s", float64(r.received)/seconds)
+	}
+	fmt.Fprintf(&b, "\nunique %d, lost %d, duplicated %d, reordered %d, untracked %d, parse errors %d",
+		r.unique, r.lost, r.duplicates, r.reordered, r.untracked, r.parseErrors)
+	if len(r.latency) > 0 {
+		b.WriteString("\nlatency")
+		for _, p := range percentiles {
+			fmt.Fprintf(&b, " %s %v", p.name, r.latency[p.name].Round(time.Microsecond))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Settings of the send mode
+type sendConfig struct {
+	network   string // udp, tcp, tls or unixgram
+	addr      string
+	tlsConfig *tls.Config
+	conns     int
+	rate      float64 // messages per second, as fast as possible if 0
+	count     int     // messages in total, unlimited if 0
+	duration  time.Duration
+	seed      int64
+	gen       *generator
+}
+
+// Result of the send mode
+type sendReport struct {
+	sent    uint64
+	bytes   uint64
+	errors  uint64
+	elapsed time.Duration
+}
+
+func (r *sendReport) String() string {
+	seconds := r.elapsed.Seconds()
+	return fmt.Sprintf("sent %d messages, %d bytes in %v: %.0f msg/s, %.2f MB/s, %d errors",
+		r.sent, r.bytes, r.elapsed.Round(time.Millisecond),
+		float64(r.sent)/seconds, float64(r.bytes)/seconds/1e6, r.errors)
+}
+
+func (c *sendConfig) stream() bool {
+	return c.network == "tcp" || c.network == "tls"
+}
+
+func (c *sendConfig) dial() (net.Conn, error) {
+	switch c.network {
+	case "tls":
+		return tls.Dial("tcp", c.addr, c.tlsConfig)
+	case "udp", "tcp", "unixgram":
+		return net.Dial(c.network, c.addr)
+	}
+	return nil, fmt.Errorf("unknown network %q", c.network)
+}
+
+// Sends the messages over the connections, until the count is reached, the
+// duration elapsed or stop closed
+func send(c *sendConfig, stop <-chan struct{}) (*sendReport, error) {
+	conns := make([]net.Conn, c.conns)
+	for i := range conns {
+		conn, err := c.dial()
+		if err != nil {
+			for _, conn := range conns[:i] {
+				conn.Close()
+			}
+			return nil, err
+		}
+		conns[i] = conn
+	}
+
+	report := &sendReport{}
+	start := time.Now()
+	var deadline time.Time
+	if c.duration > 0 {
+		deadline = start.Add(c.duration)
+	}
+
+	var wait sync.WaitGroup
+	for i, conn := range conns {
+		// The count and the rate are shared among the connections
+		count := 0
+		if c.count > 0 {
+			count = c.count / c.conns
+			if i < c.count%c.conns {
+				count++
+			}
+		}
+		var interval time.Duration
+		if c.rate > 0 {
+			interval = time.Duration(float64(time.Second) * float64(c.conns) / c.rate)
+		}
+
+		wait.Add(1)
+		go func(i int, conn net.Conn) {
+			defer wait.Done()
+			defer conn.Close()
+			c.sendConn(i, conn, count, interval, start, deadline, stop, report)
+		}(i, conn)
+	}
+	wait.Wait()
+
+	report.elapsed = time.Since(start)
+	return report, nil
+}
+
+func (c *sendConfig) sendConn(i int, conn net.Conn, count int, interval time.Duration, start time.Time, deadline time.Time, stop <-chan struct{}, report *sendReport) {
+	rng := rand.New(rand.NewSource(c.seed + int64(i)))
+	var w io.Writer = conn
+	var bw *bufio.Writer
+	if c.stream() {
+		bw = bufio.NewWriterSize(conn, 64*1024)
+		w = bw
+	}
+
+	var buf []byte
+loop:
+	for seq := 0; count == 0 || seq < count; seq++ {
+		select {
+		case <-stop:
+			break loop
+		default:
+		}
+
+		now := time.Now()
+		if !deadline.IsZero() && now.After(deadline) {
+			break
+		}
+		if interval > 0 {
+			next := start.Add(time.Duration(seq) * interval)
+			if d := next.Sub(now); d > 0 {
+				// Nothing more to send before a while
+				if bw != nil && bw.Buffered() > 0 {
+					bw.Flush()
+				}
+				time.Sleep(d)
+				now = time.Now()
+			}
+		}
+
+		msg := c.gen.message(rng, i, seq, now)
+		if c.stream() {
+			buf = c.gen.frame(rng, buf[:0], msg)
+		} else {
+			buf = msg
+		}
+		if _, err := w.Write(buf); err != nil {
+			atomic.AddUint64(&report.errors, 1)
+			if c.stream() {
+				// The connection is lost
+				return
+			}
+			continue
+		}
+		atomic.AddUint64(&report.sent, 1)
+		atomic.AddUint64(&report.bytes, uint64(len(buf)))
+	}
+	if bw != nil {
+		if err := bw.Flush(); err != nil {
+			atomic.AddUint64(&report.errors, 1)
+		}
+	}
+}
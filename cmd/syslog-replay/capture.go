@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Magic numbers of the capture files
+const (
+	pcapMagicMicro   = 0xa1b2c3d4
+	pcapMagicNano    = 0xa1b23c4d
+	pcapngBlockSHB   = 0x0a0d0d0a
+	pcapngByteOrder  = 0x1a2b3c4d
+	pcapngBlockIDB   = 0x00000001
+	pcapngBlockSPB   = 0x00000003
+	pcapngBlockEPB   = 0x00000006
+	captureMaxPacket = 256 * 1024
+)
+
+var errCaptureFormat = errors.New("not a pcap or pcapng capture")
+
+// A packet read from a capture, with the link type of its interface
+type packet struct {
+	data     []byte
+	linkType uint32
+}
+
+type captureReader interface {
+	next() (packet, error)
+}
+
+// Tells whether the given first bytes of a file are those of a capture
+func isCapture(magic []byte) bool {
+	if len(magic) < 4 {
+		return false
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(magic) {
+		case pcapMagicMicro, pcapMagicNano, pcapngBlockSHB:
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a reader of the packets of a pcap or pcapng capture
+func newCaptureReader(r *bufio.Reader) (captureReader, error) {
+	magic, err := r.Peek(4)
+	if err != nil {
+		return nil, errCaptureFormat
+	}
+	if binary.LittleEndian.Uint32(magic) == pcapngBlockSHB {
+		return &pcapngReader{r: r}, nil
+	}
+
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(header) {
+		case pcapMagicMicro, pcapMagicNano:
+			return &pcapReader{r: r, order: order, linkType: order.Uint32(header[20:]) & 0xffff}, nil
+		}
+	}
+	return nil, errCaptureFormat
+}
+
+// Reader of the classic pcap format
+type pcapReader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	linkType uint32
+}
+
+func (p *pcapReader) next() (packet, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(p.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return packet{}, fmt.Errorf("truncated pcap record header")
+		}
+		return packet{}, err
+	}
+	length := p.order.Uint32(header[8:])
+	if length > captureMaxPacket {
+		return packet{}, fmt.Errorf("pcap record too large: %d bytes", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return packet{}, fmt.Errorf("truncated pcap record: %v", err)
+	}
+	return packet{data: data, linkType: p.linkType}, nil
+}
+
+// Reader of the pcapng format, reading the packets of the enhanced and simple
+// packet blocks and skipping the other blocks
+type pcapngReader struct {
+	r          io.Reader
+	order      binary.ByteOrder
+	interfaces []uint32 // link types, by interface ID
+}
+
+func (p *pcapngReader) next() (packet, error) {
+	for {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(p.r, header); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return packet{}, fmt.Errorf("truncated pcapng block header")
+			}
+			return packet{}, err
+		}
+
+		blockType := binary.LittleEndian.Uint32(header)
+		if blockType == pcapngBlockSHB {
+			// The byte order is given by the section header, and the
+			// interfaces are per section
+			order := make([]byte, 4)
+			if _, err := io.ReadFull(p.r, order); err != nil {
+				return packet{}, fmt.Errorf("truncated pcapng section header")
+			}
+			switch {
+			case binary.LittleEndian.Uint32(order) == pcapngByteOrder:
+				p.order = binary.LittleEndian
+			case binary.BigEndian.Uint32(order) == pcapngByteOrder:
+				p.order = binary.BigEndian
+			default:
+				return packet{}, errCaptureFormat
+			}
+			p.interfaces = nil
+			if err := p.skip(p.order.Uint32(header[4:]), 12); err != nil {
+				return packet{}, err
+			}
+			continue
+		}
+		if p.order == nil {
+			return packet{}, errCaptureFormat
+		}
+
+		blockType = p.order.Uint32(header)
+		length := p.order.Uint32(header[4:])
+		if length < 12 || length%4 != 0 || length > captureMaxPacket {
+			return packet{}, fmt.Errorf("invalid pcapng block length %d", length)
+		}
+		body := make([]byte, length-8)
+		if _, err := io.ReadFull(p.r, body); err != nil {
+			return packet{}, fmt.Errorf("truncated pcapng block: %v", err)
+		}
+		body = body[:len(body)-4] // trailing length
+
+		switch blockType {
+		case pcapngBlockIDB:
+			if len(body) < 8 {
+				return packet{}, fmt.Errorf("truncated pcapng interface block")
+			}
+			p.interfaces = append(p.interfaces, uint32(p.order.Uint16(body)))
+		case pcapngBlockEPB:
+			if len(body) < 20 {
+				return packet{}, fmt.Errorf("truncated pcapng packet block")
+			}
+			id := p.order.Uint32(body)
+			captured := p.order.Uint32(body[12:])
+			if int(id) >= len(p.interfaces) || int(captured) > len(body)-20 {
+				return packet{}, fmt.Errorf("invalid pcapng packet block")
+			}
+			return packet{data: body[20 : 20+captured], linkType: p.interfaces[id]}, nil
+		case pcapngBlockSPB:
+			if len(body) < 4 || len(p.interfaces) == 0 {
+				return packet{}, fmt.Errorf("invalid pcapng simple packet block")
+			}
+			captured := p.order.Uint32(body)
+			if int(captured) > len(body)-4 {
+				captured = uint32(len(body) - 4)
+			}
+			return packet{data: body[4 : 4+captured], linkType: p.interfaces[0]}, nil
+		}
+	}
+}
+
+// Skips the rest of a block, of which read bytes were read
+func (p *pcapngReader) skip(length uint32, read uint32) error {
+	if length < read || length%4 != 0 || length > captureMaxPacket {
+		return fmt.Errorf("invalid pcapng block length %d", length)
+	}
+	_, err := io.CopyN(ioutil.Discard, p.r, int64(length-read))
+	if err != nil {
+		return fmt.Errorf("truncated pcapng block: %v", err)
+	}
+	return nil
+}
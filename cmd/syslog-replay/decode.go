@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+)
+
+// Link types, as in pcap
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLoop     = 108
+	linkTypeLinuxSLL = 113
+	linkTypeIPv4     = 228
+	linkTypeIPv6     = 229
+	linkTypeSLL2     = 276
+)
+
+const (
+	protocolTCP = 6
+	protocolUDP = 17
+
+	tcpFlagFIN = 0x01
+	tcpFlagSYN = 0x02
+	tcpFlagRST = 0x04
+)
+
+// A UDP datagram or TCP segment of a packet
+type segment struct {
+	tcp     bool
+	src     string // host:port
+	dst     string
+	srcPort int
+	dstPort int
+	seq     uint32
+	flags   byte
+	payload []byte
+}
+
+// Decodes the UDP datagram or TCP segment of a packet, ok being false for the
+// other packets and the fragments of IP packets
+func decodePacket(p packet) (s segment, ok bool) {
+	ip, ok := linkPayload(p.data, p.linkType)
+	if !ok || len(ip) < 1 {
+		return s, false
+	}
+
+	var srcIP, dstIP net.IP
+	var protocol byte
+	var transport []byte
+	switch ip[0] >> 4 {
+	case 4:
+		if len(ip) < 20 {
+			return s, false
+		}
+		headerLength := int(ip[0]&0x0f) * 4
+		totalLength := int(binary.BigEndian.Uint16(ip[2:]))
+		fragment := binary.BigEndian.Uint16(ip[6:])
+		if headerLength < 20 || totalLength < headerLength || len(ip) < totalLength || fragment&0x3fff != 0 {
+			return s, false
+		}
+		srcIP, dstIP = net.IP(ip[12:16]), net.IP(ip[16:20])
+		protocol = ip[9]
+		transport = ip[headerLength:totalLength]
+	case 6:
+		if len(ip) < 40 {
+			return s, false
+		}
+		payloadLength := int(binary.BigEndian.Uint16(ip[4:]))
+		if len(ip) < 40+payloadLength {
+			return s, false
+		}
+		srcIP, dstIP = net.IP(ip[8:24]), net.IP(ip[24:40])
+		protocol = ip[6]
+		transport = ip[40 : 40+payloadLength]
+		// Hop-by-hop, routing and destination options headers
+		for protocol == 0 || protocol == 43 || protocol == 60 {
+			if len(transport) < 8 {
+				return s, false
+			}
+			length := (int(transport[1]) + 1) * 8
+			if len(transport) < length {
+				return s, false
+			}
+			protocol = transport[0]
+			transport = transport[length:]
+		}
+	default:
+		return s, false
+	}
+
+	switch protocol {
+	case protocolUDP:
+		if len(transport) < 8 {
+			return s, false
+		}
+		length := int(binary.BigEndian.Uint16(transport[4:]))
+		if length < 8 || length > len(transport) {
+			length = len(transport)
+		}
+		s.payload = transport[8:length]
+	case protocolTCP:
+		if len(transport) < 20 {
+			return s, false
+		}
+		offset := int(transport[12]>>4) * 4
+		if offset < 20 || offset > len(transport) {
+			return s, false
+		}
+		s.tcp = true
+		s.seq = binary.BigEndian.Uint32(transport[4:])
+		s.flags = transport[13]
+		s.payload = transport[offset:]
+	default:
+		return s, false
+	}
+
+	s.srcPort = int(binary.BigEndian.Uint16(transport))
+	s.dstPort = int(binary.BigEndian.Uint16(transport[2:]))
+	s.src = net.JoinHostPort(srcIP.String(), strconv.Itoa(s.srcPort))
+	s.dst = net.JoinHostPort(dstIP.String(), strconv.Itoa(s.dstPort))
+	return s, true
+}
+
+// Returns the IP packet of a frame
+func linkPayload(data []byte, linkType uint32) ([]byte, bool) {
+	switch linkType {
+	case linkTypeEthernet:
+		if len(data) < 14 {
+			return nil, false
+		}
+		etherType := binary.BigEndian.Uint16(data[12:])
+		data = data[14:]
+		// VLAN tags
+		for etherType == 0x8100 || etherType == 0x88a8 {
+			if len(data) < 4 {
+				return nil, false
+			}
+			etherType = binary.BigEndian.Uint16(data[2:])
+			data = data[4:]
+		}
+		if etherType != 0x0800 && etherType != 0x86dd {
+			return nil, false
+		}
+		return data, true
+	case linkTypeNull, linkTypeLoop:
+		if len(data) < 4 {
+			return nil, false
+		}
+		return data[4:], true
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		return data, true
+	case linkTypeLinuxSLL:
+		if len(data) < 16 {
+			return nil, false
+		}
+		return data[16:], true
+	case linkTypeSLL2:
+		if len(data) < 20 {
+			return nil, false
+		}
+		return data[20:], true
+	}
+	return nil, false
+}
+
+// A TCP stream, in one direction, reassembled from its segments
+type tcpStream struct {
+	src     string
+	dst     string
+	started bool
+	next    uint32            // sequence number of the next byte expected
+	pending map[uint32][]byte // segments received out of order
+	data    []byte
+}
+
+func newTCPStream(src string, dst string) *tcpStream {
+	return &tcpStream{src: src, dst: dst, pending: make(map[uint32][]byte)}
+}
+
+// Adds a segment to the stream, ignoring the bytes already received
+func (t *tcpStream) add(s segment) {
+	seq := s.seq
+	if s.flags&tcpFlagSYN != 0 {
+		seq++
+		if !t.started {
+			t.started = true
+			t.next = seq
+		}
+	}
+	if len(s.payload) == 0 {
+		return
+	}
+	if !t.started {
+		// Capture started in the middle of the stream
+		t.started = true
+		t.next = seq
+	}
+
+	t.pending[seq] = s.payload
+	for len(t.pending) > 0 {
+		progress := false
+		for seq, payload := range t.pending {
+			delta := int32(seq - t.next)
+			if delta > 0 {
+				continue
+			}
+			delete(t.pending, seq)
+			progress = true
+			if int(-delta) < len(payload) {
+				payload = payload[-delta:]
+				t.data = append(t.data, payload...)
+				t.next += uint32(len(payload))
+			}
+		}
+		if !progress {
+			break
+		}
+	}
+}
@@ -0,0 +1,329 @@
+// Command syslog-replay runs captured syslog traffic through the parsers of
+// go-syslog, and prints the parsed messages as JSON, one per line, along with
+// the parse errors and the detected format. It helps reproducing the parse of
+// odd messages offline, and doubles as a regression harness for the parsers,
+// its output being stable for a given input.
+//
+// Usage:
+//
+//	syslog-replay [flags] [file ...]
+//
+// The files, or the standard input when there are none or for "-", can be:
+//
+//	lines      messages separated by new lines
+//	octets     messages framed with octet counting, as in RFC6587
+//	datagram   a single datagram, as received over UDP, split in its messages
+//	           as the server does with the datagram flags
+//	pcap       pcap or pcapng captures, the UDP datagrams and reassembled TCP
+//	           streams of the given ports being replayed, TLS excepted
+//	auto       pcap if the file is a capture, else the framing of the format
+//	           as over TCP, the default
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+var formats = map[string]format.Format{
+	"rfc3164":   &format.RFC3164{},
+	"rfc5424":   &format.RFC5424{},
+	"rfc6587":   &format.RFC6587{},
+	"cisco":     &format.Cisco{},
+	"automatic": &format.Automatic{},
+}
+
+// The output for a message
+type result struct {
+	Source  string          `json:"source"`
+	Client  string          `json:"client,omitempty"`
+	Format  string          `json:"format"`
+	Message string          `json:"message"`
+	Error   string          `json:"error,omitempty"`
+	Parts   format.LogParts `json:"parts"`
+}
+
+var errTLSStream = errors.New("TLS stream, not replayed")
+
+type replayer struct {
+	formatName      string
+	format          format.Format
+	input           string
+	ports           map[int]bool
+	location        *time.Location
+	datagramOptions syslog.DatagramOptions
+	out             *json.Encoder
+	messages        int
+	errors          int
+}
+
+func main() {
+	formatName := flag.String("format", "automatic", "format of the messages: rfc3164, rfc5424, rfc6587, cisco or automatic")
+	input := flag.String("input", "auto", "type of the files: auto, lines, octets, datagram or pcap")
+	ports := flag.String("ports", "514,601,6514", "ports of the syslog traffic in the captures, comma separated")
+	location := flag.String("location", "", "time zone of the timestamps without one, e.g. Europe/Paris, local time if empty")
+	fail := flag.Bool("fail", false, "exit with status 1 if a message could not be parsed")
+	multipleFrames := flag.Bool("multiple-frames", false, "handle all the frames of the datagrams, not only a single message")
+	maxDatagramSize := flag.Int("max-datagram-size", 0, "size of the largest datagram read, longer ones being truncated, 65536 if 0")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [file ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	r, err := newReplayer(*formatName, *input, *ports, *location, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "syslog-replay:", err)
+		os.Exit(2)
+	}
+	r.datagramOptions = syslog.DatagramOptions{MultipleFrames: *multipleFrames, MaxSize: *maxDatagramSize}
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	status := 0
+	for _, name := range files {
+		if err := r.replayFile(name); err != nil {
+			fmt.Fprintf(os.Stderr, "syslog-replay: %s: %v\n", name, err)
+			status = 1
+		}
+	}
+	if *fail && r.errors > 0 {
+		fmt.Fprintf(os.Stderr, "syslog-replay: %d of %d messages could not be parsed\n", r.errors, r.messages)
+		status = 1
+	}
+	os.Exit(status)
+}
+
+func newReplayer(formatName string, input string, ports string, location string, out io.Writer) (*replayer, error) {
+	r := &replayer{
+		formatName: strings.ToLower(formatName),
+		input:      input,
+		ports:      make(map[int]bool),
+		out:        json.NewEncoder(out),
+	}
+
+	var ok bool
+	if r.format, ok = formats[r.formatName]; !ok {
+		return nil, fmt.Errorf("unknown format %q", formatName)
+	}
+	switch input {
+	case "auto", "lines", "octets", "datagram", "pcap":
+	default:
+		return nil, fmt.Errorf("unknown input %q", input)
+	}
+	for _, p := range strings.Split(ports, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		port, err := strconv.Atoi(p)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %q", p)
+		}
+		r.ports[port] = true
+	}
+	if location != "" {
+		loc, err := time.LoadLocation(location)
+		if err != nil {
+			return nil, err
+		}
+		r.location = loc
+	}
+	return r, nil
+}
+
+func (r *replayer) replayFile(name string) error {
+	var f io.Reader = os.Stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		f = file
+	}
+	br := bufio.NewReader(f)
+
+	input := r.input
+	if input == "auto" {
+		if magic, _ := br.Peek(4); isCapture(magic) {
+			input = "pcap"
+		}
+	}
+
+	switch input {
+	case "pcap":
+		return r.replayCapture(br, name)
+	case "datagram":
+		data, err := ioutil.ReadAll(br)
+		if err != nil {
+			return err
+		}
+		r.replayDatagram(data, name, "")
+		return nil
+	}
+	return r.replayStream(br, name, "", input)
+}
+
+// Splits a stream as the server does over TCP, unless the input tells the
+// framing
+func (r *replayer) replayStream(f io.Reader, source string, client string, input string) error {
+	scanner := bufio.NewScanner(f)
+	switch input {
+	case "lines":
+		scanner.Split(bufio.ScanLines)
+	case "octets":
+		scanner.Split((&format.RFC6587{}).GetSplitFunc())
+	default:
+		if sf := r.format.GetSplitFunc(); sf != nil {
+			scanner.Split(sf)
+		}
+	}
+
+	n := 0
+	for scanner.Scan() {
+		n++
+		r.emit(source+"#"+strconv.Itoa(n), client, scanner.Bytes())
+	}
+	return scanner.Err()
+}
+
+// Parses a datagram as the server does over UDP
+func (r *replayer) replayDatagram(data []byte, source string, client string) {
+	frames := syslog.SplitDatagram(r.format, data, r.datagramOptions)
+	for i, frame := range frames {
+		frameSource := source
+		if len(frames) > 1 {
+			frameSource += "#" + strconv.Itoa(i+1)
+		}
+		r.emitFrame(frameSource, client, frame)
+	}
+}
+
+// Replays the UDP datagrams as they come, and the TCP streams when they end
+func (r *replayer) replayCapture(br *bufio.Reader, name string) error {
+	reader, err := newCaptureReader(br)
+	if err != nil {
+		return err
+	}
+
+	streams := make(map[string]*tcpStream)
+	var order []string
+	flush := func(key string) error {
+		t := streams[key]
+		delete(streams, key)
+		source := name + ":" + t.src + ">" + t.dst
+		if len(t.data) >= 2 && t.data[0] == 0x16 && t.data[1] == 0x03 {
+			r.emitError(source, t.src, nil, errTLSStream)
+			return nil
+		}
+		return r.replayStream(bytes.NewReader(t.data), source, t.src, r.input)
+	}
+
+	n := 0
+	for {
+		p, err := reader.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		n++
+
+		s, ok := decodePacket(p)
+		if !ok || (!r.ports[s.dstPort] && !r.ports[s.srcPort]) {
+			continue
+		}
+		if !s.tcp {
+			r.replayDatagram(s.payload, name+":"+strconv.Itoa(n), s.src)
+			continue
+		}
+
+		key := s.src + ">" + s.dst
+		t, ok := streams[key]
+		if !ok {
+			t = newTCPStream(s.src, s.dst)
+			streams[key] = t
+			order = append(order, key)
+		}
+		t.add(s)
+		if s.flags&(tcpFlagFIN|tcpFlagRST) != 0 {
+			if err := flush(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, key := range order {
+		if _, ok := streams[key]; ok {
+			if err := flush(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *replayer) emit(source string, client string, line []byte) {
+	r.emitFrame(source, client, syslog.DatagramFrame{Message: line})
+}
+
+// Emits a message, with the fields the server adds to the frames of datagrams
+func (r *replayer) emitFrame(source string, client string, frame syslog.DatagramFrame) {
+	line := frame.Message
+	parser := r.format.GetParser(line)
+	if r.location != nil {
+		parser.Location(r.location)
+	}
+	err := parser.Parse()
+	logParts := parser.Dump()
+	if frame.Truncated {
+		logParts["truncated"] = true
+	}
+	if frame.ExtraFrames {
+		logParts["extra_frames"] = true
+	}
+
+	res := result{
+		Source:  source,
+		Client:  client,
+		Format:  r.formatName,
+		Message: string(line),
+		Parts:   logParts,
+	}
+	if r.formatName == "automatic" {
+		res.Format = format.DetectFormat(line)
+	}
+	if err != nil {
+		res.Error = err.Error()
+		r.errors++
+	}
+	r.messages++
+	r.out.Encode(res)
+}
+
+func (r *replayer) emitError(source string, client string, line []byte, err error) {
+	r.messages++
+	r.errors++
+	r.out.Encode(result{
+		Source:  source,
+		Client:  client,
+		Format:  r.formatName,
+		Message: string(line),
+		Error:   err.Error(),
+	})
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ReplaySuite struct{}
+
+var _ = Suite(&ReplaySuite{})
+
+const (
+	rfc5424Message = "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed for lonvick on /dev/pts/8"
+	rfc3164Message = "<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8"
+)
+
+// Builds an IPv4 packet carrying a UDP datagram or a TCP segment
+func ipv4Packet(protocol byte, src [4]byte, dst [4]byte, srcPort uint16, dstPort uint16, seq uint32, flags byte, payload []byte) []byte {
+	var transport []byte
+	if protocol == protocolUDP {
+		transport = make([]byte, 8)
+		binary.BigEndian.PutUint16(transport[4:], uint16(8+len(payload)))
+	} else {
+		transport = make([]byte, 20)
+		binary.BigEndian.PutUint32(transport[4:], seq)
+		transport[12] = 5 << 4
+		transport[13] = flags
+	}
+	binary.BigEndian.PutUint16(transport, srcPort)
+	binary.BigEndian.PutUint16(transport[2:], dstPort)
+	transport = append(transport, payload...)
+
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+len(transport)))
+	ip[8] = 64
+	ip[9] = protocol
+	copy(ip[12:], src[:])
+	copy(ip[16:], dst[:])
+	return append(ip, transport...)
+}
+
+// Wraps an IP packet in an Ethernet frame
+func ethernetFrame(ip []byte) []byte {
+	frame := make([]byte, 14)
+	binary.BigEndian.PutUint16(frame[12:], 0x0800)
+	return append(frame, ip...)
+}
+
+func pcapFile(frames ...[]byte) []byte {
+	var b bytes.Buffer
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header, pcapMagicMicro)
+	binary.LittleEndian.PutUint16(header[4:], 2)
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	binary.LittleEndian.PutUint32(header[16:], 65535)
+	binary.LittleEndian.PutUint32(header[20:], linkTypeEthernet)
+	b.Write(header)
+	for _, frame := range frames {
+		record := make([]byte, 16)
+		binary.LittleEndian.PutUint32(record[8:], uint32(len(frame)))
+		binary.LittleEndian.PutUint32(record[12:], uint32(len(frame)))
+		b.Write(record)
+		b.Write(frame)
+	}
+	return b.Bytes()
+}
+
+func pcapngBlock(blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	block := make([]byte, 8, 12+len(body))
+	binary.BigEndian.PutUint32(block, blockType)
+	binary.BigEndian.PutUint32(block[4:], uint32(12+len(body)))
+	block = append(block, body...)
+	return append(block, block[4:8]...)
+}
+
+func pcapngFile(linkType uint16, packets ...[]byte) []byte {
+	var b bytes.Buffer
+	shb := make([]byte, 16)
+	binary.BigEndian.PutUint32(shb, pcapngByteOrder)
+	binary.BigEndian.PutUint16(shb[4:], 1)
+	binary.BigEndian.PutUint64(shb[8:], ^uint64(0))
+	b.Write(pcapngBlock(pcapngBlockSHB, shb))
+	idb := make([]byte, 8)
+	binary.BigEndian.PutUint16(idb, linkType)
+	b.Write(pcapngBlock(pcapngBlockIDB, idb))
+	// Name resolution block, skipped
+	b.Write(pcapngBlock(4, make([]byte, 4)))
+	for _, p := range packets {
+		epb := make([]byte, 20)
+		binary.BigEndian.PutUint32(epb[12:], uint32(len(p)))
+		binary.BigEndian.PutUint32(epb[16:], uint32(len(p)))
+		b.Write(pcapngBlock(pcapngBlockEPB, append(epb, p...)))
+	}
+	return b.Bytes()
+}
+
+func replay(c *C, formatName string, input string, data []byte) []result {
+	return replayDatagrams(c, formatName, input, data, syslog.DatagramOptions{})
+}
+
+func replayDatagrams(c *C, formatName string, input string, data []byte, options syslog.DatagramOptions) []result {
+	dir := c.MkDir()
+	name := filepath.Join(dir, "input")
+	c.Assert(ioutil.WriteFile(name, data, 0644), IsNil)
+
+	var out bytes.Buffer
+	r, err := newReplayer(formatName, input, "514,601", "UTC", &out)
+	c.Assert(err, IsNil)
+	r.datagramOptions = options
+	c.Assert(r.replayFile(name), IsNil)
+
+	results := []result{}
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var res result
+		c.Assert(decoder.Decode(&res), IsNil)
+		res.Source = strings.TrimPrefix(res.Source, name)
+		results = append(results, res)
+	}
+	return results
+}
+
+func (s *ReplaySuite) TestLines(c *C) {
+	results := replay(c, "automatic", "auto", []byte(rfc5424Message+"\n"+rfc3164Message+"\n<34>1 garbage\n"))
+	c.Assert(results, HasLen, 3)
+
+	c.Check(results[0].Source, Equals, "#1")
+	c.Check(results[0].Format, Equals, "rfc5424")
+	c.Check(results[0].Error, Equals, "")
+	c.Check(results[0].Parts["app_name"], Equals, "su")
+	c.Check(results[0].Parts["timestamp"], Equals, "2003-10-11T22:14:15.003Z")
+
+	c.Check(results[1].Format, Equals, "rfc3164")
+	c.Check(results[1].Parts["tag"], Equals, "su")
+
+	c.Check(results[2].Format, Equals, "rfc5424")
+	c.Check(results[2].Error, Not(Equals), "")
+	c.Check(results[2].Message, Equals, "<34>1 garbage")
+}
+
+func (s *ReplaySuite) TestOctets(c *C) {
+	results := replay(c, "rfc5424", "octets", []byte("5 <34>1"+"11 <34>1 - - -"))
+	c.Assert(results, HasLen, 2)
+	c.Check(results[0].Message, Equals, "<34>1")
+	c.Check(results[0].Format, Equals, "rfc5424")
+	c.Check(results[1].Message, Equals, "<34>1 - - -")
+}
+
+func (s *ReplaySuite) TestDatagram(c *C) {
+	results := replay(c, "rfc3164", "datagram", []byte(rfc3164Message+"\n\x00"))
+	c.Assert(results, HasLen, 1)
+	c.Check(results[0].Source, Equals, "")
+	c.Check(results[0].Message, Equals, rfc3164Message)
+	c.Check(results[0].Parts["hostname"], Equals, "mymachine")
+}
+
+func (s *ReplaySuite) TestDatagramFrames(c *C) {
+	datagram := []byte("24 <13>1 - host app - - - a24 <13>1 - host app - - - b\n")
+	results := replay(c, "rfc6587", "datagram", datagram)
+	c.Assert(results, HasLen, 1)
+	c.Check(results[0].Message, Equals, "<13>1 - host app - - - a")
+	c.Check(results[0].Parts["extra_frames"], Equals, true)
+
+	results = replayDatagrams(c, "rfc6587", "datagram", datagram, syslog.DatagramOptions{MultipleFrames: true})
+	c.Assert(results, HasLen, 2)
+	c.Check(results[0].Source, Equals, "#1")
+	c.Check(results[0].Parts["message"], Equals, "a")
+	c.Check(results[1].Source, Equals, "#2")
+	c.Check(results[1].Parts["message"], Equals, "b")
+
+	// Truncated to at least 2048 bytes
+	results = replayDatagrams(c, "rfc3164", "datagram", []byte(rfc3164Message+strings.Repeat("x", 3000)), syslog.DatagramOptions{MaxSize: 100})
+	c.Assert(results, HasLen, 1)
+	c.Check(results[0].Message, HasLen, 2048)
+	c.Check(results[0].Parts["truncated"], Equals, true)
+}
+
+func (s *ReplaySuite) TestPcap(c *C) {
+	client, server := [4]byte{192, 0, 2, 1}, [4]byte{192, 0, 2, 2}
+	stream := format.AppendOctetCounted(nil, []byte(rfc5424Message))
+	stream = format.AppendOctetCounted(stream, []byte(rfc3164Message))
+	results := replay(c, "automatic", "auto", pcapFile(
+		ethernetFrame(ipv4Packet(protocolUDP, client, server, 40000, 514, 0, 0, []byte(rfc3164Message))),
+		// Not syslog
+		ethernetFrame(ipv4Packet(protocolUDP, client, server, 40000, 53, 0, 0, []byte("dns"))),
+		// TCP stream with a retransmission and out of order segments
+		ethernetFrame(ipv4Packet(protocolTCP, client, server, 40001, 601, 1000, tcpFlagSYN, nil)),
+		ethernetFrame(ipv4Packet(protocolTCP, client, server, 40001, 601, 1001, 0, stream[:50])),
+		ethernetFrame(ipv4Packet(protocolTCP, client, server, 40001, 601, 1101, 0, stream[100:])),
+		ethernetFrame(ipv4Packet(protocolTCP, client, server, 40001, 601, 1001, 0, stream[:60])),
+		ethernetFrame(ipv4Packet(protocolTCP, client, server, 40001, 601, 1061, 0, stream[60:100])),
+		ethernetFrame(ipv4Packet(protocolTCP, client, server, 40001, 601, 1001+uint32(len(stream)), tcpFlagFIN, nil)),
+	))
+	c.Assert(results, HasLen, 3)
+
+	c.Check(results[0].Source, Equals, ":1")
+	c.Check(results[0].Client, Equals, "192.0.2.1:40000")
+	c.Check(results[0].Format, Equals, "rfc3164")
+	c.Check(results[0].Message, Equals, rfc3164Message)
+
+	c.Check(results[1].Source, Equals, ":192.0.2.1:40001>192.0.2.2:601#1")
+	c.Check(results[1].Format, Equals, "rfc5424")
+	c.Check(results[1].Message, Equals, rfc5424Message)
+	c.Check(results[1].Error, Equals, "")
+	c.Check(results[2].Source, Equals, ":192.0.2.1:40001>192.0.2.2:601#2")
+	c.Check(results[2].Message, Equals, rfc3164Message)
+}
+
+func (s *ReplaySuite) TestPcapng(c *C) {
+	client, server := [4]byte{192, 0, 2, 1}, [4]byte{192, 0, 2, 2}
+	results := replay(c, "rfc5424", "pcap", pcapngFile(linkTypeRaw,
+		ipv4Packet(protocolUDP, client, server, 40000, 514, 0, 0, []byte(rfc5424Message)),
+		// TLS, not replayed
+		ipv4Packet(protocolTCP, client, server, 40001, 514, 1, 0, []byte{0x16, 0x03, 0x01, 0x00}),
+	))
+	c.Assert(results, HasLen, 2)
+	c.Check(results[0].Message, Equals, rfc5424Message)
+	c.Check(results[0].Parts["hostname"], Equals, "mymachine.example.com")
+	c.Check(results[1].Error, Equals, errTLSStream.Error())
+}
+
+func (s *ReplaySuite) TestInvalidCapture(c *C) {
+	var out bytes.Buffer
+	r, err := newReplayer("rfc5424", "pcap", "514", "", &out)
+	c.Assert(err, IsNil)
+	name := filepath.Join(c.MkDir(), "input")
+	c.Assert(ioutil.WriteFile(name, []byte(rfc5424Message), 0644), IsNil)
+	c.Check(r.replayFile(name), Equals, errCaptureFormat)
+
+	data := pcapFile(ethernetFrame(ipv4Packet(protocolUDP, [4]byte{}, [4]byte{}, 1, 514, 0, 0, []byte("x"))))
+	c.Assert(ioutil.WriteFile(name, data[:len(data)-10], 0644), IsNil)
+	c.Check(r.replayFile(name), ErrorMatches, "truncated pcap record.*")
+
+	_, err = newReplayer("json", "auto", "514", "", &out)
+	c.Check(err, ErrorMatches, `unknown format "json"`)
+	_, err = newReplayer("rfc5424", "auto", "70000", "", &out)
+	c.Check(err, ErrorMatches, `invalid port "70000"`)
+	_, err = os.Stat(name)
+	c.Check(err, IsNil)
+}
@@ -0,0 +1,143 @@
+package syslog
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+type DatagramSuite struct{}
+
+var _ = Suite(&DatagramSuite{})
+
+type channelHandler chan format.LogParts
+
+func (h channelHandler) Handle(logParts format.LogParts, messageLength int64, err error) {
+	h <- logParts
+}
+
+func (h channelHandler) receive(c *C) format.LogParts {
+	select {
+	case logParts := <-h:
+		return logParts
+	case <-time.After(5 * time.Second):
+		c.Fatal("no message")
+	}
+	return nil
+}
+
+func (s *DatagramSuite) parse(c *C, f format.Format, frames bool, datagram string) []format.LogParts {
+	handler := make(channelHandler, 10)
+	server := NewServer()
+	server.SetFormat(f)
+	server.SetHandler(handler)
+	server.parseDatagram(DatagramMessage{message: []byte(datagram), client: "127.0.0.1:514", frames: frames})
+	close(handler)
+
+	var messages []format.LogParts
+	for logParts := range handler {
+		messages = append(messages, logParts)
+	}
+	return messages
+}
+
+func (s *DatagramSuite) TestSingleMessage(c *C) {
+	// Not cut at its first line
+	messages := s.parse(c, Automatic, false, "<165>1 2003-10-11T22:14:15.003Z host app - - - panic: boom\n\tat main.go:12")
+	c.Assert(messages, HasLen, 1)
+	c.Check(messages[0]["message"], Equals, "panic: boom\n\tat main.go:12")
+
+	// Unframed when the frame spans the datagram
+	messages = s.parse(c, RFC6587, false, "24 <13>1 - host app - - - a")
+	c.Assert(messages, HasLen, 1)
+	c.Check(messages[0]["message"], Equals, "a")
+	messages = s.parse(c, Automatic, false, "29 <13>May  1 20:51:40 host p: a")
+	c.Assert(messages, HasLen, 1)
+	c.Check(messages[0]["content"], Equals, "a")
+
+	messages = s.parse(c, RFC3164, false, "<13>May  1 20:51:40 host p: a\n<13>May  1 20:51:40 host p: b")
+	c.Assert(messages, HasLen, 1)
+	c.Check(messages[0]["content"], Equals, "a\n<13>May  1 20:51:40 host p: b")
+
+	// Only the first of several octet counted frames
+	messages = s.parse(c, RFC6587, false, "24 <13>1 - host app - - - a24 <13>1 - host app - - - b")
+	c.Assert(messages, HasLen, 1)
+	c.Check(messages[0]["message"], Equals, "a")
+	c.Check(messages[0]["priority"], Equals, 13)
+	c.Check(messages[0]["extra_frames"], Equals, true)
+	_, ok := messages[0]["truncated"]
+	c.Check(ok, Equals, false)
+}
+
+func (s *DatagramSuite) TestMultipleFrames(c *C) {
+	messages := s.parse(c, Automatic, true, "29 <13>May  1 20:51:40 host p: a29 <13>May  1 20:51:40 host p: b")
+	c.Assert(messages, HasLen, 2)
+	c.Check(messages[0]["content"], Equals, "a")
+	c.Check(messages[1]["content"], Equals, "b")
+
+	messages = s.parse(c, Automatic, true, "<13>May  1 20:51:40 host p: a\n<13>May  1 20:51:40 host p: b")
+	c.Assert(messages, HasLen, 2)
+	c.Check(messages[1]["content"], Equals, "b")
+
+	// A frame cut short is handled as is
+	messages = s.parse(c, RFC6587, true, "29 <13>May  1 20:51:40 host p: a40 <13>May")
+	c.Assert(messages, HasLen, 2)
+	c.Check(messages[1]["message"], Equals, "")
+}
+
+func (s *DatagramSuite) TestTruncated(c *C) {
+	handler := make(channelHandler, 10)
+	server := NewServer()
+	server.SetFormat(RFC3164)
+	server.SetHandler(handler)
+	c.Assert(server.ListenUDPWithOptions("127.0.0.1:0", DatagramOptions{MaxSize: 100}), IsNil)
+	c.Assert(server.Boot(), IsNil)
+	defer server.Kill()
+
+	conn, err := net.Dial("udp", server.connections[0].LocalAddr().String())
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	// At least 2048 bytes are read
+	_, err = conn.Write([]byte("<13>May  1 20:51:40 host p: " + strings.Repeat("x", 2000)))
+	c.Assert(err, IsNil)
+	_, err = conn.Write([]byte("<13>May  1 20:51:40 host p: " + strings.Repeat("y", 3000)))
+	c.Assert(err, IsNil)
+
+	logParts := handler.receive(c)
+	c.Check(logParts["content"], HasLen, 2000)
+	_, ok := logParts["truncated"]
+	c.Check(ok, Equals, false)
+	logParts = handler.receive(c)
+	c.Check(logParts["content"], HasLen, 2048-28)
+	c.Check(logParts["truncated"], Equals, true)
+}
+
+func (s *DatagramSuite) TestDualStack(c *C) {
+	handler := make(channelHandler, 10)
+	server := NewServer()
+	server.SetFormat(RFC3164)
+	server.SetHandler(handler)
+	c.Assert(server.ListenUDP(":0"), IsNil)
+	c.Assert(server.Boot(), IsNil)
+	defer server.Kill()
+	_, port, err := net.SplitHostPort(server.connections[0].LocalAddr().String())
+	c.Assert(err, IsNil)
+
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		conn, err := net.Dial("udp", net.JoinHostPort(host, port))
+		if err != nil && host == "::1" {
+			c.Skip("no IPv6 loopback: " + err.Error())
+		}
+		c.Assert(err, IsNil)
+		_, err = conn.Write([]byte("<13>May  1 20:51:40 host p: from " + host))
+		conn.Close()
+		c.Assert(err, IsNil)
+
+		logParts := handler.receive(c)
+		c.Check(logParts["content"], Equals, "from "+host)
+		c.Check(clientIP(logParts["client"].(string)), Equals, host)
+	}
+}
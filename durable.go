@@ -0,0 +1,121 @@
+package syslog
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/queue"
+)
+
+// Flags of the messages in a durable queue
+const (
+	queuedDatagram  byte = 1 << iota
+	queuedFrames         // datagram which may carry several messages
+	queuedTruncated      // datagram truncated
+)
+
+var errQueuedRecord = errors.New("invalid queued message")
+
+// Sets a durable queue, which the received messages are appended to before
+// being parsed and handled. The TCP messages are appended once read from their
+// connection, and the datagrams before their buffer is reused, a single
+// goroutine then parsing them and calling the handler, the messages being
+// committed once handled. The messages left in the queue when the server stops
+// or crashes are handled once it starts again with the same queue.
+//
+// The messages refused by a full queue are lost, see queue.Queue.Dropped. The
+// queue is not closed by the server
+func (s *Server) SetQueue(q *queue.Queue) {
+	s.queue = q
+}
+
+// Appends a datagram to the queue
+func (s *Server) enqueueDatagram(msg DatagramMessage) {
+	flags := queuedDatagram
+	if msg.frames {
+		flags |= queuedFrames
+	}
+	if msg.truncated {
+		flags |= queuedTruncated
+	}
+	s.enqueue(flags, msg.message, connectionInfo{client: msg.client})
+}
+
+// Appends a message to the queue
+func (s *Server) enqueue(flags byte, message []byte, conn connectionInfo) {
+	record := make([]byte, 0, 1+4*binary.MaxVarintLen64+len(conn.client)+len(conn.tlsPeer)+
+		len(conn.tlsVersion)+len(conn.tlsCipherSuite)+len(message))
+	record = append(record, flags)
+	record = appendQueuedString(record, conn.client)
+	record = appendQueuedString(record, conn.tlsPeer)
+	record = appendQueuedString(record, conn.tlsVersion)
+	record = appendQueuedString(record, conn.tlsCipherSuite)
+	record = append(record, message...)
+	if err := s.queue.Append(record); err != nil {
+		s.lastError = err
+	}
+}
+
+func appendQueuedString(b []byte, s string) []byte {
+	var n [binary.MaxVarintLen64]byte
+	b = append(b, n[:binary.PutUvarint(n[:], uint64(len(s)))]...)
+	return append(b, s...)
+}
+
+func readQueuedString(b []byte) (string, []byte, error) {
+	l, n := binary.Uvarint(b)
+	if n <= 0 || uint64(len(b)-n) < l {
+		return "", nil, errQueuedRecord
+	}
+	return string(b[n : n+int(l)]), b[n+int(l):], nil
+}
+
+// Parses and handles the queued messages, until the server is killed
+func (s *Server) goConsumeQueue() {
+	s.wait.Add(1)
+	go func() {
+		defer s.wait.Done()
+		for {
+			record, err := s.queue.Next(s.done)
+			if err != nil {
+				if err != queue.ErrCanceled && err != queue.ErrClosed {
+					s.lastError = err
+				}
+				return
+			}
+			if err := s.handleQueued(record); err != nil {
+				s.lastError = err
+			}
+			if err := s.queue.Commit(); err != nil {
+				s.lastError = err
+			}
+		}
+	}()
+}
+
+func (s *Server) handleQueued(record []byte) error {
+	if len(record) == 0 {
+		return errQueuedRecord
+	}
+	flags := record[0]
+	var conn connectionInfo
+	message := record[1:]
+	for _, field := range []*string{&conn.client, &conn.tlsPeer, &conn.tlsVersion, &conn.tlsCipherSuite} {
+		var err error
+		if *field, message, err = readQueuedString(message); err != nil {
+			return err
+		}
+	}
+
+	if flags&queuedDatagram == 0 {
+		s.parse(message, conn, false, false)
+		return nil
+	}
+	s.parseDatagram(DatagramMessage{
+		message:   message,
+		client:    conn.client,
+		frames:    flags&queuedFrames != 0,
+		truncated: flags&queuedTruncated != 0,
+	})
+	return nil
+}
@@ -0,0 +1,82 @@
+package syslog
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/queue"
+)
+
+type DurableSuite struct{}
+
+var _ = Suite(&DurableSuite{})
+
+func (s *DurableSuite) TestHandlerOutage(c *C) {
+	dir := c.MkDir()
+	q, err := queue.Open(dir, 0)
+	c.Assert(err, IsNil)
+
+	// A handler stuck on the first message
+	stuck := make(chan struct{})
+	defer close(stuck)
+	server := NewServer()
+	server.SetFormat(Automatic)
+	server.SetQueue(q)
+	server.SetHandler(HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+		<-stuck
+	}))
+	c.Assert(server.ListenUDP("127.0.0.1:0"), IsNil)
+	c.Assert(server.ListenTCP("127.0.0.1:0"), IsNil)
+	c.Assert(server.Boot(), IsNil)
+
+	udp, err := net.Dial("udp", server.connections[0].LocalAddr().String())
+	c.Assert(err, IsNil)
+	defer udp.Close()
+	tcp, err := net.Dial("tcp", server.listeners[0].Addr().String())
+	c.Assert(err, IsNil)
+	for i := 0; i < 3; i++ {
+		_, err = fmt.Fprintf(udp, "<13>May  1 20:51:40 host prog: udp %d\n", i)
+		c.Assert(err, IsNil)
+		_, err = fmt.Fprintf(tcp, "<13>May  1 20:51:40 host prog: tcp %d\n", i)
+		c.Assert(err, IsNil)
+	}
+	tcp.Close()
+	// Records of 64 bytes: header, kind, client, TLS peer, version and cipher
+	// suite, and message
+	for start := time.Now(); q.Size() < 6*64 && time.Since(start) < 5*time.Second; {
+		time.Sleep(5 * time.Millisecond)
+	}
+	server.Kill()
+	c.Assert(q.Close(), IsNil)
+
+	// Handled once started again with the queue, the message stuck included
+	q, err = queue.Open(dir, 0)
+	c.Assert(err, IsNil)
+	defer q.Close()
+	contents := make(chan string, 10)
+	server = NewServer()
+	server.SetFormat(Automatic)
+	server.SetQueue(q)
+	server.SetHandler(HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+		contents <- logParts["content"].(string) + " from " + logParts["client"].(string)[:10]
+	}))
+	c.Assert(server.Boot(), IsNil)
+	defer server.Kill()
+
+	received := map[string]bool{}
+	for len(received) < 6 {
+		select {
+		case content := <-contents:
+			received[content] = true
+		case <-time.After(5 * time.Second):
+			c.Fatalf("received %v", received)
+		}
+	}
+	c.Check(received, DeepEquals, map[string]bool{
+		"udp 0 from 127.0.0.1:": true, "udp 1 from 127.0.0.1:": true, "udp 2 from 127.0.0.1:": true,
+		"tcp 0 from 127.0.0.1:": true, "tcp 1 from 127.0.0.1:": true, "tcp 2 from 127.0.0.1:": true,
+	})
+}
@@ -0,0 +1,155 @@
+package encoder
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// ECSVersion is the version of the Elastic Common Schema the ECS encoder
+// follows
+const ECSVersion = "8.11.0"
+
+// Names of the severities, as in RFC5424
+var ecsSeverityNames = []string{
+	"Emergency", "Alert", "Critical", "Error", "Warning", "Notice", "Informational", "Debug",
+}
+
+// ECS encodes the messages as Elastic Common Schema documents, one per line:
+//
+//	@timestamp                       timestamp
+//	message                          message or content
+//	log.level                        severity keyword, e.g. err
+//	log.syslog.priority              priority
+//	log.syslog.facility.code/name    facility, e.g. 4 and auth
+//	log.syslog.severity.code/name    severity, e.g. 3 and Error
+//	log.syslog.version               version
+//	log.syslog.hostname              hostname
+//	log.syslog.appname               app_name or tag
+//	log.syslog.procid                proc_id
+//	log.syslog.msgid                 msg_id
+//	log.syslog.structured_data       structured data, by SD-ID then parameter
+//	log.source.address               client
+//	host.hostname                    hostname
+//	process.name                     app_name or tag
+//	process.pid                      proc_id, when numeric
+//	client.ip/port                   client
+//	tls.client.subject               tls_peer
+//
+// The other fields are kept under the Namespace object
+type ECS struct {
+	// Name of the object holding the fields not part of ECS, "syslog" if
+	// empty, "-" to discard them
+	Namespace string
+}
+
+func (e *ECS) Encode(logParts format.LogParts) ([]byte, error) {
+	doc := map[string]interface{}{
+		"ecs": map[string]interface{}{"version": ECSVersion},
+	}
+	logDoc := map[string]interface{}{}
+	syslogDoc := map[string]interface{}{}
+
+	if t, ok := timeField(logParts, "timestamp"); ok {
+		doc["@timestamp"] = t.Format(time.RFC3339Nano)
+	}
+	if msg, ok := stringField(logParts, "message", "content"); ok {
+		doc["message"] = msg
+	}
+
+	if p, ok := intField(logParts, "priority"); ok {
+		syslogDoc["priority"] = p
+	}
+	if f, ok := intField(logParts, "facility"); ok {
+		facility := map[string]interface{}{"code": f}
+		if syslog.Facility(f).Valid() {
+			facility["name"] = syslog.Facility(f).String()
+		}
+		syslogDoc["facility"] = facility
+	}
+	if s, ok := intField(logParts, "severity"); ok {
+		severity := map[string]interface{}{"code": s}
+		if syslog.Severity(s).Valid() {
+			severity["name"] = ecsSeverityNames[s]
+			logDoc["level"] = syslog.Severity(s).String()
+		}
+		syslogDoc["severity"] = severity
+	}
+	if v, ok := intField(logParts, "version"); ok {
+		syslogDoc["version"] = strconv.Itoa(v)
+	}
+	if hostname, ok := nilableField(logParts, "hostname"); ok {
+		syslogDoc["hostname"] = hostname
+		doc["host"] = map[string]interface{}{"hostname": hostname}
+	}
+
+	process := map[string]interface{}{}
+	if appName, ok := stringField(logParts, "app_name", "tag"); ok && appName != "-" {
+		syslogDoc["appname"] = appName
+		process["name"] = appName
+	}
+	if procID, ok := nilableField(logParts, "proc_id"); ok {
+		syslogDoc["procid"] = procID
+	}
+	if n, ok := pid(logParts); ok {
+		process["pid"] = n
+	}
+	if len(process) > 0 {
+		doc["process"] = process
+	}
+	if msgID, ok := nilableField(logParts, "msg_id"); ok {
+		syslogDoc["msgid"] = msgID
+	}
+	if sd := structuredData(logParts); sd != nil {
+		syslogDoc["structured_data"] = sd
+	}
+
+	if client, ok := stringField(logParts, "client"); ok {
+		logDoc["source"] = map[string]interface{}{"address": client}
+		if host, port, err := net.SplitHostPort(client); err == nil {
+			clientDoc := map[string]interface{}{"ip": host}
+			if n, err := strconv.Atoi(port); err == nil {
+				clientDoc["port"] = n
+			}
+			doc["client"] = clientDoc
+		}
+	}
+	if tlsPeer, ok := stringField(logParts, "tls_peer"); ok {
+		doc["tls"] = map[string]interface{}{
+			"client": map[string]interface{}{"subject": tlsPeer},
+		}
+	}
+
+	if len(syslogDoc) > 0 {
+		logDoc["syslog"] = syslogDoc
+	}
+	if len(logDoc) > 0 {
+		doc["log"] = logDoc
+	}
+
+	namespace := e.Namespace
+	if namespace == "" {
+		namespace = "syslog"
+	}
+	if namespace != "-" {
+		extra := map[string]interface{}{}
+		for k, v := range logParts {
+			if !mappedFields[k] {
+				extra[k] = v
+			}
+		}
+		if len(extra) > 0 {
+			doc[namespace] = extra
+		}
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
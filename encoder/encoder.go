@@ -0,0 +1,84 @@
+// Package encoder provides encoders turning parsed messages into JSON
+// documents for downstream pipelines: plain JSON Lines, Elastic Common Schema
+// and OpenTelemetry log records. They implement syslog.Encoder, and can be
+// used with syslog.FileHandler
+package encoder
+
+import (
+	"strconv"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/internal/syslogparser/rfc5424"
+)
+
+// Returns the string field of the first key set and not empty
+func stringField(logParts format.LogParts, keys ...string) (string, bool) {
+	for _, key := range keys {
+		if s, ok := logParts[key].(string); ok && s != "" {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// Returns the string field of key, ignoring the NILVALUE
+func nilableField(logParts format.LogParts, key string) (string, bool) {
+	s, ok := stringField(logParts, key)
+	if !ok || s == "-" {
+		return "", false
+	}
+	return s, true
+}
+
+func intField(logParts format.LogParts, key string) (int, bool) {
+	i, ok := logParts[key].(int)
+	return i, ok
+}
+
+func timeField(logParts format.LogParts, key string) (time.Time, bool) {
+	t, ok := logParts[key].(time.Time)
+	return t, ok && !t.IsZero()
+}
+
+// Returns the parsed structured data, nil if there is none or it is invalid
+func structuredData(logParts format.LogParts) map[string]map[string]string {
+	sd, ok := nilableField(logParts, "structured_data")
+	if !ok {
+		return nil
+	}
+	elements, err := rfc5424.ParseStructuredDataElements(sd)
+	if err != nil || len(elements) == 0 {
+		return nil
+	}
+	return elements
+}
+
+// Returns the process ID as a number when it is one
+func pid(logParts format.LogParts) (int, bool) {
+	procID, ok := nilableField(logParts, "proc_id")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(procID)
+	return n, err == nil
+}
+
+// The fields mapped by all the encoders, the others being passed as is
+var mappedFields = map[string]bool{
+	"priority":        true,
+	"facility":        true,
+	"severity":        true,
+	"version":         true,
+	"timestamp":       true,
+	"hostname":        true,
+	"app_name":        true,
+	"tag":             true,
+	"proc_id":         true,
+	"msg_id":          true,
+	"structured_data": true,
+	"message":         true,
+	"content":         true,
+	"client":          true,
+	"tls_peer":        true,
+}
@@ -0,0 +1,153 @@
+package encoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func Test(t *testing.T) { TestingT(t) }
+
+type EncoderSuite struct{}
+
+var _ = Suite(&EncoderSuite{})
+
+var (
+	_ syslog.Encoder = (*JSONLines)(nil)
+	_ syslog.Encoder = (*ECS)(nil)
+	_ syslog.Encoder = (*OTel)(nil)
+)
+
+var fixtures = []format.LogParts{
+	// RFC5424
+	{
+		"priority":        165,
+		"facility":        20,
+		"severity":        5,
+		"version":         1,
+		"timestamp":       time.Date(2003, time.October, 11, 22, 14, 15, 3000000, time.UTC),
+		"hostname":        "mymachine.example.com",
+		"app_name":        "evntslog",
+		"proc_id":         "8710",
+		"msg_id":          "ID47",
+		"structured_data": `[exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"]`,
+		"message":         "An application event log entry...",
+		"client":          "192.0.2.1:51234",
+		"tls_peer":        "mymachine.example.com",
+	},
+	// RFC3164
+	{
+		"priority":         34,
+		"facility":         4,
+		"severity":         2,
+		"timestamp":        time.Date(2026, time.October, 11, 22, 14, 15, 0, time.FixedZone("", 2*60*60)),
+		"timestamp_format": "Jan _2 15:04:05",
+		"hostname":         "mymachine",
+		"tag":              "su",
+		"content":          "'su root' failed for lonvick on /dev/pts/8",
+		"client":           "[2001:db8::1]:514",
+		"tls_peer":         "",
+	},
+	// RFC5424 with NILVALUEs and a decoded payload
+	{
+		"priority":        14,
+		"facility":        1,
+		"severity":        6,
+		"version":         1,
+		"timestamp":       time.Time{},
+		"hostname":        "-",
+		"app_name":        "-",
+		"proc_id":         "-",
+		"msg_id":          "-",
+		"structured_data": "-",
+		"message":         `@cee: {"user": "alice", "attempts": 3, "ratio": 0.5, "tags": ["a", "b"], "ok": true}`,
+		"payload_format":  "cee",
+		"fields": map[string]interface{}{
+			"user":     "alice",
+			"attempts": json.Number("3"),
+			"ratio":    json.Number("0.5"),
+			"tags":     []interface{}{"a", "b"},
+			"ok":       true,
+		},
+	},
+}
+
+func (s *EncoderSuite) checkGolden(c *C, name string, encoder syslog.Encoder) {
+	var obtained bytes.Buffer
+	for _, logParts := range fixtures {
+		b, err := encoder.Encode(logParts)
+		c.Assert(err, IsNil)
+		c.Assert(json.Valid(b), Equals, true)
+		c.Assert(bytes.Count(b, []byte{'\n'}), Equals, 1)
+		obtained.Write(b)
+	}
+
+	golden := filepath.Join("testdata", name+".golden")
+	if *update {
+		c.Assert(ioutil.WriteFile(golden, obtained.Bytes(), 0644), IsNil)
+	}
+	expected, err := ioutil.ReadFile(golden)
+	c.Assert(err, IsNil)
+	c.Check(obtained.String(), Equals, string(expected))
+}
+
+func (s *EncoderSuite) TestJSONLines(c *C) {
+	s.checkGolden(c, "jsonlines", &JSONLines{})
+}
+
+func (s *EncoderSuite) TestJSONLinesOptions(c *C) {
+	s.checkGolden(c, "jsonlines_options", &JSONLines{
+		TimestampLayout:      time.RFC1123Z,
+		ExpandStructuredData: true,
+	})
+}
+
+func (s *EncoderSuite) TestECS(c *C) {
+	s.checkGolden(c, "ecs", &ECS{})
+}
+
+func (s *EncoderSuite) TestECSNamespace(c *C) {
+	b, err := (&ECS{Namespace: "custom"}).Encode(fixtures[1])
+	c.Assert(err, IsNil)
+	var doc map[string]interface{}
+	c.Assert(json.Unmarshal(b, &doc), IsNil)
+	c.Check(doc["custom"], DeepEquals, map[string]interface{}{"timestamp_format": "Jan _2 15:04:05"})
+
+	b, err = (&ECS{Namespace: "-"}).Encode(fixtures[1])
+	c.Assert(err, IsNil)
+	doc = nil
+	c.Assert(json.Unmarshal(b, &doc), IsNil)
+	c.Check(doc["syslog"], IsNil)
+}
+
+func (s *EncoderSuite) TestOTel(c *C) {
+	s.checkGolden(c, "otel", &OTel{
+		now: func() time.Time { return time.Date(2026, time.January, 2, 3, 4, 5, 6, time.UTC) },
+	})
+}
+
+func (s *EncoderSuite) TestOTelSeverityNumbers(c *C) {
+	e := &OTel{}
+	expected := []int{21, 19, 18, 17, 13, 10, 9, 5}
+	for severity, number := range expected {
+		b, err := e.Encode(format.LogParts{"severity": severity})
+		c.Assert(err, IsNil)
+		var record struct {
+			SeverityNumber int    `json:"severityNumber"`
+			SeverityText   string `json:"severityText"`
+		}
+		c.Assert(json.Unmarshal(b, &record), IsNil)
+		c.Check(record.SeverityNumber, Equals, number)
+		c.Check(record.SeverityText, Equals, syslog.Severity(severity).String())
+	}
+}
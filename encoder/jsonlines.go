@@ -0,0 +1,46 @@
+package encoder
+
+import (
+	"encoding/json"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// JSONLines encodes the messages as JSON objects holding all their fields,
+// one per line
+type JSONLines struct {
+	// Layout of the timestamps, time.RFC3339Nano if empty
+	TimestampLayout string
+	// Replaces the structured data string by an object of its elements, each
+	// one an object of its parameters
+	ExpandStructuredData bool
+}
+
+func (e *JSONLines) Encode(logParts format.LogParts) ([]byte, error) {
+	doc := make(map[string]interface{}, len(logParts))
+	for k, v := range logParts {
+		doc[k] = v
+	}
+
+	if e.TimestampLayout != "" {
+		for k, v := range doc {
+			if t, ok := v.(time.Time); ok {
+				doc[k] = t.Format(e.TimestampLayout)
+			}
+		}
+	}
+	if e.ExpandStructuredData {
+		if sd := structuredData(logParts); sd != nil {
+			doc["structured_data"] = sd
+		} else {
+			delete(doc, "structured_data")
+		}
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
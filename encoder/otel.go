@@ -0,0 +1,219 @@
+package encoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// OpenTelemetry severity numbers of the syslog severities, following the
+// mapping of the OpenTelemetry log data model
+var otelSeverityNumbers = []int{
+	21, // Emergency: FATAL
+	19, // Alert: ERROR3
+	18, // Critical: ERROR2
+	17, // Error: ERROR
+	13, // Warning: WARN
+	10, // Notice: INFO2
+	9,  // Informational: INFO
+	5,  // Debug: DEBUG
+}
+
+// OTel encodes the messages as OpenTelemetry log records, in the OTLP JSON
+// encoding, one per line. The body is the message or content, the severity is
+// mapped to a severity number, and the syslog fields become attributes named
+// as by the OpenTelemetry collector syslog receiver: priority, facility,
+// version, hostname, appname, proc_id, msg_id and structured_data, the latter
+// being a map of the SD-IDs to maps of their parameters. The client and TLS
+// peer follow the semantic conventions, as client.address, client.port and
+// tls.client.subject. The other fields are attributes of the same name
+type OTel struct {
+	now func() time.Time
+}
+
+type otelLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano,omitempty"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber,omitempty"`
+	SeverityText         string         `json:"severityText,omitempty"`
+	Body                 *otelAnyValue  `json:"body,omitempty"`
+	Attributes           []otelKeyValue `json:"attributes,omitempty"`
+}
+
+type otelKeyValue struct {
+	Key   string       `json:"key"`
+	Value otelAnyValue `json:"value"`
+}
+
+type otelAnyValue struct {
+	StringValue *string        `json:"stringValue,omitempty"`
+	BoolValue   *bool          `json:"boolValue,omitempty"`
+	IntValue    *string        `json:"intValue,omitempty"`
+	DoubleValue *float64       `json:"doubleValue,omitempty"`
+	ArrayValue  *otelArray     `json:"arrayValue,omitempty"`
+	KvlistValue *otelKeyValues `json:"kvlistValue,omitempty"`
+}
+
+type otelArray struct {
+	Values []otelAnyValue `json:"values"`
+}
+
+type otelKeyValues struct {
+	Values []otelKeyValue `json:"values"`
+}
+
+func (e *OTel) Encode(logParts format.LogParts) ([]byte, error) {
+	now := time.Now
+	if e.now != nil {
+		now = e.now
+	}
+
+	record := otelLogRecord{
+		ObservedTimeUnixNano: strconv.FormatInt(now().UnixNano(), 10),
+	}
+	if t, ok := timeField(logParts, "timestamp"); ok {
+		record.TimeUnixNano = strconv.FormatInt(t.UnixNano(), 10)
+	}
+	if s, ok := intField(logParts, "severity"); ok && s >= 0 && s < len(otelSeverityNumbers) {
+		record.SeverityNumber = otelSeverityNumbers[s]
+		record.SeverityText = syslog.Severity(s).String()
+	}
+	if msg, ok := stringField(logParts, "message", "content"); ok {
+		body := otelValue(msg)
+		record.Body = &body
+	}
+
+	attributes := map[string]interface{}{}
+	for _, key := range []string{"priority", "facility", "version"} {
+		if v, ok := intField(logParts, key); ok {
+			attributes[key] = v
+		}
+	}
+	if hostname, ok := nilableField(logParts, "hostname"); ok {
+		attributes["hostname"] = hostname
+	}
+	if appName, ok := stringField(logParts, "app_name", "tag"); ok && appName != "-" {
+		attributes["appname"] = appName
+	}
+	for _, key := range []string{"proc_id", "msg_id"} {
+		if v, ok := nilableField(logParts, key); ok {
+			attributes[key] = v
+		}
+	}
+	if sd := structuredData(logParts); sd != nil {
+		attributes["structured_data"] = sd
+	}
+	if client, ok := stringField(logParts, "client"); ok {
+		if host, port, err := net.SplitHostPort(client); err == nil {
+			attributes["client.address"] = host
+			if n, err := strconv.Atoi(port); err == nil {
+				attributes["client.port"] = n
+			}
+		} else {
+			attributes["client.address"] = client
+		}
+	}
+	if tlsPeer, ok := stringField(logParts, "tls_peer"); ok {
+		attributes["tls.client.subject"] = tlsPeer
+	}
+	for k, v := range logParts {
+		if !mappedFields[k] && !isEmpty(v) {
+			attributes[k] = v
+		}
+	}
+	record.Attributes = otelKeyValueList(attributes)
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func isEmpty(v interface{}) bool {
+	s, ok := v.(string)
+	return v == nil || ok && s == ""
+}
+
+// Returns the key values of m sorted by key
+func otelKeyValueList(m map[string]interface{}) []otelKeyValue {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]otelKeyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, otelKeyValue{Key: k, Value: otelValue(m[k])})
+	}
+	return kvs
+}
+
+func otelValue(v interface{}) otelAnyValue {
+	switch v := v.(type) {
+	case nil:
+		return otelAnyValue{}
+	case string:
+		return otelAnyValue{StringValue: &v}
+	case bool:
+		return otelAnyValue{BoolValue: &v}
+	case int:
+		s := strconv.Itoa(v)
+		return otelAnyValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otelAnyValue{IntValue: &s}
+	case uint64:
+		s := strconv.FormatUint(v, 10)
+		return otelAnyValue{IntValue: &s}
+	case float64:
+		return otelAnyValue{DoubleValue: &v}
+	case json.Number:
+		if _, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			s := string(v)
+			return otelAnyValue{IntValue: &s}
+		}
+		if f, err := v.Float64(); err == nil {
+			return otelAnyValue{DoubleValue: &f}
+		}
+		return otelValue(string(v))
+	case time.Time:
+		return otelValue(v.Format(time.RFC3339Nano))
+	case []interface{}:
+		values := make([]otelAnyValue, 0, len(v))
+		for _, e := range v {
+			values = append(values, otelValue(e))
+		}
+		return otelAnyValue{ArrayValue: &otelArray{Values: values}}
+	case []string:
+		values := make([]otelAnyValue, 0, len(v))
+		for _, e := range v {
+			values = append(values, otelValue(e))
+		}
+		return otelAnyValue{ArrayValue: &otelArray{Values: values}}
+	case map[string]interface{}:
+		return otelAnyValue{KvlistValue: &otelKeyValues{Values: otelKeyValueList(v)}}
+	case format.LogParts:
+		return otelValue(map[string]interface{}(v))
+	case map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = e
+		}
+		return otelValue(m)
+	case map[string]map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = e
+		}
+		return otelValue(m)
+	}
+	return otelValue(fmt.Sprint(v))
+}
@@ -7,12 +7,13 @@ import (
 )
 
 func main() {
-	channel := make(syslog.LogPartsChannel)
-	handler := syslog.NewChannelHandler(channel)
+	channel := make(syslog.MessageChannel)
+	handler := syslog.NewMessageChannelHandler(channel)
 
 	server := syslog.NewServer()
 	server.SetFormat(syslog.RFC5424)
 	server.SetHandler(handler)
+	server.SetCloseHandlerOnStop(true)
 	err := server.ListenUDP("0.0.0.0:514")
 	if err != nil {
 		panic(err)
@@ -27,9 +28,12 @@ func main() {
 		panic(err)
 	}
 
-	go func(channel syslog.LogPartsChannel) {
-		for logParts := range channel {
-			fmt.Println(logParts)
+	go func(channel syslog.MessageChannel) {
+		for msg := range channel {
+			if msg.Err != nil {
+				fmt.Println("error:", msg.Err)
+			}
+			fmt.Println(msg.LogParts)
 		}
 	}(channel)
 
@@ -0,0 +1,473 @@
+package syslog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// SyncPolicy tells when the written messages are synced to disk
+type SyncPolicy int
+
+const (
+	// Files are synced when rotated or closed only, leaving the rest to the
+	// operating system
+	SyncNone SyncPolicy = iota
+	// Files are synced after every message
+	SyncEveryMessage
+	// Files are synced after a message when the sync interval elapsed since
+	// their previous sync
+	SyncInterval
+)
+
+const (
+	fileMaxOpenDefault  = 64
+	rotatedSuffixLayout = "20060102-150405"
+)
+
+// FileHandler writes the messages to files, at paths that can depend on the
+// messages, e.g. /var/log/remote/{{hostname}}/{{app_name}}.log, each message
+// being rendered by an Encoder, TemplateRFC3164 by default.
+//
+// Files can be rotated when they reach a size and/or when a time period ends,
+// the rotated files being renamed with a timestamp suffix, optionally
+// compressed with gzip, and removed according to the retention settings.
+// Only the most recently used files are kept open.
+//
+// Write errors are reported to the error handler, the messages concerned are
+// lost
+type FileHandler struct {
+	path         *Template
+	encoder      Encoder
+	maxSize      int64
+	interval     time.Duration
+	maxBackups   int
+	maxAge       time.Duration
+	compress     bool
+	syncPolicy   SyncPolicy
+	syncInterval time.Duration
+	maxOpen      int
+	fileMode     os.FileMode
+	dirMode      os.FileMode
+	onError      func(error)
+	now          func() time.Time
+
+	mu     sync.Mutex
+	files  map[string]*list.Element // of *openFile
+	lru    *list.List               // most recently used first
+	closed bool
+	wait   sync.WaitGroup // background compressions
+	bgMu   sync.Mutex     // serializes the background compressions
+}
+
+type openFile struct {
+	path     string
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	syncedAt time.Time
+}
+
+// NewFileHandler returns a new FileHandler writing to the files at the paths
+// rendered by the given template. The values of the fields are made safe for
+// paths: they cannot add directory levels
+func NewFileHandler(pathTemplate string) (*FileHandler, error) {
+	path, err := ParseTemplate(pathTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileHandler{
+		path:     path,
+		encoder:  TemplateRFC3164,
+		maxOpen:  fileMaxOpenDefault,
+		fileMode: 0644,
+		dirMode:  0755,
+		now:      time.Now,
+		files:    make(map[string]*list.Element),
+		lru:      list.New(),
+	}, nil
+}
+
+// Sets the encoder of the messages, e.g. TemplateRFC5424 or TemplateJSONLines
+func (h *FileHandler) SetEncoder(encoder Encoder) {
+	h.encoder = encoder
+}
+
+// Sets the size in bytes above which files are rotated, 0 to disable
+func (h *FileHandler) SetMaxSize(maxSize int64) {
+	h.maxSize = maxSize
+}
+
+// Sets the period after which files are rotated, 0 to disable. Periods are
+// aligned on multiples of interval since the zero time, in UTC, e.g. a 24
+// hours interval rotates files at midnight UTC
+func (h *FileHandler) SetRotationInterval(interval time.Duration) {
+	h.interval = interval
+}
+
+// Sets how many rotated files are kept per file, and for how long. 0 keeps
+// them all, forever
+func (h *FileHandler) SetRetention(maxBackups int, maxAge time.Duration) {
+	h.maxBackups = maxBackups
+	h.maxAge = maxAge
+}
+
+// Sets whether the rotated files are compressed with gzip
+func (h *FileHandler) SetCompression(compress bool) {
+	h.compress = compress
+}
+
+// Sets the sync policy, the interval being used by SyncInterval
+func (h *FileHandler) SetSyncPolicy(policy SyncPolicy, interval time.Duration) {
+	h.syncPolicy = policy
+	h.syncInterval = interval
+}
+
+// Sets the maximum number of files kept open, the least recently used ones
+// being closed first
+func (h *FileHandler) SetMaxOpenFiles(maxOpen int) {
+	h.maxOpen = maxOpen
+}
+
+// Sets the permissions of the files and of the directories created
+func (h *FileHandler) SetPermissions(fileMode os.FileMode, dirMode os.FileMode) {
+	h.fileMode = fileMode
+	h.dirMode = dirMode
+}
+
+// Sets the function the write errors are reported to
+func (h *FileHandler) SetErrorHandler(onError func(error)) {
+	h.onError = onError
+}
+
+func (h *FileHandler) Handle(logParts format.LogParts, messageLength int64, err error) {
+	if err := h.write(logParts); err != nil && h.onError != nil {
+		h.onError(err)
+	}
+}
+
+func (h *FileHandler) write(logParts format.LogParts) error {
+	var path bytes.Buffer
+	if err := h.path.execute(&path, logParts, pathSafe); err != nil {
+		return err
+	}
+	data, err := h.encoder.Encode(logParts)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return os.ErrClosed
+	}
+
+	f, err := h.open(filepath.Clean(path.String()))
+	if err != nil {
+		return err
+	}
+
+	now := h.now()
+	if h.needsRotation(f, int64(len(data)), now) {
+		if err := h.rotate(f, now); err != nil {
+			return err
+		}
+		if f, err = h.open(f.path); err != nil {
+			return err
+		}
+	}
+
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	switch h.syncPolicy {
+	case SyncEveryMessage:
+		return f.file.Sync()
+	case SyncInterval:
+		if now.Sub(f.syncedAt) >= h.syncInterval {
+			f.syncedAt = now
+			return f.file.Sync()
+		}
+	}
+	return nil
+}
+
+// Returns the open file at path, opening it if needed
+func (h *FileHandler) open(path string) (*openFile, error) {
+	if e, ok := h.files[path]; ok {
+		h.lru.MoveToFront(e)
+		return e.Value.(*openFile), nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), h.dirMode); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, h.fileMode)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	now := h.now()
+	f := &openFile{
+		path:     path,
+		file:     file,
+		size:     info.Size(),
+		openedAt: now,
+		syncedAt: now,
+	}
+	if f.size > 0 {
+		// Continuing an existing file, its period is the one of its last write
+		f.openedAt = info.ModTime()
+	}
+	h.files[path] = h.lru.PushFront(f)
+
+	for h.maxOpen > 0 && h.lru.Len() > h.maxOpen {
+		h.closeFile(h.lru.Back().Value.(*openFile))
+	}
+
+	return f, nil
+}
+
+func (h *FileHandler) closeFile(f *openFile) error {
+	h.lru.Remove(h.files[f.path])
+	delete(h.files, f.path)
+
+	err := f.file.Sync()
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (h *FileHandler) needsRotation(f *openFile, n int64, now time.Time) bool {
+	if f.size == 0 {
+		return false
+	}
+	if h.maxSize > 0 && f.size+n > h.maxSize {
+		return true
+	}
+	if h.interval > 0 && !f.openedAt.UTC().Truncate(h.interval).Equal(now.UTC().Truncate(h.interval)) {
+		return true
+	}
+	return false
+}
+
+// Closes f and renames it with a timestamp suffix, then compresses it and
+// applies the retention in the background
+func (h *FileHandler) rotate(f *openFile, now time.Time) error {
+	if err := h.closeFile(f); err != nil {
+		return err
+	}
+
+	rotated := f.path + "." + now.Format(rotatedSuffixLayout)
+	for i := 1; fileExists(rotated) || fileExists(rotated+".gz"); i++ {
+		rotated = f.path + "." + now.Format(rotatedSuffixLayout) + "." + strconv.Itoa(i)
+	}
+	if err := os.Rename(f.path, rotated); err != nil {
+		return err
+	}
+
+	h.wait.Add(1)
+	go func() {
+		defer h.wait.Done()
+		h.bgMu.Lock()
+		defer h.bgMu.Unlock()
+		if h.compress {
+			// The file may already be gone because of the retention of a
+			// later rotation
+			if err := compressFile(rotated, h.fileMode); err != nil && !os.IsNotExist(err) && h.onError != nil {
+				h.onError(err)
+			}
+		}
+		if err := h.applyRetention(f.path, now); err != nil && h.onError != nil {
+			h.onError(err)
+		}
+	}()
+
+	return nil
+}
+
+// Removes the rotated files of path beyond the retention settings at now
+func (h *FileHandler) applyRetention(path string, now time.Time) error {
+	if h.maxBackups <= 0 && h.maxAge <= 0 {
+		return nil
+	}
+
+	matches, err := filepath.Glob(globEscape(path) + ".*")
+	if err != nil {
+		return err
+	}
+	var rotated []rotatedFile
+	for _, m := range matches {
+		if r, ok := parseRotatedFile(path, m); ok {
+			rotated = append(rotated, r)
+		}
+	}
+	// Most recent first
+	sort.Slice(rotated, func(i, j int) bool {
+		if rotated[i].stamp != rotated[j].stamp {
+			return rotated[i].stamp > rotated[j].stamp
+		}
+		return rotated[i].n > rotated[j].n
+	})
+
+	for i, r := range rotated {
+		name := r.name
+		remove := h.maxBackups > 0 && i >= h.maxBackups
+		if !remove && h.maxAge > 0 {
+			if info, err := os.Stat(name); err == nil && now.Sub(info.ModTime()) > h.maxAge {
+				remove = true
+			}
+		}
+		if remove {
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type rotatedFile struct {
+	name  string
+	stamp string
+	n     int
+}
+
+// Parses the suffix of name if it is a rotated file of path, excluding the
+// temporary files of compressions in progress
+func parseRotatedFile(path string, name string) (rotatedFile, bool) {
+	r := rotatedFile{name: name}
+	suffix := strings.TrimSuffix(name[len(path)+1:], ".gz")
+	if i := strings.IndexByte(suffix, '.'); i >= 0 {
+		n, err := strconv.Atoi(suffix[i+1:])
+		if err != nil {
+			return r, false
+		}
+		r.n = n
+		suffix = suffix[:i]
+	}
+	if _, err := time.Parse(rotatedSuffixLayout, suffix); err != nil {
+		return r, false
+	}
+	r.stamp = suffix
+	return r, true
+}
+
+func compressFile(name string, mode os.FileMode) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := name + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if serr := dst.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	src.Close()
+	return os.Remove(name)
+}
+
+func fileExists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+func globEscape(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		switch r {
+		case '*', '?', '[', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Makes a value safe for a path, so that it stays a single path element, an
+// empty one included
+func pathSafe(value string) string {
+	if value == "" || value == "." || value == ".." {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == 0 {
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+// Sync syncs the open files to disk
+func (h *FileHandler) Sync() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var err error
+	for e := h.lru.Front(); e != nil; e = e.Next() {
+		f := e.Value.(*openFile)
+		if serr := f.file.Sync(); err == nil {
+			err = serr
+		}
+		f.syncedAt = h.now()
+	}
+	return err
+}
+
+// Close closes the open files, and waits for the compressions in progress
+func (h *FileHandler) Close() error {
+	h.mu.Lock()
+	var err error
+	h.closed = true
+	for h.lru.Len() > 0 {
+		if cerr := h.closeFile(h.lru.Front().Value.(*openFile)); err == nil {
+			err = cerr
+		}
+	}
+	h.mu.Unlock()
+
+	h.wait.Wait()
+	return err
+}
@@ -0,0 +1,219 @@
+package syslog
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+type FileHandlerSuite struct {
+	dir    string
+	now    time.Time
+	errors []error
+}
+
+var _ = Suite(&FileHandlerSuite{})
+
+func (s *FileHandlerSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	s.now = time.Date(2026, time.March, 4, 5, 6, 7, 0, time.UTC)
+	s.errors = nil
+}
+
+func (s *FileHandlerSuite) newHandler(c *C, path string) *FileHandler {
+	h, err := NewFileHandler(filepath.Join(s.dir, path))
+	c.Assert(err, IsNil)
+	h.now = func() time.Time { return s.now }
+	h.SetEncoder(MustParseTemplate("{{content}}\n"))
+	h.SetErrorHandler(func(err error) {
+		s.errors = append(s.errors, err)
+	})
+	return h
+}
+
+func (s *FileHandlerSuite) read(c *C, path string) string {
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, path))
+	c.Assert(err, IsNil)
+	return string(b)
+}
+
+func (s *FileHandlerSuite) list(c *C, dir string) []string {
+	infos, err := ioutil.ReadDir(filepath.Join(s.dir, dir))
+	c.Assert(err, IsNil)
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *FileHandlerSuite) TestDynamicPaths(c *C) {
+	h := s.newHandler(c, "{{hostname|unknown}}/{{tag}}.log")
+
+	h.Handle(format.LogParts{"hostname": "host1", "tag": "sshd", "content": "a"}, 0, nil)
+	h.Handle(format.LogParts{"hostname": "host2", "tag": "sshd", "content": "b"}, 0, nil)
+	h.Handle(format.LogParts{"hostname": "host1", "tag": "sshd", "content": "c"}, 0, nil)
+	h.Handle(format.LogParts{"tag": "../cron", "content": "d"}, 0, nil)
+	// Not in the parent directory
+	h.Handle(format.LogParts{"hostname": "host1", "tag": "", "content": "e"}, 0, nil)
+	c.Assert(h.Close(), IsNil)
+
+	c.Check(s.errors, HasLen, 0)
+	c.Check(s.read(c, "host1/sshd.log"), Equals, "a\nc\n")
+	c.Check(s.read(c, "host2/sshd.log"), Equals, "b\n")
+	c.Check(s.read(c, "unknown/.._cron.log"), Equals, "d\n")
+	c.Check(s.read(c, "host1/_.log"), Equals, "e\n")
+
+	h.Handle(format.LogParts{"hostname": "host1", "tag": "sshd", "content": "e"}, 0, nil)
+	c.Check(s.errors, DeepEquals, []error{os.ErrClosed})
+}
+
+func (s *FileHandlerSuite) TestDefaultEncoder(c *C) {
+	h, err := NewFileHandler(filepath.Join(s.dir, "messages"))
+	c.Assert(err, IsNil)
+	h.Handle(format.LogParts{"priority": 34, "timestamp": s.now, "hostname": "host1", "tag": "su", "content": "failed"}, 0, nil)
+	c.Assert(h.Close(), IsNil)
+
+	c.Check(s.read(c, "messages"), Equals, "<34>Mar  4 05:06:07 host1 su: failed\n")
+}
+
+func (s *FileHandlerSuite) TestSizeRotation(c *C) {
+	h := s.newHandler(c, "app.log")
+	h.SetMaxSize(10)
+
+	h.Handle(format.LogParts{"content": "1234"}, 0, nil)
+	h.Handle(format.LogParts{"content": "5678"}, 0, nil)
+	h.Handle(format.LogParts{"content": "90"}, 0, nil)
+	// Same second, gets a counter
+	h.Handle(format.LogParts{"content": "abcdefghijkl"}, 0, nil)
+	h.Handle(format.LogParts{"content": "m"}, 0, nil)
+	c.Assert(h.Close(), IsNil)
+
+	c.Check(s.errors, HasLen, 0)
+	c.Check(s.list(c, ""), DeepEquals, []string{"app.log", "app.log.20260304-050607", "app.log.20260304-050607.1", "app.log.20260304-050607.2"})
+	c.Check(s.read(c, "app.log.20260304-050607"), Equals, "1234\n5678\n")
+	c.Check(s.read(c, "app.log.20260304-050607.1"), Equals, "90\n")
+	c.Check(s.read(c, "app.log.20260304-050607.2"), Equals, "abcdefghijkl\n")
+	c.Check(s.read(c, "app.log"), Equals, "m\n")
+}
+
+func (s *FileHandlerSuite) TestTimeRotation(c *C) {
+	h := s.newHandler(c, "app.log")
+	h.SetRotationInterval(time.Hour)
+
+	h.Handle(format.LogParts{"content": "a"}, 0, nil)
+	s.now = s.now.Add(50 * time.Minute)
+	h.Handle(format.LogParts{"content": "b"}, 0, nil)
+	c.Assert(h.Close(), IsNil)
+	c.Check(s.list(c, ""), DeepEquals, []string{"app.log"})
+
+	// Reopening an existing file of the previous period
+	c.Assert(os.Chtimes(filepath.Join(s.dir, "app.log"), s.now, s.now), IsNil)
+	h = s.newHandler(c, "app.log")
+	h.SetRotationInterval(time.Hour)
+	s.now = s.now.Add(time.Hour)
+	h.Handle(format.LogParts{"content": "c"}, 0, nil)
+	c.Assert(h.Close(), IsNil)
+
+	c.Check(s.errors, HasLen, 0)
+	c.Check(s.list(c, ""), DeepEquals, []string{"app.log", "app.log.20260304-065607"})
+	c.Check(s.read(c, "app.log.20260304-065607"), Equals, "a\nb\n")
+	c.Check(s.read(c, "app.log"), Equals, "c\n")
+}
+
+func (s *FileHandlerSuite) TestCompressionAndRetention(c *C) {
+	h := s.newHandler(c, "app.log")
+	h.SetMaxSize(2)
+	h.SetCompression(true)
+	h.SetRetention(2, 0)
+
+	for _, content := range []string{"a", "b", "c", "d", "e"} {
+		h.Handle(format.LogParts{"content": content}, 0, nil)
+		s.now = s.now.Add(time.Second)
+	}
+	c.Assert(h.Close(), IsNil)
+
+	c.Check(s.errors, HasLen, 0)
+	c.Check(s.list(c, ""), DeepEquals, []string{"app.log", "app.log.20260304-050610.gz", "app.log.20260304-050611.gz"})
+	c.Check(s.read(c, "app.log"), Equals, "e\n")
+
+	f, err := os.Open(filepath.Join(s.dir, "app.log.20260304-050611.gz"))
+	c.Assert(err, IsNil)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	c.Assert(err, IsNil)
+	b, err := ioutil.ReadAll(zr)
+	c.Assert(err, IsNil)
+	c.Check(string(b), Equals, "d\n")
+}
+
+func (s *FileHandlerSuite) TestRetentionMaxAge(c *C) {
+	h := s.newHandler(c, "app.log")
+	h.SetMaxSize(2)
+	h.SetRetention(0, time.Hour)
+
+	old := filepath.Join(s.dir, "app.log.20260303-000000")
+	c.Assert(ioutil.WriteFile(old, []byte("old\n"), 0644), IsNil)
+	c.Assert(os.Chtimes(old, s.now.Add(-2*time.Hour), s.now.Add(-2*time.Hour)), IsNil)
+	unrelated := filepath.Join(s.dir, "app.log.backup")
+	c.Assert(ioutil.WriteFile(unrelated, []byte("keep\n"), 0644), IsNil)
+	c.Assert(os.Chtimes(unrelated, s.now.Add(-2*time.Hour), s.now.Add(-2*time.Hour)), IsNil)
+
+	h.Handle(format.LogParts{"content": "a"}, 0, nil)
+	h.Handle(format.LogParts{"content": "b"}, 0, nil)
+	c.Assert(h.Close(), IsNil)
+
+	c.Check(s.errors, HasLen, 0)
+	c.Check(s.list(c, ""), DeepEquals, []string{"app.log", "app.log.20260304-050607", "app.log.backup"})
+}
+
+func (s *FileHandlerSuite) TestMaxOpenFiles(c *C) {
+	h := s.newHandler(c, "{{tag}}.log")
+	h.SetMaxOpenFiles(2)
+	h.SetSyncPolicy(SyncEveryMessage, 0)
+
+	for _, tag := range []string{"a", "b", "a", "c", "b", "a"} {
+		h.Handle(format.LogParts{"tag": tag, "content": tag}, 0, nil)
+		c.Check(h.lru.Len() <= 2, Equals, true)
+	}
+
+	// Most recently used first
+	var open []string
+	for e := h.lru.Front(); e != nil; e = e.Next() {
+		open = append(open, filepath.Base(e.Value.(*openFile).path))
+	}
+	c.Check(open, DeepEquals, []string{"a.log", "b.log"})
+	c.Assert(h.Sync(), IsNil)
+	c.Assert(h.Close(), IsNil)
+
+	c.Check(s.errors, HasLen, 0)
+	c.Check(s.read(c, "a.log"), Equals, "a\na\na\n")
+	c.Check(s.read(c, "b.log"), Equals, "b\nb\n")
+	c.Check(s.read(c, "c.log"), Equals, "c\n")
+}
+
+func (s *FileHandlerSuite) TestSyncInterval(c *C) {
+	h := s.newHandler(c, "app.log")
+	h.SetSyncPolicy(SyncInterval, time.Minute)
+
+	h.Handle(format.LogParts{"content": "a"}, 0, nil)
+	f := h.lru.Front().Value.(*openFile)
+	c.Check(f.syncedAt.Equal(s.now), Equals, true)
+
+	s.now = s.now.Add(2 * time.Minute)
+	h.Handle(format.LogParts{"content": "b"}, 0, nil)
+	c.Check(f.syncedAt.Equal(s.now), Equals, true)
+	c.Assert(h.Close(), IsNil)
+}
+
+func (s *FileHandlerSuite) TestInvalidTemplate(c *C) {
+	_, err := NewFileHandler("/var/log/{{hostname")
+	c.Check(err, Equals, ErrTemplateUnclosed)
+}
@@ -61,6 +61,18 @@ func detect(data []byte) int {
 	return detectedRFC3164
 }
 
+// DetectFormat returns the format of data as detected by Automatic, "rfc3164",
+// "rfc5424", or "rfc6587" when it starts with an octet count
+func DetectFormat(data []byte) string {
+	switch detect(data) {
+	case detectedRFC5424:
+		return "rfc5424"
+	case detectedRFC6587:
+		return "rfc6587"
+	}
+	return "rfc3164"
+}
+
 func (f *Automatic) GetParser(line []byte) LogParser {
 	switch format := detect(line); format {
 	case detectedRFC3164:
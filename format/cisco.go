@@ -0,0 +1,33 @@
+package format
+
+import (
+	"bufio"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/internal/syslogparser/cisco"
+)
+
+// Cisco parses the messages of Cisco IOS, NX-OS and ASA devices, i.e. the
+// messages carrying a %FACILITY-SEVERITY-MNEMONIC code.
+//
+// When Fallback is set, the messages without such a code are parsed by it
+// instead, and its framing is used, so that Cisco devices can share a
+// listener with other senders:
+//
+//	&format.Cisco{Fallback: &format.Automatic{}}
+type Cisco struct {
+	Fallback Format
+}
+
+func (f *Cisco) GetParser(line []byte) LogParser {
+	if f.Fallback != nil && !cisco.Detect(line) {
+		return f.Fallback.GetParser(line)
+	}
+	return &parserWrapper{cisco.NewParser(line)}
+}
+
+func (f *Cisco) GetSplitFunc() bufio.SplitFunc {
+	if f.Fallback != nil {
+		return f.Fallback.GetSplitFunc()
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package format
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (s *FormatSuite) TestCisco_SingleSplit(c *C) {
+	f := Cisco{}
+	c.Assert(f.GetSplitFunc(), IsNil)
+}
+
+func (s *FormatSuite) TestCisco_FallbackSplit(c *C) {
+	f := Cisco{Fallback: &Automatic{}}
+	c.Assert(f.GetSplitFunc(), NotNil)
+}
+
+func (s *FormatSuite) TestCisco_CorrectParsing(c *C) {
+	f := Cisco{}
+
+	find := `<189>123: router1: *Mar  1 18:46:11.123 UTC: %SYS-5-CONFIG_I: Configured from console`
+	parser := f.GetParser([]byte(find))
+	err := parser.Parse()
+	c.Assert(err, IsNil)
+	c.Assert(parser.Dump()["sequence"], Equals, "123")
+	c.Assert(parser.Dump()["hostname"], Equals, "router1")
+	c.Assert(parser.Dump()["cisco_facility"], Equals, "SYS")
+	c.Assert(parser.Dump()["cisco_severity"], Equals, 5)
+	c.Assert(parser.Dump()["cisco_mnemonic"], Equals, "CONFIG_I")
+	c.Assert(parser.Dump()["content"], Equals, "Configured from console")
+}
+
+func (s *FormatSuite) TestCisco_Fallback(c *C) {
+	f := Cisco{Fallback: &Automatic{}}
+
+	find := `<189>123: router1: *Mar  1 18:46:11.123 UTC: %SYS-5-CONFIG_I: Configured from console`
+	parser := f.GetParser([]byte(find))
+	c.Assert(parser.Parse(), IsNil)
+	c.Assert(parser.Dump()["hostname"], Equals, "router1")
+	c.Assert(parser.Dump()["cisco_mnemonic"], Equals, "CONFIG_I")
+
+	find = `<13>May  1 20:51:40 myhostname myprogram: ciao`
+	parser = f.GetParser([]byte(find))
+	c.Assert(parser.Parse(), IsNil)
+	c.Assert(parser.Dump()["hostname"], Equals, "myhostname")
+	c.Assert(parser.Dump()["tag"], Equals, "myprogram")
+	c.Assert(parser.Dump()["cisco_mnemonic"], IsNil)
+
+	find = `<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - %SYS-5-CONFIG_I: relayed`
+	parser = f.GetParser([]byte(find))
+	c.Assert(parser.Parse(), IsNil)
+	c.Assert(parser.Dump()["app_name"], Equals, "su")
+	c.Assert(parser.Dump()["message"], Equals, "%SYS-5-CONFIG_I: relayed")
+}
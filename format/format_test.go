@@ -11,3 +11,10 @@ func Test(t *testing.T) { TestingT(t) }
 type FormatSuite struct{}
 
 var _ = Suite(&FormatSuite{})
+
+func (s *FormatSuite) TestDetectFormat(c *C) {
+	c.Check(DetectFormat([]byte("<34>Oct 11 22:14:15 mymachine su: 'su root' failed")), Equals, "rfc3164")
+	c.Check(DetectFormat([]byte("<34>1 2003-10-11T22:14:15.003Z mymachine su - - - failed")), Equals, "rfc5424")
+	c.Check(DetectFormat([]byte("55 <34>1 2003-10-11T22:14:15.003Z mymachine su - - - failed")), Equals, "rfc6587")
+	c.Check(DetectFormat([]byte("garbage")), Equals, "rfc3164")
+}
@@ -43,7 +43,7 @@ func (s *FormatSuite) TestRFC3164_CorrectParsingGNU(c *C) {
 	err := parser.Parse()
 	c.Assert(err, IsNil)
 	c.Assert(parser.Dump()["content"], Equals, "ciao")
-	// c.Assert(parser.Dump()["hostname"], Equals, "myhostname")
+	c.Assert(parser.Dump()["hostname"], Equals, "")
 	c.Assert(parser.Dump()["tag"], Equals, "myprogram")
 
 }
@@ -58,7 +58,7 @@ func (s *FormatSuite) TestRFC3164_CorrectParsingJournald(c *C) {
 	err := parser.Parse()
 	c.Assert(err, IsNil)
 	c.Assert(parser.Dump()["content"], Equals, "blah")
-	// c.Assert(parser.Dump()["hostname"], Equals, "myhostname")
+	c.Assert(parser.Dump()["hostname"], Equals, "")
 	c.Assert(parser.Dump()["tag"], Equals, "myprog")
 
 }
@@ -0,0 +1,58 @@
+package format
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+var (
+	ErrOctetCount    = errors.New("frame without valid octet count")
+	ErrFrameTooLarge = errors.New("frame larger than the maximum message size")
+)
+
+// RFC5425SplitFunc returns the split function of the frames of RFC5425 (section
+// 4.3), which are framed with octet counting only: the length of the message
+// in decimal, without leading zero, a space, then the message. Any other
+// framing fails with ErrOctetCount, a frame longer than maxSize with
+// ErrFrameTooLarge, and a frame cut short by the end of the connection with
+// io.ErrUnexpectedEOF. The scanner buffer must hold frames of maxSize
+func RFC5425SplitFunc(maxSize int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		return rfc5425Split(data, atEOF, maxSize)
+	}
+}
+
+func rfc5425Split(data []byte, atEOF bool, maxSize int) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	length := 0
+	for i, b := range data {
+		switch {
+		case b == ' ' && i > 0:
+			end := i + 1 + length
+			if len(data) >= end {
+				return end, data[i+1 : end], nil
+			}
+			if atEOF {
+				return 0, nil, io.ErrUnexpectedEOF
+			}
+			// Request more data
+			return 0, nil, nil
+		case b < '0' || b > '9' || (i == 0 && b == '0'):
+			return 0, nil, ErrOctetCount
+		}
+		length = length*10 + int(b-'0')
+		if length > maxSize {
+			return 0, nil, ErrFrameTooLarge
+		}
+	}
+	if atEOF {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+
+	// Request more data
+	return 0, nil, nil
+}
@@ -0,0 +1,40 @@
+package format
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *FormatSuite) TestRFC5425SplitFunc(c *C) {
+	split := func(data string) ([]string, error) {
+		scanner := bufio.NewScanner(strings.NewReader(data))
+		scanner.Split(RFC5425SplitFunc(20))
+		var frames []string
+		for scanner.Scan() {
+			frames = append(frames, scanner.Text())
+		}
+		return frames, scanner.Err()
+	}
+
+	frames, err := split("5 <1> a7 <2> b\nc")
+	c.Check(err, IsNil)
+	c.Check(frames, DeepEquals, []string{"<1> a", "<2> b\nc"})
+
+	for data, expected := range map[string]error{
+		"5 <1> a<2> b\n":            ErrOctetCount,
+		"5 <1> a\n5 <2> b":          ErrOctetCount,
+		"5 <1> a05 <2> b":           ErrOctetCount,
+		"5 <1> a 5 <2> b":           ErrOctetCount,
+		"5 <1> a21 ":                ErrFrameTooLarge,
+		"5 <1> a123456789012345678": ErrFrameTooLarge,
+		"5 <1> a9 <2> b":            io.ErrUnexpectedEOF,
+		"5 <1> a9":                  io.ErrUnexpectedEOF,
+	} {
+		frames, err := split(data)
+		c.Check(err, Equals, expected, Commentf("%q", data))
+		c.Check(frames, DeepEquals, []string{"<1> a"}, Commentf("%q", data))
+	}
+}
@@ -3,41 +3,144 @@ package format
 import (
 	"bufio"
 	"bytes"
+	"io"
 	"strconv"
 
 	"gopkg.in/sleepinggenius2/go-syslog.v2/internal/syslogparser/rfc5424"
 )
 
-type RFC6587 struct{}
+// Beyond it an octet count is considered invalid
+const rfc6587FrameSizeMax = 16 << 20
+
+// Trailer is the trailer ending the frames of RFC6587 non-transparent framing
+type Trailer int
+
+const (
+	TrailerLF   Trailer = iota // LF, a CR before it being removed too (default)
+	TrailerCRLF                // CR LF, so that frames may hold LFs
+	TrailerNUL                 // NUL
+)
+
+// RFC6587 reads the frames of RFC6587, each one being framed with octet
+// counting (section 3.4.1) when it starts with a digit, or else with
+// non-transparent framing (section 3.4.2) ended by the trailer, so that a
+// connection may switch between them. The last frame of a connection is read
+// up to its end even without trailer, but an octet counted frame cut short is
+// discarded, the split failing with io.ErrUnexpectedEOF
+type RFC6587 struct {
+	Trailer Trailer
+}
 
 func (f *RFC6587) GetParser(line []byte) LogParser {
 	return &parserWrapper{rfc5424.NewParser(line)}
 }
 
 func (f *RFC6587) GetSplitFunc() bufio.SplitFunc {
-	return rfc6587ScannerSplit
+	trailer := f.Trailer
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		return rfc6587Split(data, atEOF, trailer)
+	}
+}
+
+// AppendOctetCounted appends msg to dst, framed with octet counting as read by
+// the RFC6587 split function: the length of msg in decimal, a space, then msg
+func AppendOctetCounted(dst []byte, msg []byte) []byte {
+	dst = strconv.AppendInt(dst, int64(len(msg)), 10)
+	dst = append(dst, ' ')
+	return append(dst, msg...)
 }
 
 func rfc6587ScannerSplit(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	if atEOF && len(data) == 0 {
+	return rfc6587Split(data, atEOF, TrailerLF)
+}
+
+func rfc6587Split(data []byte, atEOF bool, trailer Trailer) (advance int, token []byte, err error) {
+	// Skip the trailers of empty frames, or sent after octet counted ones
+	skip := 0
+	for skip < len(data) && (data[skip] == '\n' || data[skip] == '\r' || data[skip] == 0) {
+		skip++
+	}
+	if skip > 0 {
+		// In the same call, as the scanner stops on a call without token at EOF
+		advance, token, err = rfc6587Split(data[skip:], atEOF, trailer)
+		if token == nil && err == nil {
+			return skip, nil, nil
+		}
+		return skip + advance, token, err
+	}
+
+	if len(data) == 0 {
+		// Request more data, if not at EOF
 		return 0, nil, nil
 	}
 
+	if data[0] == '<' {
+		return splitTrailer(data, atEOF, trailer)
+	}
+
 	if i := bytes.IndexByte(data, ' '); i > 0 {
 		pLength := data[0:i]
 		length, err := strconv.Atoi(string(pLength))
 		if err != nil {
-			if string(data[0:1]) == "<" {
-				// Assume this frame uses non-transparent-framing
-				return len(data), data, nil
-			}
 			return 0, nil, err
 		}
+		if length <= 0 || pLength[0] < '0' || pLength[0] > '9' {
+			// Signed or zero
+			return 0, nil, ErrOctetCount
+		}
+		if length > rfc6587FrameSizeMax {
+			return 0, nil, ErrFrameTooLarge
+		}
 		end := length + i + 1
 		if len(data) >= end {
 			// Return the frame with the length removed
 			return end, data[i+1 : end], nil
 		}
+		if atEOF {
+			// The frame is cut short, discarded
+			return len(data), nil, io.ErrUnexpectedEOF
+		}
+	} else if atEOF {
+		if isOctetCount(data) {
+			// Only the count of a frame
+			return len(data), nil, io.ErrUnexpectedEOF
+		}
+		return len(data), data, nil
+	}
+
+	// Request more data
+	return 0, nil, nil
+}
+
+// Returns whether data is only made of digits
+func isOctetCount(data []byte) bool {
+	for _, b := range data {
+		if b < '0' || b > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Splits a frame of non-transparent framing
+func splitTrailer(data []byte, atEOF bool, trailer Trailer) (advance int, token []byte, err error) {
+	switch trailer {
+	case TrailerCRLF:
+		if i := bytes.Index(data, []byte("\r\n")); i >= 0 {
+			return i + 2, data[:i], nil
+		}
+	case TrailerNUL:
+		if i := bytes.IndexByte(data, 0); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+	default:
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			return i + 1, bytes.TrimSuffix(data[:i], []byte{'\r'}), nil
+		}
+	}
+	if atEOF {
+		// The last frame, without its trailer
+		return len(data), data, nil
 	}
 
 	// Request more data
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"strings"
 
 	. "gopkg.in/check.v1"
@@ -45,28 +46,139 @@ func (s *FormatSuite) TestRFC6587_GetSplitFuncMultiSplit(c *C) {
 	c.Assert(i, Equals, len(find))
 }
 
-func (s *FormatSuite) TestRFC6587_GetSplitFuncMultiSplitNonTransparentFraming(c *C) {
+func (s *FormatSuite) TestRFC6587_AppendOctetCounted(c *C) {
 	f := RFC6587{}
 
 	find := []string{
-		"<1> I am a test.",
-		"<2> I am a test 2.",
-		"<3> hahahah",
+		"I am test.",
+		"<34>1 - - - - - - caf\u00e9",
 	}
-	buf := new(bytes.Buffer)
+	var buf []byte
 	for _, i := range find {
-		fmt.Fprintf(buf, "%s", i)
+		buf = AppendOctetCounted(buf, []byte(i))
 	}
-	scanner := bufio.NewScanner(buf)
+	c.Assert(string(buf[:13]), Equals, "10 I am test.")
+
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
 	scanner.Split(f.GetSplitFunc())
 
 	i := 0
 	for scanner.Scan() {
-		c.Assert(scanner.Text(), Equals, strings.Join(find, ""))
+		c.Assert(scanner.Text(), Equals, find[i])
 		i++
 	}
 
-	c.Assert(i, Equals, 1)
+	c.Assert(i, Equals, len(find))
+}
+
+func (s *FormatSuite) TestRFC6587_GetSplitFuncMultiSplitNonTransparentFraming(c *C) {
+	find := []string{
+		"<1> I am a test.",
+		"<2> I am a test 2.",
+		"<3> hahahah",
+	}
+	for trailer, separator := range map[Trailer]string{TrailerLF: "\n", TrailerCRLF: "\r\n", TrailerNUL: "\x00"} {
+		f := RFC6587{Trailer: trailer}
+		buf := new(bytes.Buffer)
+		for _, i := range find {
+			fmt.Fprintf(buf, "%s%s", i, separator)
+		}
+		scanner := bufio.NewScanner(buf)
+		scanner.Split(f.GetSplitFunc())
+
+		i := 0
+		for scanner.Scan() {
+			c.Assert(scanner.Text(), Equals, find[i])
+			i++
+		}
+
+		c.Assert(i, Equals, len(find))
+	}
+}
+
+func (s *FormatSuite) TestRFC6587_GetSplitFuncTrailers(c *C) {
+	split := func(f *RFC6587, data string) []string {
+		scanner := bufio.NewScanner(strings.NewReader(data))
+		scanner.Split(f.GetSplitFunc())
+		var frames []string
+		for scanner.Scan() {
+			frames = append(frames, scanner.Text())
+		}
+		c.Assert(scanner.Err(), IsNil)
+		return frames
+	}
+
+	// A CR before LF is removed
+	c.Check(split(&RFC6587{}, "<1> a\r\n<2> b\n"), DeepEquals, []string{"<1> a", "<2> b"})
+	// LFs in CRLF framed messages
+	c.Check(split(&RFC6587{Trailer: TrailerCRLF}, "<1> a\n\tb\r\n<2> c\r\n"), DeepEquals, []string{"<1> a\n\tb", "<2> c"})
+	c.Check(split(&RFC6587{Trailer: TrailerNUL}, "<1> a\nb\x00<2> c"), DeepEquals, []string{"<1> a\nb", "<2> c"})
+}
+
+func (s *FormatSuite) TestRFC6587_GetSplitFuncMixedFraming(c *C) {
+	f := RFC6587{}
+
+	data := "5 <1> a<2> b\n\n9 <3> c\nd e\r\n<4> f\n5 <5> g"
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	scanner.Split(f.GetSplitFunc())
+	var frames []string
+	for scanner.Scan() {
+		frames = append(frames, scanner.Text())
+	}
+	c.Assert(scanner.Err(), IsNil)
+	c.Check(frames, DeepEquals, []string{"<1> a", "<2> b", "<3> c\nd e", "<4> f", "<5> g"})
+}
+
+func (s *FormatSuite) TestRFC6587_GetSplitFuncPartialFrames(c *C) {
+	for data, expected := range map[string]error{
+		"5 <1> a<2> b":    nil,                 // without trailer
+		"5 <1> a10 <2> b": io.ErrUnexpectedEOF, // cut short
+		"5 <1> a10":       io.ErrUnexpectedEOF, // count only
+	} {
+		f := RFC6587{}
+		scanner := bufio.NewScanner(strings.NewReader(data))
+		scanner.Split(f.GetSplitFunc())
+		var frames []string
+		for scanner.Scan() {
+			frames = append(frames, scanner.Text())
+		}
+		c.Check(scanner.Err(), Equals, expected, Commentf("%q", data))
+		if expected == nil {
+			c.Check(frames, DeepEquals, []string{"<1> a", "<2> b"}, Commentf("%q", data))
+		} else {
+			c.Check(frames, DeepEquals, []string{"<1> a"}, Commentf("%q", data))
+		}
+	}
+}
+
+func (s *FormatSuite) TestRFC6587_GetSplitFuncTrailersOnly(c *C) {
+	f := RFC6587{}
+	split := f.GetSplitFunc()
+	advance, token, err := split([]byte("\n"), false)
+	c.Check(advance, Equals, 1)
+	c.Check(token, IsNil)
+	c.Check(err, IsNil)
+
+	advance, token, err = split([]byte("5 <1> a\n"), false)
+	c.Assert(err, IsNil)
+	c.Check(string(token), Equals, "<1> a")
+	advance, token, err = split([]byte("5 <1> a\n")[advance:], false)
+	c.Check(advance, Equals, 1)
+	c.Check(token, IsNil)
+	c.Check(err, IsNil)
+
+	// Over a connection left open
+	reader, writer := io.Pipe()
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(split)
+	go writer.Write([]byte("5 <1> a\n"))
+	c.Assert(scanner.Scan(), Equals, true)
+	c.Check(scanner.Text(), Equals, "<1> a")
+	go writer.Write([]byte("5 <2> b"))
+	c.Assert(scanner.Scan(), Equals, true)
+	c.Check(scanner.Text(), Equals, "<2> b")
+	writer.Close()
+	c.Check(scanner.Scan(), Equals, false)
 }
 
 func (s *FormatSuite) TestRFC6587_GetSplitBadSplit(c *C) {
@@ -87,3 +199,20 @@ func (s *FormatSuite) TestRFC6587_GetSplitBadSplit(c *C) {
 	err := scanner.Err()
 	c.Assert(err, ErrorMatches, "strconv.*: parsing \".2\": invalid syntax")
 }
+
+func (s *FormatSuite) TestRFC6587_GetSplitFuncInvalidCount(c *C) {
+	for data, expected := range map[string]error{
+		"-5 <13>1 - host app - - - a":                  ErrOctetCount,
+		"+5 <13>1 - host app - - - a":                  ErrOctetCount,
+		"0 <13>1 - host app - - - a":                   ErrOctetCount,
+		"-0 <13>1 - host app - - - a":                  ErrOctetCount,
+		"99999999999 <13>1 - host app - - - a":         ErrFrameTooLarge,
+		"9223372036854775807 <13>1 - host app - - - a": ErrFrameTooLarge,
+	} {
+		f := RFC6587{}
+		scanner := bufio.NewScanner(strings.NewReader(data))
+		scanner.Split(f.GetSplitFunc())
+		c.Check(scanner.Scan(), Equals, false, Commentf("%q", data))
+		c.Check(scanner.Err(), Equals, expected, Commentf("%q", data))
+	}
+}
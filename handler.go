@@ -1,6 +1,10 @@
 package syslog
 
 import (
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
 )
 
@@ -9,6 +13,15 @@ type Handler interface {
 	Handle(format.LogParts, int64, error)
 }
 
+// The HandlerFunc type is an adapter to allow the use of ordinary functions as
+// handlers
+type HandlerFunc func(format.LogParts, int64, error)
+
+// Handle calls f(logParts, messageLength, err)
+func (f HandlerFunc) Handle(logParts format.LogParts, messageLength int64, err error) {
+	f(logParts, messageLength, err)
+}
+
 type LogPartsChannel chan format.LogParts
 
 //The ChannelHandler will send all the syslog entries into the given channel
@@ -33,3 +46,99 @@ func (h *ChannelHandler) SetChannel(channel LogPartsChannel) {
 func (h *ChannelHandler) Handle(logParts format.LogParts, messageLength int64, err error) {
 	h.channel <- logParts
 }
+
+// Message is a syslog entry along with the length of the message and the
+// error of its parsing, if any
+type Message struct {
+	LogParts format.LogParts
+	Length   int64
+	Err      error
+}
+
+type MessageChannel chan Message
+
+// SendMode defines what a MessageChannelHandler does when the channel is full
+type SendMode int
+
+const (
+	// SendBlock waits until the message is received
+	SendBlock SendMode = iota
+	// SendDrop drops the message
+	SendDrop
+	// SendTimeout waits for the message to be received, up to a timeout, and
+	// drops it then
+	SendTimeout
+)
+
+// The MessageChannelHandler sends all the syslog entries into the given
+// channel, as messages, and closes it when the server stops if the server is
+// set to close its handler, see Server.SetCloseHandlerOnStop
+type MessageChannelHandler struct {
+	channel MessageChannel
+	mode    SendMode
+	timeout time.Duration
+	dropped uint64
+	mu      sync.RWMutex
+	closed  bool
+}
+
+// NewMessageChannelHandler returns a new MessageChannelHandler, blocking until
+// the messages are received
+func NewMessageChannelHandler(channel MessageChannel) *MessageChannelHandler {
+	return &MessageChannelHandler{channel: channel}
+}
+
+// Sets what to do when the channel is full, the timeout being used by
+// SendTimeout only
+func (h *MessageChannelHandler) SetSendMode(mode SendMode, timeout time.Duration) {
+	h.mode = mode
+	h.timeout = timeout
+}
+
+// Dropped returns the number of messages dropped, because the channel was
+// full or closed
+func (h *MessageChannelHandler) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+//Syslog entry receiver
+func (h *MessageChannelHandler) Handle(logParts format.LogParts, messageLength int64, err error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.closed {
+		atomic.AddUint64(&h.dropped, 1)
+		return
+	}
+
+	msg := Message{LogParts: logParts, Length: messageLength, Err: err}
+	switch h.mode {
+	case SendDrop:
+		select {
+		case h.channel <- msg:
+		default:
+			atomic.AddUint64(&h.dropped, 1)
+		}
+	case SendTimeout:
+		timer := time.NewTimer(h.timeout)
+		defer timer.Stop()
+		select {
+		case h.channel <- msg:
+		case <-timer.C:
+			atomic.AddUint64(&h.dropped, 1)
+		}
+	default:
+		h.channel <- msg
+	}
+}
+
+// Close closes the channel, once the messages being sent are received. It is
+// called by Server.Wait when set to close the handler
+func (h *MessageChannelHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.closed {
+		h.closed = true
+		close(h.channel)
+	}
+	return nil
+}
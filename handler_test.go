@@ -1,6 +1,10 @@
 package syslog
 
 import (
+	"errors"
+	"net"
+	"time"
+
 	. "gopkg.in/check.v1"
 	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
 )
@@ -19,3 +23,108 @@ func (s *HandlerSuite) TestHandle(c *C) {
 	fromChan := <-channel
 	c.Check(fromChan["tag"], Equals, logPart["tag"])
 }
+
+func (s *HandlerSuite) TestHandlerFunc(c *C) {
+	var obtained format.LogParts
+	var obtainedLength int64
+	handler := HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+		obtained = logParts
+		obtainedLength = messageLength
+	})
+	handler.Handle(format.LogParts{"tag": "foo"}, 10, nil)
+
+	c.Check(obtained["tag"], Equals, "foo")
+	c.Check(obtainedLength, Equals, int64(10))
+}
+
+func (s *HandlerSuite) TestMessageChannelHandler(c *C) {
+	channel := make(MessageChannel, 1)
+	handler := NewMessageChannelHandler(channel)
+	parseErr := errors.New("parse error")
+	handler.Handle(format.LogParts{"tag": "foo"}, 10, parseErr)
+
+	msg := <-channel
+	c.Check(msg.LogParts["tag"], Equals, "foo")
+	c.Check(msg.Length, Equals, int64(10))
+	c.Check(msg.Err, Equals, parseErr)
+
+	c.Assert(handler.Close(), IsNil)
+	c.Assert(handler.Close(), IsNil)
+	handler.Handle(format.LogParts{}, 0, nil)
+	_, ok := <-channel
+	c.Check(ok, Equals, false)
+	c.Check(handler.Dropped(), Equals, uint64(1))
+}
+
+func (s *HandlerSuite) TestMessageChannelHandlerSendModes(c *C) {
+	channel := make(MessageChannel, 1)
+	handler := NewMessageChannelHandler(channel)
+
+	handler.SetSendMode(SendDrop, 0)
+	handler.Handle(format.LogParts{"n": 1}, 1, nil)
+	handler.Handle(format.LogParts{"n": 2}, 1, nil)
+	c.Check(handler.Dropped(), Equals, uint64(1))
+
+	handler.SetSendMode(SendTimeout, 10*time.Millisecond)
+	start := time.Now()
+	handler.Handle(format.LogParts{"n": 3}, 1, nil)
+	c.Check(time.Since(start) >= 10*time.Millisecond, Equals, true)
+	c.Check(handler.Dropped(), Equals, uint64(2))
+
+	c.Check((<-channel).LogParts["n"], Equals, 1)
+	handler.Handle(format.LogParts{"n": 4}, 1, nil)
+	c.Check((<-channel).LogParts["n"], Equals, 4)
+	c.Check(handler.Dropped(), Equals, uint64(2))
+}
+
+func (s *HandlerSuite) TestMessageChannelHandlerServer(c *C) {
+	channel := make(MessageChannel)
+	handler := NewMessageChannelHandler(channel)
+	server := NewServer()
+	server.SetFormat(RFC5424)
+	server.SetHandler(handler)
+	server.SetCloseHandlerOnStop(true)
+	c.Assert(server.ListenUDP("127.0.0.1:0"), IsNil)
+	c.Assert(server.Boot(), IsNil)
+
+	conn, err := net.Dial("udp", server.connections[0].LocalAddr().String())
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	_, err = conn.Write([]byte("<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed"))
+	c.Assert(err, IsNil)
+
+	msg := <-channel
+	c.Check(msg.Err, IsNil)
+	c.Check(msg.LogParts["app_name"], Equals, "su")
+
+	c.Assert(server.Kill(), IsNil)
+	done := make(chan struct{})
+	go func() {
+		for range channel {
+		}
+		close(done)
+	}()
+	server.Wait()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		c.Fatal("channel not closed")
+	}
+}
+
+func (s *HandlerSuite) TestHandlerNotClosedByDefault(c *C) {
+	channel := make(MessageChannel)
+	server := NewServer()
+	server.SetFormat(RFC5424)
+	server.SetHandler(NewMessageChannelHandler(channel))
+	c.Assert(server.ListenUDP("127.0.0.1:0"), IsNil)
+	c.Assert(server.Boot(), IsNil)
+	c.Assert(server.Kill(), IsNil)
+	server.Wait()
+
+	select {
+	case <-channel:
+		c.Fatal("channel closed")
+	default:
+	}
+}
@@ -0,0 +1,138 @@
+package syslog
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostnamePolicy selects what is used as the hostname of a message that does
+// not carry one (GNU style RFC3164 messages, RFC5424 NILVALUE hostnames, ...)
+type HostnamePolicy int
+
+const (
+	// Leave the hostname empty
+	HostnameEmpty HostnamePolicy = iota
+	// Use the IP address of the client (default)
+	HostnameSourceIP
+	// Use the reverse DNS name of the client, falls back to its IP address
+	HostnameReverseDNS
+	// Use the TLS peer name, falls back to the client IP address
+	HostnameTLSPeer
+)
+
+const (
+	hostnameCacheTTLDefault      = 5 * time.Minute
+	hostnameLookupTimeoutDefault = time.Second
+	hostnameCacheMaxEntries      = 4096
+)
+
+// Resolver performs reverse DNS lookups, it is satisfied by *net.Resolver
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) (names []string, err error)
+}
+
+type hostnameCacheEntry struct {
+	name    string
+	expires time.Time
+}
+
+// hostnameCache caches reverse DNS lookups, including failed ones so that an
+// unresolvable client does not cost a lookup timeout for every message
+type hostnameCache struct {
+	resolver Resolver
+	ttl      time.Duration
+	timeout  time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]hostnameCacheEntry
+}
+
+func newHostnameCache(resolver Resolver, ttl time.Duration, timeout time.Duration) *hostnameCache {
+	return &hostnameCache{
+		resolver: resolver,
+		ttl:      ttl,
+		timeout:  timeout,
+		now:      time.Now,
+		entries:  make(map[string]hostnameCacheEntry),
+	}
+}
+
+// Returns the name of the given IP address, or the address itself if it can
+// not be resolved in time
+func (c *hostnameCache) lookup(ip string) string {
+	now := c.now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[ip]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.name
+	}
+
+	name := ip
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	names, err := c.resolver.LookupAddr(ctx, ip)
+	cancel()
+	if err == nil && len(names) > 0 && names[0] != "" {
+		name = strings.TrimSuffix(names[0], ".")
+	}
+
+	c.mu.Lock()
+	if len(c.entries) >= hostnameCacheMaxEntries {
+		c.evict(now)
+	}
+	c.entries[ip] = hostnameCacheEntry{name: name, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+
+	return name
+}
+
+// Removes the expired entries, or an arbitrary half of them if none expired.
+// Must be called with the lock held
+func (c *hostnameCache) evict(now time.Time) {
+	for ip, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, ip)
+		}
+	}
+	for ip := range c.entries {
+		if len(c.entries) < hostnameCacheMaxEntries/2 {
+			break
+		}
+		delete(c.entries, ip)
+	}
+}
+
+// Returns the IP address part of a client address
+func clientIP(client string) string {
+	if host, _, err := net.SplitHostPort(client); err == nil {
+		return host
+	}
+	return client
+}
+
+// Returns the hostname to use for a message without one, according to the
+// hostname policy of the server
+func (s *Server) fallbackHostname(client string, tlsPeer string) string {
+	switch s.hostnamePolicy {
+	case HostnameSourceIP:
+		return clientIP(client)
+	case HostnameReverseDNS:
+		ip := clientIP(client)
+		if net.ParseIP(ip) == nil {
+			return ip
+		}
+		return s.hostnameCache.lookup(ip)
+	case HostnameTLSPeer:
+		if tlsPeer != "" {
+			return tlsPeer
+		}
+		return clientIP(client)
+	default:
+		return ""
+	}
+}
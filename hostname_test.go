@@ -0,0 +1,102 @@
+package syslog
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+type HostnameSuite struct{}
+
+var _ = Suite(&HostnameSuite{})
+
+var exampleSyslogGNU = "<13>May  1 20:51:40 myprogram: ciao"
+var exampleRFC5424SyslogNilHostname = "<34>1 2003-10-11T22:14:15.003Z - su - ID47 - 'su root' failed"
+
+type resolverStub struct {
+	names map[string]string
+	block bool
+	calls int
+}
+
+func (r *resolverStub) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	r.calls++
+	if r.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if name, ok := r.names[addr]; ok {
+		return []string{name}, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func (s *HostnameSuite) parse(c *C, f format.Format, policy HostnamePolicy, resolver Resolver, line string, client string, tlsPeer string) string {
+	handler := new(HandlerMock)
+	server := NewServer()
+	server.SetFormat(f)
+	server.SetHandler(handler)
+	server.SetHostnamePolicy(policy)
+	if resolver != nil {
+		server.SetHostnameResolver(resolver, time.Minute, 10*time.Millisecond)
+	}
+	server.parser([]byte(line), client, tlsPeer)
+	return handler.LastLogParts["hostname"].(string)
+}
+
+func (s *HostnameSuite) TestPolicyEmpty(c *C) {
+	c.Check(s.parse(c, RFC3164, HostnameEmpty, nil, exampleSyslogGNU, "10.0.0.1:514", ""), Equals, "")
+	c.Check(s.parse(c, RFC5424, HostnameEmpty, nil, exampleRFC5424SyslogNilHostname, "10.0.0.1:514", ""), Equals, "")
+	c.Check(s.parse(c, Automatic, HostnameEmpty, nil, exampleSyslog, "10.0.0.1:514", ""), Equals, "hostname")
+}
+
+func (s *HostnameSuite) TestPolicySourceIP(c *C) {
+	c.Check(s.parse(c, RFC3164, HostnameSourceIP, nil, exampleSyslogGNU, "10.0.0.1:514", ""), Equals, "10.0.0.1")
+	c.Check(s.parse(c, RFC5424, HostnameSourceIP, nil, exampleRFC5424SyslogNilHostname, "[2001:db8::1]:514", ""), Equals, "2001:db8::1")
+	c.Check(s.parse(c, Automatic, HostnameSourceIP, nil, exampleRFC5424SyslogNilHostname, "10.0.0.1:514", ""), Equals, "10.0.0.1")
+	c.Check(s.parse(c, Automatic, HostnameSourceIP, nil, exampleRFC5424Syslog, "10.0.0.1:514", ""), Equals, "mymachine.example.com")
+}
+
+func (s *HostnameSuite) TestPolicyReverseDNS(c *C) {
+	resolver := &resolverStub{names: map[string]string{"10.0.0.1": "router1.example.com."}}
+	c.Check(s.parse(c, RFC3164, HostnameReverseDNS, resolver, exampleSyslogGNU, "10.0.0.1:514", ""), Equals, "router1.example.com")
+	c.Check(s.parse(c, RFC5424, HostnameReverseDNS, resolver, exampleRFC5424SyslogNilHostname, "10.0.0.2:514", ""), Equals, "10.0.0.2")
+}
+
+func (s *HostnameSuite) TestPolicyReverseDNSTimeout(c *C) {
+	resolver := &resolverStub{block: true}
+	c.Check(s.parse(c, RFC3164, HostnameReverseDNS, resolver, exampleSyslogGNU, "10.0.0.1:514", ""), Equals, "10.0.0.1")
+}
+
+func (s *HostnameSuite) TestPolicyTLSPeer(c *C) {
+	c.Check(s.parse(c, RFC3164, HostnameTLSPeer, nil, exampleSyslogGNU, "10.0.0.1:6514", "dummycert1"), Equals, "dummycert1")
+	c.Check(s.parse(c, RFC3164, HostnameTLSPeer, nil, exampleSyslogGNU, "10.0.0.1:514", ""), Equals, "10.0.0.1")
+}
+
+func (s *HostnameSuite) TestCacheTTL(c *C) {
+	resolver := &resolverStub{names: map[string]string{"10.0.0.1": "router1"}}
+	cache := newHostnameCache(resolver, time.Minute, time.Second)
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	c.Check(cache.lookup("10.0.0.1"), Equals, "router1")
+	c.Check(cache.lookup("10.0.0.1"), Equals, "router1")
+	c.Check(resolver.calls, Equals, 1)
+
+	resolver.names["10.0.0.1"] = "router2"
+	now = now.Add(time.Minute)
+	c.Check(cache.lookup("10.0.0.1"), Equals, "router2")
+	c.Check(resolver.calls, Equals, 2)
+}
+
+func (s *HostnameSuite) TestCacheNegative(c *C) {
+	resolver := &resolverStub{}
+	cache := newHostnameCache(resolver, time.Minute, time.Second)
+
+	c.Check(cache.lookup("10.0.0.1"), Equals, "10.0.0.1")
+	c.Check(cache.lookup("10.0.0.1"), Equals, "10.0.0.1")
+	c.Check(resolver.calls, Equals, 1)
+}
@@ -0,0 +1,239 @@
+package cisco
+
+import (
+	"bytes"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/internal/syslogparser"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/internal/syslogparser/rfc3164"
+)
+
+var (
+	ErrNoMessageCode = &syslogparser.ParserError{ErrorString: "No Cisco message code found"}
+)
+
+// Parser parses the messages sent by Cisco IOS, NX-OS and ASA devices:
+//
+//	<189>123: router1: *Mar  1 18:46:11.123 UTC: %SYS-5-CONFIG_I: Configured from console
+//	<189>: 2019 Jan 11 10:00:00 UTC: %ETHPORT-5-IF_UP: Interface Ethernet1/1 is up
+//	<166>Jan 11 2019 10:00:00 asa01 : %ASA-6-302013: Built outbound TCP connection
+//
+// The sequence number, hostname and timestamp in front of the message code
+// are all optional
+type Parser struct {
+	buff     []byte
+	cursor   int
+	l        int
+	priority syslogparser.Priority
+	header   header
+	code     code
+	content  string
+	location *time.Location
+}
+
+type header struct {
+	sequence        string
+	hostname        string
+	timestamp       time.Time
+	timestampFormat string
+}
+
+// %FACILITY[-SUBFACILITY]-SEVERITY-MNEMONIC
+type code struct {
+	facility string
+	severity int
+	mnemonic string
+}
+
+func NewParser(buff []byte) *Parser {
+	return &Parser{
+		buff:     buff,
+		cursor:   0,
+		l:        len(buff),
+		location: time.UTC,
+	}
+}
+
+// Detect reports whether buff looks like a Cisco message, i.e. whether a
+// message code is only preceded by the optional Cisco header fields
+func Detect(buff []byte) bool {
+	cursor := 0
+	if _, err := syslogparser.ParsePriority(buff, &cursor, len(buff)); err != nil {
+		cursor = 0
+	}
+
+	from, _, _, ok := findCode(buff, cursor, len(buff))
+	if !ok {
+		return false
+	}
+
+	_, ok = parseHeader(buff[:from], cursor, time.UTC)
+	return ok
+}
+
+func (p *Parser) Location(location *time.Location) {
+	p.location = location
+}
+
+func (p *Parser) Parse() error {
+	tcursor := p.cursor
+	pri, err := p.parsePriority()
+	if err != nil {
+		// RFC3164 sec 4.3.3
+		pri = syslogparser.Priority{P: 13, F: syslogparser.Facility{Value: 1}, S: syslogparser.Severity{Value: 5}}
+		p.cursor = tcursor
+	}
+	p.priority = pri
+
+	from, to, c, ok := findCode(p.buff, p.cursor, p.l)
+	if !ok {
+		p.header.timestamp = time.Now().Round(time.Second)
+		p.content = string(bytes.Trim(p.buff[p.cursor:], " "))
+		return ErrNoMessageCode
+	}
+
+	hdr, _ := parseHeader(p.buff[:from], p.cursor, p.location)
+	if hdr.timestamp.IsZero() {
+		hdr.timestamp = time.Now().Round(time.Second)
+	}
+
+	p.header = hdr
+	p.code = c
+	p.cursor = to
+	p.content = string(bytes.Trim(p.buff[p.cursor:], " "))
+	p.cursor = p.l
+
+	return nil
+}
+
+func (p *Parser) Dump() syslogparser.LogParts {
+	return syslogparser.LogParts{
+		"priority":         p.priority.P,
+		"facility":         p.priority.F.Value,
+		"severity":         p.priority.S.Value,
+		"sequence":         p.header.sequence,
+		"hostname":         p.header.hostname,
+		"timestamp":        p.header.timestamp,
+		"timestamp_format": p.header.timestampFormat,
+		"cisco_facility":   p.code.facility,
+		"cisco_severity":   p.code.severity,
+		"cisco_mnemonic":   p.code.mnemonic,
+		"content":          p.content,
+	}
+}
+
+func (p *Parser) parsePriority() (syslogparser.Priority, error) {
+	return syslogparser.ParsePriority(p.buff, &p.cursor, p.l)
+}
+
+// Parses the header fields in buff[cursor:], which end right before the
+// message code. Fails if something else than a sequence number, a hostname
+// and timestamps is found
+func parseHeader(buff []byte, cursor int, location *time.Location) (header, bool) {
+	hdr := header{}
+	l := len(buff)
+
+	for {
+		for cursor < l && (buff[cursor] == ' ' || buff[cursor] == ':') {
+			cursor++
+		}
+		if cursor >= l {
+			return hdr, true
+		}
+
+		// The clock of the device is more accurate than the one of the
+		// syslog header, so the last timestamp wins
+		tcursor := cursor
+		if ts, layout, err := rfc3164.ParseTimestamp(buff, &cursor, l, location); err == nil {
+			hdr.timestamp = ts
+			hdr.timestampFormat = layout
+			continue
+		}
+		cursor = tcursor
+
+		to := cursor
+		for to < l && buff[to] != ' ' && buff[to] != ':' {
+			to++
+		}
+		token := buff[cursor:to]
+
+		if hdr.sequence == "" && to < l && buff[to] == ':' && isDigits(token) {
+			hdr.sequence = string(token)
+		} else if hdr.hostname == "" {
+			hdr.hostname = string(token)
+		} else {
+			return hdr, false
+		}
+
+		cursor = to
+	}
+}
+
+// Finds the first message code from cursor onwards, returns where it starts
+// and where the message text after it starts
+func findCode(buff []byte, cursor int, l int) (int, int, code, bool) {
+	for from := cursor; from < l; from++ {
+		if buff[from] != '%' || (from > cursor && buff[from-1] != ' ' && buff[from-1] != ':') {
+			continue
+		}
+		if to, c, ok := parseCode(buff, from, l); ok {
+			return from, to, c, true
+		}
+	}
+
+	return 0, 0, code{}, false
+}
+
+func parseCode(buff []byte, from int, l int) (int, code, bool) {
+	to := from + 1
+	for to < l && buff[to] != ':' && buff[to] != ' ' {
+		to++
+	}
+	if to >= l || buff[to] != ':' {
+		return 0, code{}, false
+	}
+
+	parts := bytes.Split(buff[from+1:to], []byte{'-'})
+	if len(parts) < 3 {
+		return 0, code{}, false
+	}
+	for _, part := range parts {
+		if len(part) == 0 || !isCodeChars(part) {
+			return 0, code{}, false
+		}
+	}
+
+	sev := parts[len(parts)-2]
+	if len(sev) != 1 || sev[0] < '0' || sev[0] > '7' {
+		return 0, code{}, false
+	}
+
+	c := code{
+		facility: string(bytes.Join(parts[:len(parts)-2], []byte{'-'})),
+		severity: int(sev[0] - '0'),
+		mnemonic: string(parts[len(parts)-1]),
+	}
+
+	return to + 1, c, true
+}
+
+func isCodeChars(b []byte) bool {
+	for _, c := range b {
+		if !(c >= 'A' && c <= 'Z') && !(c >= 'a' && c <= 'z') && !syslogparser.IsDigit(c) && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for _, c := range b {
+		if !syslogparser.IsDigit(c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+package cisco
+
+import (
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/internal/syslogparser"
+)
+
+// Hooks up gocheck into the gotest runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type CiscoTestSuite struct {
+}
+
+var _ = Suite(&CiscoTestSuite{})
+
+func (s *CiscoTestSuite) TestParser_Valid(c *C) {
+	y := time.Now().Year()
+
+	fixtures := []struct {
+		buff     string
+		expected syslogparser.LogParts
+	}{
+		{
+			"<189>123: router1: *Mar  1 18:46:11.123 UTC: %SYS-5-CONFIG_I: Configured from console by vty0 (10.0.0.1)",
+			syslogparser.LogParts{
+				"priority":         189,
+				"facility":         23,
+				"severity":         5,
+				"sequence":         "123",
+				"hostname":         "router1",
+				"timestamp":        time.Date(y, time.March, 1, 18, 46, 11, 123000000, time.UTC),
+				"timestamp_format": "*Jan _2 15:04:05.000 MST:",
+				"cisco_facility":   "SYS",
+				"cisco_severity":   5,
+				"cisco_mnemonic":   "CONFIG_I",
+				"content":          "Configured from console by vty0 (10.0.0.1)",
+			},
+		},
+		{
+			"<187>4567: .Jun 14 10:12:33.054: %LINK-3-UPDOWN: Interface GigabitEthernet0/1, changed state to down",
+			syslogparser.LogParts{
+				"priority":         187,
+				"facility":         23,
+				"severity":         3,
+				"sequence":         "4567",
+				"hostname":         "",
+				"timestamp":        time.Date(y, time.June, 14, 10, 12, 33, 54000000, time.UTC),
+				"timestamp_format": ".Jan _2 15:04:05.000:",
+				"cisco_facility":   "LINK",
+				"cisco_severity":   3,
+				"cisco_mnemonic":   "UPDOWN",
+				"content":          "Interface GigabitEthernet0/1, changed state to down",
+			},
+		},
+		{
+			"<189>Jun 14 10:12:33 10.0.0.1 88: Jun 14 10:12:33.054 CEST: %SEC_LOGIN-5-LOGIN_SUCCESS: Login Success [user: admin]",
+			syslogparser.LogParts{
+				"priority":         189,
+				"facility":         23,
+				"severity":         5,
+				"sequence":         "88",
+				"hostname":         "10.0.0.1",
+				"timestamp":        time.Date(y, time.June, 14, 10, 12, 33, 54000000, time.FixedZone("CEST", 2*3600)),
+				"timestamp_format": "Jan _2 15:04:05.000 MST:",
+				"cisco_facility":   "SEC_LOGIN",
+				"cisco_severity":   5,
+				"cisco_mnemonic":   "LOGIN_SUCCESS",
+				"content":          "Login Success [user: admin]",
+			},
+		},
+		{
+			"<189>: 2019 Jan 11 10:00:00 UTC: %ETHPORT-5-IF_UP: Interface Ethernet1/1 is up in mode access",
+			syslogparser.LogParts{
+				"priority":         189,
+				"facility":         23,
+				"severity":         5,
+				"sequence":         "",
+				"hostname":         "",
+				"timestamp":        time.Date(2019, time.January, 11, 10, 0, 0, 0, time.UTC),
+				"timestamp_format": "2006 Jan _2 15:04:05 MST:",
+				"cisco_facility":   "ETHPORT",
+				"cisco_severity":   5,
+				"cisco_mnemonic":   "IF_UP",
+				"content":          "Interface Ethernet1/1 is up in mode access",
+			},
+		},
+		{
+			"<190>2019 Jan 11 10:00:00 nx01 %VSHD-5-VSHD_SYSLOG_CONFIG_I: Configured from vty by admin on 10.0.0.5@pts/0",
+			syslogparser.LogParts{
+				"priority":         190,
+				"facility":         23,
+				"severity":         6,
+				"sequence":         "",
+				"hostname":         "nx01",
+				"timestamp":        time.Date(2019, time.January, 11, 10, 0, 0, 0, time.UTC),
+				"timestamp_format": "2006 Jan _2 15:04:05",
+				"cisco_facility":   "VSHD",
+				"cisco_severity":   5,
+				"cisco_mnemonic":   "VSHD_SYSLOG_CONFIG_I",
+				"content":          "Configured from vty by admin on 10.0.0.5@pts/0",
+			},
+		},
+		{
+			"<166>Jan 11 2019 10:00:00 asa01 : %ASA-6-302013: Built outbound TCP connection 1 for outside:10.0.0.1/443 (10.0.0.1/443) to inside:192.168.1.2/5000 (10.0.0.2/5000)",
+			syslogparser.LogParts{
+				"priority":         166,
+				"facility":         20,
+				"severity":         6,
+				"sequence":         "",
+				"hostname":         "asa01",
+				"timestamp":        time.Date(2019, time.January, 11, 10, 0, 0, 0, time.UTC),
+				"timestamp_format": "Jan _2 2006 15:04:05",
+				"cisco_facility":   "ASA",
+				"cisco_severity":   6,
+				"cisco_mnemonic":   "302013",
+				"content":          "Built outbound TCP connection 1 for outside:10.0.0.1/443 (10.0.0.1/443) to inside:192.168.1.2/5000 (10.0.0.2/5000)",
+			},
+		},
+		{
+			"<164>Jan 11 2019 10:00:00: %ASA-4-106023: Deny tcp src outside:10.0.0.1/1234 dst inside:10.0.0.2/22",
+			syslogparser.LogParts{
+				"priority":         164,
+				"facility":         20,
+				"severity":         4,
+				"sequence":         "",
+				"hostname":         "",
+				"timestamp":        time.Date(2019, time.January, 11, 10, 0, 0, 0, time.UTC),
+				"timestamp_format": "Jan _2 2006 15:04:05:",
+				"cisco_facility":   "ASA",
+				"cisco_severity":   4,
+				"cisco_mnemonic":   "106023",
+				"content":          "Deny tcp src outside:10.0.0.1/1234 dst inside:10.0.0.2/22",
+			},
+		},
+		{
+			"<189>12: %PLATFORM-ENV-1-FAN: Fan tray failure",
+			syslogparser.LogParts{
+				"priority":         189,
+				"facility":         23,
+				"severity":         5,
+				"sequence":         "12",
+				"hostname":         "",
+				"timestamp":        nil,
+				"timestamp_format": "",
+				"cisco_facility":   "PLATFORM-ENV",
+				"cisco_severity":   1,
+				"cisco_mnemonic":   "FAN",
+				"content":          "Fan tray failure",
+			},
+		},
+	}
+
+	for _, f := range fixtures {
+		p := NewParser([]byte(f.buff))
+		err := p.Parse()
+		c.Assert(err, IsNil, Commentf("%s", f.buff))
+
+		obtained := p.Dump()
+		if f.expected["timestamp"] == nil {
+			s.assertTimeIsCloseToNow(c, obtained["timestamp"].(time.Time))
+			obtained["timestamp"] = nil
+		}
+		c.Check(obtained, DeepEquals, f.expected, Commentf("%s", f.buff))
+	}
+}
+
+func (s *CiscoTestSuite) TestParser_NoMessageCode(c *C) {
+	buff := []byte("<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8")
+
+	p := NewParser(buff)
+	c.Assert(p.Parse(), Equals, ErrNoMessageCode)
+	c.Check(p.Dump()["priority"], Equals, 34)
+	c.Check(p.Dump()["content"], Equals, "Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8")
+}
+
+func (s *CiscoTestSuite) TestDetect(c *C) {
+	fixtures := map[string]bool{
+		"<189>123: router1: *Mar  1 18:46:11.123 UTC: %SYS-5-CONFIG_I: Configured from console": true,
+		"<166>%ASA-6-302013: Built outbound TCP connection":                                     true,
+		"%ASA-6-302013: Built outbound TCP connection":                                          true,
+		"<34>Oct 11 22:14:15 mymachine su: 'su root' failed":                                    false,
+		"<34>Oct 11 22:14:15 mymachine su: relayed %SYS-5-CONFIG_I: Configured":                 false,
+		"<165>1 2003-10-11T22:14:15.003Z host app - - - %SYS-5-CONFIG_I: Configured":            false,
+		"<189>123: router1: %SYS-9-CONFIG_I: Configured":                                        false,
+		"<189>123: router1: %SYS-CONFIG_I: Configured":                                          false,
+	}
+
+	for buff, expected := range fixtures {
+		c.Check(Detect([]byte(buff)), Equals, expected, Commentf("%s", buff))
+	}
+}
+
+func (s *CiscoTestSuite) TestParser_Location(c *C) {
+	loc := time.FixedZone("test", 3600)
+	buff := []byte("<189>123: *Mar  1 18:46:11: %SYS-5-CONFIG_I: Configured from console")
+
+	p := NewParser(buff)
+	p.Location(loc)
+	c.Assert(p.Parse(), IsNil)
+	c.Check(p.Dump()["timestamp"], Equals, time.Date(time.Now().Year(), time.March, 1, 18, 46, 11, 0, loc))
+}
+
+func (s *CiscoTestSuite) assertTimeIsCloseToNow(c *C, obtainedTime time.Time) {
+	now := time.Now()
+	timeStart := now.Add(-(time.Second * 5))
+	timeEnd := now.Add(time.Second)
+	c.Assert(obtainedTime.After(timeStart), Equals, true)
+	c.Assert(obtainedTime.Before(timeEnd), Equals, true)
+}
@@ -0,0 +1,20 @@
+package cisco_test
+
+import (
+	"fmt"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/internal/syslogparser/cisco"
+)
+
+func ExampleNewParser() {
+	b := "<189>123: router1: *Mar  1 18:46:11.123 UTC: %SYS-5-CONFIG_I: Configured from console"
+	buff := []byte(b)
+
+	p := cisco.NewParser(buff)
+	err := p.Parse()
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(p.Dump())
+}
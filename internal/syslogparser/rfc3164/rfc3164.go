@@ -2,7 +2,6 @@ package rfc3164
 
 import (
 	"bytes"
-	"os"
 	"time"
 
 	"gopkg.in/sleepinggenius2/go-syslog.v2/internal/syslogparser"
@@ -18,6 +17,8 @@ type Parser struct {
 	message  rfc3164message
 	location *time.Location
 	skipTag  bool
+
+	timestampFormat string
 }
 
 type header struct {
@@ -89,13 +90,14 @@ func (p *Parser) Parse() error {
 
 func (p *Parser) Dump() syslogparser.LogParts {
 	return syslogparser.LogParts{
-		"timestamp": p.header.timestamp,
-		"hostname":  p.header.hostname,
-		"tag":       p.message.tag,
-		"content":   p.message.content,
-		"priority":  p.priority.P,
-		"facility":  p.priority.F.Value,
-		"severity":  p.priority.S.Value,
+		"timestamp":        p.header.timestamp,
+		"timestamp_format": p.timestampFormat,
+		"hostname":         p.header.hostname,
+		"tag":              p.message.tag,
+		"content":          p.message.content,
+		"priority":         p.priority.P,
+		"facility":         p.priority.F.Value,
+		"severity":         p.priority.S.Value,
 	}
 }
 
@@ -147,72 +149,19 @@ func (p *Parser) parsemessage() (rfc3164message, error) {
 
 // https://tools.ietf.org/html/rfc3164#section-4.1.2
 func (p *Parser) parseTimestamp() (time.Time, error) {
-	var ts time.Time
-	var err error
-	var tsFmtLen int
-	var sub []byte
-
-	tsFmts := []string{
-		time.Stamp,
-		time.RFC3339,
-	}
-	// if timestamps starts with numeric try formats with different order
-	// it is more likely that timestamp is in RFC3339 format then
-	if c := p.buff[p.cursor]; c > '0' && c < '9' {
-		tsFmts = []string{
-			time.RFC3339,
-			time.Stamp,
-		}
-	}
-
-	found := false
-	for _, tsFmt := range tsFmts {
-		tsFmtLen = len(tsFmt)
-
-		if p.cursor+tsFmtLen > p.l {
-			continue
-		}
+	ts, layout, err := ParseTimestamp(p.buff, &p.cursor, p.l, p.location)
+	p.timestampFormat = layout
 
-		sub = p.buff[p.cursor : tsFmtLen+p.cursor]
-		ts, err = time.ParseInLocation(tsFmt, string(sub), p.location)
-		if err == nil {
-			found = true
-			break
-		}
-	}
-
-	if !found {
-		p.cursor = len(time.Stamp)
-
-		// XXX : If the timestamp is invalid we try to push the cursor one byte
-		// XXX : further, in case it is a space
-		if (p.cursor < p.l) && (p.buff[p.cursor] == ' ') {
-			p.cursor++
-		}
-
-		return ts, syslogparser.ErrTimestampUnknownFormat
-	}
-
-	fixTimestampIfNeeded(&ts)
-
-	p.cursor += tsFmtLen
-
-	if (p.cursor < p.l) && (p.buff[p.cursor] == ' ') {
-		p.cursor++
-	}
-
-	return ts, nil
+	return ts, err
 }
 
 func (p *Parser) parseHostname() (string, error) {
 	oldcursor := p.cursor
 	hostname, err := syslogparser.ParseHostname(p.buff, &p.cursor, p.l)
 	if err == nil && len(hostname) > 0 && string(hostname[len(hostname)-1]) == ":" { // not an hostname! we found a GNU implementation of syslog()
+		// The hostname is left empty, it is up to the receiver to decide
+		// what to use instead (see syslog.HostnamePolicy)
 		p.cursor = oldcursor - 1
-		myhostname, err := os.Hostname()
-		if err == nil {
-			return myhostname, nil
-		}
 		return "", nil
 	}
 	return hostname, err
@@ -275,17 +224,3 @@ func (p *Parser) parseContent() (string, error) {
 
 	return string(content), syslogparser.ErrEOL
 }
-
-func fixTimestampIfNeeded(ts *time.Time) {
-	now := time.Now()
-	y := ts.Year()
-
-	if ts.Year() == 0 {
-		y = now.Year()
-	}
-
-	newTs := time.Date(y, ts.Month(), ts.Day(), ts.Hour(), ts.Minute(),
-		ts.Second(), ts.Nanosecond(), ts.Location())
-
-	*ts = newTs
-}
@@ -43,13 +43,14 @@ func (s *Rfc3164TestSuite) TestParser_Valid(c *C) {
 
 	obtained := p.Dump()
 	expected := syslogparser.LogParts{
-		"timestamp": time.Date(now.Year(), time.October, 11, 22, 14, 15, 0, time.UTC),
-		"hostname":  "mymachine",
-		"tag":       "very.large.syslog.message.tag",
-		"content":   "'su root' failed for lonvick on /dev/pts/8",
-		"priority":  34,
-		"facility":  4,
-		"severity":  2,
+		"timestamp":        time.Date(now.Year(), time.October, 11, 22, 14, 15, 0, time.UTC),
+		"timestamp_format": time.Stamp,
+		"hostname":         "mymachine",
+		"tag":              "very.large.syslog.message.tag",
+		"content":          "'su root' failed for lonvick on /dev/pts/8",
+		"priority":         34,
+		"facility":         4,
+		"severity":         2,
 	}
 
 	c.Assert(obtained, DeepEquals, expected)
@@ -75,13 +76,14 @@ func (s *Rfc3164TestSuite) TestParser_ValidNoTag(c *C) {
 
 	obtained := p.Dump()
 	expected := syslogparser.LogParts{
-		"timestamp": time.Date(now.Year(), time.October, 11, 22, 14, 15, 0, time.UTC),
-		"hostname":  "mymachine",
-		"tag":       "",
-		"content":   "singleword",
-		"priority":  34,
-		"facility":  4,
-		"severity":  2,
+		"timestamp":        time.Date(now.Year(), time.October, 11, 22, 14, 15, 0, time.UTC),
+		"timestamp_format": time.Stamp,
+		"hostname":         "mymachine",
+		"tag":              "",
+		"content":          "singleword",
+		"priority":         34,
+		"facility":         4,
+		"severity":         2,
 	}
 
 	c.Assert(obtained, DeepEquals, expected)
@@ -113,13 +115,14 @@ func (s *Rfc3164TestSuite) TestParser_NoTimestamp(c *C) {
 
 	obtained["timestamp"] = now // XXX: Need to mock out time to test this fully
 	expected := syslogparser.LogParts{
-		"timestamp": now,
-		"hostname":  "",
-		"tag":       "",
-		"content":   "INFO     leaving (1) step postscripts",
-		"priority":  14,
-		"facility":  1,
-		"severity":  6,
+		"timestamp":        now,
+		"timestamp_format": "",
+		"hostname":         "",
+		"tag":              "",
+		"content":          "INFO     leaving (1) step postscripts",
+		"priority":         14,
+		"facility":         1,
+		"severity":         6,
 	}
 
 	c.Assert(obtained, DeepEquals, expected)
@@ -150,13 +153,14 @@ func (s *Rfc3164TestSuite) TestParser_NoPriority(c *C) {
 
 	obtained["timestamp"] = now // XXX: Need to mock out time to test this fully
 	expected := syslogparser.LogParts{
-		"timestamp": now,
-		"hostname":  "",
-		"tag":       "",
-		"content":   "Oct 11 22:14:15 Testing no priority",
-		"priority":  13,
-		"facility":  1,
-		"severity":  5,
+		"timestamp":        now,
+		"timestamp_format": "",
+		"hostname":         "",
+		"tag":              "",
+		"content":          "Oct 11 22:14:15 Testing no priority",
+		"priority":         13,
+		"facility":         1,
+		"severity":         5,
 	}
 
 	c.Assert(obtained, DeepEquals, expected)
@@ -202,13 +206,14 @@ func (s *Rfc3164TestSuite) TestParser_ValidRFC3339Timestamp(c *C) {
 	c.Assert(err, IsNil)
 	obtained := p.Dump()
 	expected := syslogparser.LogParts{
-		"timestamp": time.Date(2018, time.January, 12, 22, 14, 15, 0, time.UTC),
-		"hostname":  "mymachine",
-		"tag":       "app",
-		"content":   "msg",
-		"priority":  34,
-		"facility":  4,
-		"severity":  2,
+		"timestamp":        time.Date(2018, time.January, 12, 22, 14, 15, 0, time.UTC),
+		"timestamp_format": time.RFC3339,
+		"hostname":         "mymachine",
+		"tag":              "app",
+		"content":          "msg",
+		"priority":         34,
+		"facility":         4,
+		"severity":         2,
 	}
 	c.Assert(obtained, DeepEquals, expected)
 }
@@ -0,0 +1,347 @@
+package rfc3164
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/internal/syslogparser"
+)
+
+// Layouts of the timestamps with a numeric date, tried in order. Fractional
+// seconds are accepted by all of them
+var numericLayouts = []string{
+	time.RFC3339,               // RSYSLOG_ForwardFormat and friends
+	"2006-01-02T15:04:05Z0700", // ISO8601 with a basic offset
+	"2006-01-02T15:04:05",      // ISO8601 in local time
+}
+
+// Time zone abbreviations commonly appended to timestamps by network devices.
+// Only well-known abbreviations are accepted, so that a hostname following
+// the timestamp is not mistaken for a time zone
+var zoneOffsets = map[string]int{
+	"UTC":  0,
+	"GMT":  0,
+	"WET":  0,
+	"BST":  1 * 3600,
+	"WEST": 1 * 3600,
+	"CET":  1 * 3600,
+	"CEST": 2 * 3600,
+	"EET":  2 * 3600,
+	"EEST": 3 * 3600,
+	"MSK":  3 * 3600,
+	"IST":  5*3600 + 1800,
+	"HKT":  8 * 3600,
+	"SGT":  8 * 3600,
+	"JST":  9 * 3600,
+	"KST":  9 * 3600,
+	"AEST": 10 * 3600,
+	"AEDT": 11 * 3600,
+	"NZST": 12 * 3600,
+	"NZDT": 13 * 3600,
+	"AST":  -4 * 3600,
+	"ADT":  -3 * 3600,
+	"EST":  -5 * 3600,
+	"EDT":  -4 * 3600,
+	"CST":  -6 * 3600,
+	"CDT":  -5 * 3600,
+	"MST":  -7 * 3600,
+	"MDT":  -6 * 3600,
+	"PST":  -8 * 3600,
+	"PDT":  -7 * 3600,
+	"AKST": -9 * 3600,
+	"AKDT": -8 * 3600,
+	"HST":  -10 * 3600,
+}
+
+// ParseTimestamp parses the RFC3164 timestamp at the cursor, along with the
+// common non-standard variants:
+//
+//	Jan _2 15:04:05                 RFC3164
+//	Jan _2 2006 15:04:05            year after the day
+//	Jan _2 15:04:05.000             fractional seconds
+//	Jan _2 15:04:05 MST             time zone abbreviation
+//	*Jan _2 15:04:05.000 MST:       Cisco, with the '*' or '.' sync marker
+//	2006 Jan _2 15:04:05 MST        Cisco NX-OS, year first
+//	2006-01-02T15:04:05.000Z07:00   RFC3339 / ISO8601
+//
+// It returns the time along with the layout of the detected variant. Time
+// stamps without a time zone are in loc, and without a year in the current one
+func ParseTimestamp(buff []byte, cursor *int, l int, loc *time.Location) (time.Time, string, error) {
+	var ts time.Time
+	var layout string
+	var to int
+	var err error
+
+	from := *cursor
+	if from < l && syslogparser.IsDigit(buff[from]) {
+		ts, layout, to, err = parseNumericTimestamp(buff, from, l, loc)
+	} else {
+		ts, layout, to, err = parseStampTimestamp(buff, from, l, loc, 0)
+	}
+
+	if err != nil {
+		*cursor = from + len(time.Stamp)
+
+		// XXX : If the timestamp is invalid we try to push the cursor one byte
+		// XXX : further, in case it is a space
+		if (*cursor < l) && (buff[*cursor] == ' ') {
+			*cursor++
+		}
+
+		return ts, "", err
+	}
+
+	fixTimestampIfNeeded(&ts)
+
+	*cursor = to
+
+	if (*cursor < l) && (buff[*cursor] == ' ') {
+		*cursor++
+	}
+
+	return ts, layout, nil
+}
+
+func parseNumericTimestamp(buff []byte, from int, l int, loc *time.Location) (time.Time, string, int, error) {
+	// Year first, as sent by NX-OS
+	if from+5 < l && isDigits(buff[from:from+4]) && buff[from+4] == ' ' {
+		year, _ := strconv.Atoi(string(buff[from : from+4]))
+		ts, layout, to, err := parseStampTimestamp(buff, from+5, l, loc, year)
+		return ts, "2006 " + layout, to, err
+	}
+
+	to := from
+	for to < l && buff[to] != ' ' {
+		to++
+	}
+
+	end := to
+	suffix := ""
+	if end > from && buff[end-1] == ':' {
+		end--
+		suffix = ":"
+	}
+
+	value := string(buff[from:end])
+	for _, layout := range numericLayouts {
+		ts, err := time.ParseInLocation(layout, value, loc)
+		if err == nil {
+			return ts, withFraction(layout, value) + suffix, to, nil
+		}
+	}
+
+	return time.Time{}, "", from, syslogparser.ErrTimestampUnknownFormat
+}
+
+// Parses a "Jan _2 15:04:05" timestamp along with its variants. A non-zero
+// year means it was already found in front of the timestamp
+func parseStampTimestamp(buff []byte, from int, l int, loc *time.Location, year int) (time.Time, string, int, error) {
+	var ts time.Time
+	var layout []byte
+
+	cursor := from
+
+	// Cisco: '*' time is not authoritative, '.' time was synchronized but
+	// the connection was lost since
+	if cursor < l && (buff[cursor] == '*' || buff[cursor] == '.') {
+		layout = append(layout, buff[cursor])
+		cursor++
+	}
+
+	month, ok := parseMonthName(buff, cursor, l)
+	if !ok {
+		return ts, "", from, syslogparser.ErrTimestampUnknownFormat
+	}
+	cursor += 3
+
+	if cursor >= l || buff[cursor] != ' ' {
+		return ts, "", from, syslogparser.ErrTimestampUnknownFormat
+	}
+	cursor++
+
+	day, ok := parseDay(buff, &cursor, l)
+	if !ok {
+		return ts, "", from, syslogparser.ErrTimestampUnknownFormat
+	}
+	layout = append(layout, "Jan _2 "...)
+
+	if cursor >= l || buff[cursor] != ' ' {
+		return ts, "", from, syslogparser.ErrTimestampUnknownFormat
+	}
+	cursor++
+
+	if year == 0 && cursor+5 <= l && isDigits(buff[cursor:cursor+4]) && buff[cursor+4] == ' ' {
+		year, _ = strconv.Atoi(string(buff[cursor : cursor+4]))
+		cursor += 5
+		layout = append(layout, "2006 "...)
+	}
+
+	hour, minute, second, err := parseTime(buff, &cursor, l)
+	if err != nil {
+		return ts, "", from, syslogparser.ErrTimestampUnknownFormat
+	}
+	layout = append(layout, "15:04:05"...)
+
+	nsec := 0
+	if cursor+1 < l && buff[cursor] == '.' && syslogparser.IsDigit(buff[cursor+1]) {
+		cursor++
+		digits := 0
+		for ; cursor < l && syslogparser.IsDigit(buff[cursor]); cursor++ {
+			// digits past the nanosecond are ignored
+			if digits < 9 {
+				nsec = nsec*10 + int(buff[cursor]-'0')
+				digits++
+			}
+		}
+		for i := digits; i < 9; i++ {
+			nsec *= 10
+		}
+		layout = append(layout, '.')
+		layout = append(layout, strings.Repeat("0", digits)...)
+	}
+
+	if zone, to, ok := parseZone(buff, cursor, l); ok {
+		loc = zone
+		cursor = to
+		layout = append(layout, " MST"...)
+	}
+
+	if cursor < l && buff[cursor] == ':' {
+		cursor++
+		layout = append(layout, ':')
+	}
+
+	ts = time.Date(year, month, day, hour, minute, second, nsec, loc)
+
+	return ts, string(layout), cursor, nil
+}
+
+func parseMonthName(buff []byte, cursor int, l int) (time.Month, bool) {
+	if cursor+3 > l {
+		return 0, false
+	}
+
+	name := string(buff[cursor : cursor+3])
+	for m := time.January; m <= time.December; m++ {
+		if strings.EqualFold(name, m.String()[:3]) {
+			return m, true
+		}
+	}
+
+	return 0, false
+}
+
+// Accepts "_2", "02" and a single digit
+func parseDay(buff []byte, cursor *int, l int) (int, bool) {
+	if *cursor < l && buff[*cursor] == ' ' {
+		*cursor++
+	}
+
+	day := 0
+	digits := 0
+	for ; digits < 2 && *cursor < l && syslogparser.IsDigit(buff[*cursor]); digits++ {
+		day = day*10 + int(buff[*cursor]-'0')
+		*cursor++
+	}
+
+	return day, digits > 0 && day >= 1 && day <= 31
+}
+
+func parseTime(buff []byte, cursor *int, l int) (int, int, int, error) {
+	hour, err := syslogparser.Parse2Digits(buff, cursor, l, 0, 23, syslogparser.ErrTimestampUnknownFormat)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	if *cursor >= l || buff[*cursor] != ':' {
+		return 0, 0, 0, syslogparser.ErrTimestampUnknownFormat
+	}
+	*cursor++
+
+	minute, err := syslogparser.Parse2Digits(buff, cursor, l, 0, 59, syslogparser.ErrTimestampUnknownFormat)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	if *cursor >= l || buff[*cursor] != ':' {
+		return 0, 0, 0, syslogparser.ErrTimestampUnknownFormat
+	}
+	*cursor++
+
+	// 60 allows for leap seconds
+	second, err := syslogparser.Parse2Digits(buff, cursor, l, 0, 60, syslogparser.ErrTimestampUnknownFormat)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return hour, minute, second, nil
+}
+
+// Parses a " MST" time zone abbreviation, which must be followed by a space,
+// a colon or the end of the line
+func parseZone(buff []byte, cursor int, l int) (*time.Location, int, bool) {
+	if cursor >= l || buff[cursor] != ' ' {
+		return nil, cursor, false
+	}
+
+	to := cursor + 1
+	for to < l && buff[to] >= 'A' && buff[to] <= 'Z' {
+		to++
+	}
+
+	if to < l && buff[to] != ' ' && buff[to] != ':' {
+		return nil, cursor, false
+	}
+
+	abbr := string(buff[cursor+1 : to])
+	offset, ok := zoneOffsets[abbr]
+	if !ok {
+		return nil, cursor, false
+	}
+
+	if abbr == "UTC" {
+		return time.UTC, to, true
+	}
+
+	return time.FixedZone(abbr, offset), to, true
+}
+
+// Adds the fractional seconds of value, if any, to a layout whose seconds end
+// at the same offset
+func withFraction(layout string, value string) string {
+	i := strings.Index(layout, "05") + 2
+	if i >= len(value) || value[i] != '.' {
+		return layout
+	}
+
+	digits := 0
+	for j := i + 1; j < len(value) && syslogparser.IsDigit(value[j]); j++ {
+		digits++
+	}
+
+	return layout[:i] + "." + strings.Repeat("0", digits) + layout[i:]
+}
+
+func isDigits(b []byte) bool {
+	for _, c := range b {
+		if !syslogparser.IsDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func fixTimestampIfNeeded(ts *time.Time) {
+	now := time.Now()
+	y := ts.Year()
+
+	if ts.Year() == 0 {
+		y = now.Year()
+	}
+
+	newTs := time.Date(y, ts.Month(), ts.Day(), ts.Hour(), ts.Minute(),
+		ts.Second(), ts.Nanosecond(), ts.Location())
+
+	*ts = newTs
+}
@@ -0,0 +1,103 @@
+package rfc3164
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/internal/syslogparser"
+)
+
+func (s *Rfc3164TestSuite) TestParseTimestamp_Variants(c *C) {
+	y := time.Now().Year()
+	cet := time.FixedZone("CET", 3600)
+	plus2 := time.FixedZone("", 2*3600)
+
+	fixtures := []struct {
+		buff   string
+		ts     time.Time
+		layout string
+		cursor int
+	}{
+		{"Oct 11 22:14:15 host", time.Date(y, time.October, 11, 22, 14, 15, 0, time.UTC), time.Stamp, 16},
+		{"Oct  1 22:14:15 host", time.Date(y, time.October, 1, 22, 14, 15, 0, time.UTC), time.Stamp, 16},
+		{"Oct 1 22:14:15 host", time.Date(y, time.October, 1, 22, 14, 15, 0, time.UTC), time.Stamp, 15},
+		{"oct 11 22:14:15", time.Date(y, time.October, 11, 22, 14, 15, 0, time.UTC), time.Stamp, 15},
+		// year after the day
+		{"Oct 11 2018 22:14:15 host", time.Date(2018, time.October, 11, 22, 14, 15, 0, time.UTC), "Jan _2 2006 15:04:05", 21},
+		// fractional seconds
+		{"Oct 11 22:14:15.123 host", time.Date(y, time.October, 11, 22, 14, 15, 123000000, time.UTC), "Jan _2 15:04:05.000", 20},
+		{"Oct 11 22:14:15.123456789123 host", time.Date(y, time.October, 11, 22, 14, 15, 123456789, time.UTC), "Jan _2 15:04:05.000000000", 29},
+		// time zone abbreviations
+		{"Oct 11 22:14:15 CET host", time.Date(y, time.October, 11, 22, 14, 15, 0, cet), "Jan _2 15:04:05 MST", 20},
+		{"Oct 11 2018 22:14:15.5 UTC host", time.Date(2018, time.October, 11, 22, 14, 15, 500000000, time.UTC), "Jan _2 2006 15:04:05.0 MST", 27},
+		{"Oct 11 22:14:15 HOST app", time.Date(y, time.October, 11, 22, 14, 15, 0, time.UTC), time.Stamp, 16},
+		// Cisco
+		{"*Mar  1 18:46:11.123: %SYS", time.Date(y, time.March, 1, 18, 46, 11, 123000000, time.UTC), "*Jan _2 15:04:05.000:", 22},
+		{".Mar  1 18:46:11.123 UTC: %SYS", time.Date(y, time.March, 1, 18, 46, 11, 123000000, time.UTC), ".Jan _2 15:04:05.000 MST:", 26},
+		{"Mar  1 18:46:11: %SYS", time.Date(y, time.March, 1, 18, 46, 11, 0, time.UTC), "Jan _2 15:04:05:", 17},
+		{"2019 Jan 11 10:00:00 UTC: %ETH", time.Date(2019, time.January, 11, 10, 0, 0, 0, time.UTC), "2006 Jan _2 15:04:05 MST:", 26},
+		// RFC3339 / ISO8601
+		{"2018-01-12T22:14:15+00:00 host", time.Date(2018, time.January, 12, 22, 14, 15, 0, time.UTC), time.RFC3339, 26},
+		{"2018-01-12T22:14:15.003Z host", time.Date(2018, time.January, 12, 22, 14, 15, 3000000, time.UTC), "2006-01-02T15:04:05.000Z07:00", 25},
+		{"2018-01-12T22:14:15.123456+02:00 host", time.Date(2018, time.January, 12, 22, 14, 15, 123456000, plus2), "2006-01-02T15:04:05.000000Z07:00", 33},
+		{"2018-01-12T22:14:15+0200 host", time.Date(2018, time.January, 12, 22, 14, 15, 0, plus2), "2006-01-02T15:04:05Z0700", 25},
+		{"2018-01-12T22:14:15 host", time.Date(2018, time.January, 12, 22, 14, 15, 0, time.UTC), "2006-01-02T15:04:05", 20},
+	}
+
+	for _, f := range fixtures {
+		buff := []byte(f.buff)
+		cursor := 0
+		ts, layout, err := ParseTimestamp(buff, &cursor, len(buff), time.UTC)
+		c.Assert(err, IsNil, Commentf("%s", f.buff))
+		c.Check(ts.Equal(f.ts), Equals, true, Commentf("%s: %s != %s", f.buff, ts, f.ts))
+		_, offset := ts.Zone()
+		_, expectedOffset := f.ts.Zone()
+		c.Check(offset, Equals, expectedOffset, Commentf("%s", f.buff))
+		c.Check(layout, Equals, f.layout, Commentf("%s", f.buff))
+		c.Check(cursor, Equals, f.cursor, Commentf("%s", f.buff))
+	}
+}
+
+func (s *Rfc3164TestSuite) TestParseTimestamp_InvalidVariants(c *C) {
+	fixtures := []string{
+		"Foo 11 22:14:15",
+		"Oct 32 22:14:15",
+		"Oct 11 24:14:15",
+		"Oct 11 22:14",
+		"123: router1: *Mar  1 18:46:11",
+		"2018-13-12T22:14:15Z",
+		"INFO     leaving",
+	}
+
+	for _, f := range fixtures {
+		buff := []byte(f)
+		cursor := 0
+		_, layout, err := ParseTimestamp(buff, &cursor, len(buff), time.UTC)
+		c.Check(err, Equals, syslogparser.ErrTimestampUnknownFormat, Commentf("%s", f))
+		c.Check(layout, Equals, "", Commentf("%s", f))
+	}
+}
+
+func (s *Rfc3164TestSuite) TestParser_TimestampLocation(c *C) {
+	buff := []byte("<34>Oct 11 22:14:15 mymachine app: msg")
+	loc := time.FixedZone("test", -3600)
+
+	p := NewParser(buff)
+	p.Location(loc)
+	c.Assert(p.Parse(), IsNil)
+
+	obtained := p.Dump()
+	c.Check(obtained["timestamp"], Equals, time.Date(time.Now().Year(), time.October, 11, 22, 14, 15, 0, loc))
+	c.Check(obtained["timestamp_format"], Equals, time.Stamp)
+}
+
+func (s *Rfc3164TestSuite) TestParser_CiscoTimestamp(c *C) {
+	buff := []byte("<189>*Mar  1 18:46:11.123 UTC: %SYS-5-CONFIG_I: Configured from console")
+
+	p := NewParser(buff)
+	c.Assert(p.Parse(), IsNil)
+
+	obtained := p.Dump()
+	c.Check(obtained["timestamp"], Equals, time.Date(time.Now().Year(), time.March, 1, 18, 46, 11, 123000000, time.UTC))
+	c.Check(obtained["timestamp_format"], Equals, "*Jan _2 15:04:05.000 MST:")
+}
@@ -4,6 +4,7 @@
 package rfc5424
 
 import (
+	"bytes"
 	"fmt"
 	"math"
 	"strconv"
@@ -16,6 +17,9 @@ const (
 	NILVALUE = '-'
 )
 
+// BOM starts a MSG encoded in UTF-8, see RFC5424 section 6.4
+var BOM = []byte{0xEF, 0xBB, 0xBF}
+
 var (
 	ErrYearInvalid       = &syslogparser.ParserError{ErrorString: "Invalid year in timestamp"}
 	ErrMonthInvalid      = &syslogparser.ParserError{ErrorString: "Invalid month in timestamp"}
@@ -30,6 +34,7 @@ var (
 	ErrInvalidProcId     = &syslogparser.ParserError{ErrorString: "Invalid proc ID"}
 	ErrInvalidMsgId      = &syslogparser.ParserError{ErrorString: "Invalid msg ID"}
 	ErrNoStructuredData  = &syslogparser.ParserError{ErrorString: "No structured data"}
+	ErrInvalidSDElement  = &syslogparser.ParserError{ErrorString: "Invalid structured data element"}
 )
 
 type Parser struct {
@@ -39,6 +44,7 @@ type Parser struct {
 	header         header
 	structuredData string
 	message        string
+	messageUTF8    bool
 }
 
 type header struct {
@@ -98,14 +104,21 @@ func (p *Parser) Parse() error {
 	p.cursor++
 
 	if p.cursor < p.l {
-		p.message = string(p.buff[p.cursor:])
+		msg := p.buff[p.cursor:]
+		if bytes.HasPrefix(msg, BOM) {
+			msg = msg[len(BOM):]
+			p.messageUTF8 = true
+		}
+		p.message = string(msg)
 	}
 
 	return nil
 }
 
+// Dump returns the parts of the message. The BOM of a MSG declared as UTF-8 is
+// stripped, and the "message_utf8" part is then added, set to true
 func (p *Parser) Dump() syslogparser.LogParts {
-	return syslogparser.LogParts{
+	logParts := syslogparser.LogParts{
 		"priority":        p.header.priority.P,
 		"facility":        p.header.priority.F.Value,
 		"severity":        p.header.priority.S.Value,
@@ -118,6 +131,10 @@ func (p *Parser) Dump() syslogparser.LogParts {
 		"structured_data": p.structuredData,
 		"message":         p.message,
 	}
+	if p.messageUTF8 {
+		logParts["message_utf8"] = true
+	}
+	return logParts
 }
 
 // HEADER = PRI VERSION SP TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID
@@ -575,6 +592,114 @@ func parseStructuredData(buff []byte, cursor *int, l int) (string, error) {
 	return sdData, ErrNoStructuredData
 }
 
+// ParseStructuredDataElements splits structured data, as found in the
+// "structured_data" field, into its elements keyed by SD-ID, each one holding
+// its unescaped parameters keyed by name. The NILVALUE gives an empty map
+func ParseStructuredDataElements(sd string) (map[string]map[string]string, error) {
+	elements := make(map[string]map[string]string)
+	if sd == "" || sd == "-" {
+		return elements, nil
+	}
+
+	cursor := 0
+	l := len(sd)
+	for cursor < l {
+		if sd[cursor] == ' ' {
+			cursor++
+			continue
+		}
+		if sd[cursor] != '[' {
+			return nil, ErrInvalidSDElement
+		}
+		cursor++
+
+		id, err := parseSDName(sd, &cursor, l)
+		if err != nil {
+			return nil, err
+		}
+		params := elements[id]
+		if params == nil {
+			params = make(map[string]string)
+			elements[id] = params
+		}
+
+		for {
+			if cursor >= l {
+				return nil, ErrInvalidSDElement
+			}
+			if sd[cursor] == ']' {
+				cursor++
+				break
+			}
+			if sd[cursor] != ' ' {
+				return nil, ErrInvalidSDElement
+			}
+			cursor++
+
+			name, err := parseSDName(sd, &cursor, l)
+			if err != nil {
+				return nil, err
+			}
+			if cursor+1 >= l || sd[cursor] != '=' || sd[cursor+1] != '"' {
+				return nil, ErrInvalidSDElement
+			}
+			cursor += 2
+
+			value, err := parseSDParamValue(sd, &cursor, l)
+			if err != nil {
+				return nil, err
+			}
+			params[name] = value
+		}
+	}
+
+	return elements, nil
+}
+
+// SD-NAME: up to 32 printable characters except '=', ' ', ']' and '"'
+func parseSDName(sd string, cursor *int, l int) (string, error) {
+	from := *cursor
+	for ; *cursor < l; *cursor++ {
+		b := sd[*cursor]
+		if b == '=' || b == ' ' || b == ']' || b == '"' {
+			break
+		}
+		if b < 33 || b > 126 {
+			return "", ErrInvalidSDElement
+		}
+	}
+
+	if n := *cursor - from; n == 0 || n > 32 {
+		return "", ErrInvalidSDElement
+	}
+
+	return sd[from:*cursor], nil
+}
+
+// PARAM-VALUE: up to the closing '"', with '"', '\\' and ']' escaped by a
+// backslash. Any other backslash is kept as is
+func parseSDParamValue(sd string, cursor *int, l int) (string, error) {
+	value := make([]byte, 0, 16)
+	for ; *cursor < l; *cursor++ {
+		b := sd[*cursor]
+		switch {
+		case b == '"':
+			*cursor++
+			return string(value), nil
+		case b == '\\' && *cursor+1 < l:
+			switch next := sd[*cursor+1]; next {
+			case '"', '\\', ']':
+				value = append(value, next)
+				*cursor++
+				continue
+			}
+		}
+		value = append(value, b)
+	}
+
+	return "", ErrInvalidSDElement
+}
+
 func parseUpToLen(buff []byte, cursor *int, l int, maxLen int, e error) (string, error) {
 	var to int
 	var found bool
@@ -137,6 +137,24 @@ func (s *Rfc5424TestSuite) TestParser_Valid(c *C) {
 	}
 }
 
+func (s *Rfc5424TestSuite) TestParser_BOM(c *C) {
+	buff := "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - \xef\xbb\xbfAn application event log entry"
+	p := NewParser([]byte(buff))
+	c.Assert(p.Parse(), IsNil)
+	obtained := p.Dump()
+	c.Assert(obtained["message"], Equals, "An application event log entry")
+	c.Assert(obtained["message_utf8"], Equals, true)
+
+	// A BOM only counts at the start of MSG
+	buff = "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - An entry \xef\xbb\xbf"
+	p = NewParser([]byte(buff))
+	c.Assert(p.Parse(), IsNil)
+	obtained = p.Dump()
+	c.Assert(obtained["message"], Equals, "An entry \xef\xbb\xbf")
+	_, ok := obtained["message_utf8"]
+	c.Assert(ok, Equals, false)
+}
+
 func (s *Rfc5424TestSuite) TestParser_Truncated(c *C) {
 	msg := "<165>1 2003-08-24T05:14:15.000003-07:00 192.0.2.1 myproc 8710 - - %% It's time to make the do-nuts."
 	for i := range msg {
@@ -751,6 +769,53 @@ func (s *Rfc5424TestSuite) TestParseStructuredData_MultipleStructuredDataInvalid
 
 // -------------
 
+func (s *Rfc5424TestSuite) TestParseStructuredDataElements(c *C) {
+	elements, err := ParseStructuredDataElements(`[exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"]`)
+	c.Assert(err, IsNil)
+	c.Assert(elements, DeepEquals, map[string]map[string]string{
+		"exampleSDID@32473": {
+			"iut":         "3",
+			"eventSource": "Application",
+			"eventID":     "1011",
+		},
+		"examplePriority@32473": {
+			"class": "high",
+		},
+	})
+
+	elements, err = ParseStructuredDataElements(`[origin ip="10.0.0.1" software="a \"quoted\" \] \\ \n"][timeQuality]`)
+	c.Assert(err, IsNil)
+	c.Assert(elements, DeepEquals, map[string]map[string]string{
+		"origin": {
+			"ip":       "10.0.0.1",
+			"software": `a "quoted" ] \ \n`,
+		},
+		"timeQuality": {},
+	})
+
+	for _, sd := range []string{"", "-"} {
+		elements, err = ParseStructuredDataElements(sd)
+		c.Assert(err, IsNil)
+		c.Assert(elements, HasLen, 0)
+	}
+}
+
+func (s *Rfc5424TestSuite) TestParseStructuredDataElements_Invalid(c *C) {
+	for _, sd := range []string{
+		`origin`,
+		`[origin`,
+		`[]`,
+		`[origin ip]`,
+		`[origin ip=10.0.0.1]`,
+		`[origin ip="10.0.0.1]`,
+		`[origin ip="10.0.0.1"x]`,
+		`[origin ="10.0.0.1"]`,
+	} {
+		_, err := ParseStructuredDataElements(sd)
+		c.Check(err, Equals, ErrInvalidSDElement, Commentf("%s", sd))
+	}
+}
+
 func (s *Rfc5424TestSuite) BenchmarkParseTimestamp(c *C) {
 	buff := []byte("2003-08-24T05:14:15.000003-07:00")
 
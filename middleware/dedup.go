@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+const dedupWindowDefault = 30 * time.Second
+
+// The fields identifying a message by default: its source and its text
+var dedupFieldsDefault = []string{
+	"client", "hostname", "tag", "app_name", "proc_id", "facility", "severity", "message", "content",
+}
+
+// The duplicates of a message received within a window
+type dedupEntry struct {
+	last          format.LogParts // the latest duplicate
+	count         int
+	messageLength int64
+	err           error
+	timer         *time.Timer
+}
+
+// Dedup collapses the identical messages of a source, like classic syslogd
+// does. The first one is passed on, and its duplicates received within the
+// window are counted instead. At the end of the window, if any, a summary
+// made of the latest duplicate is passed on, its text becoming "message
+// repeated N times: [text]" and the "repeated" field added, holding N. The
+// duplicates keep being counted in a new window until one ends without any.
+// Close passes on the pending summaries
+type Dedup struct {
+	next   syslog.Handler
+	window time.Duration
+	fields []string
+
+	mu      sync.Mutex
+	entries map[string]*dedupEntry // by identity
+	closed  bool
+}
+
+// NewDedup returns a new Dedup, passing the messages and the summaries on to
+// next, with a window of 30 seconds
+func NewDedup(next syslog.Handler) *Dedup {
+	return &Dedup{
+		next:    next,
+		window:  dedupWindowDefault,
+		fields:  dedupFieldsDefault,
+		entries: make(map[string]*dedupEntry),
+	}
+}
+
+// Sets the length of the windows, defaults to 30 seconds
+func (d *Dedup) SetWindow(window time.Duration) {
+	d.window = window
+}
+
+// Sets the fields of the identical messages, defaults to the client,
+// hostname, tag, app name, proc ID, facility, severity and text
+func (d *Dedup) SetFields(fields ...string) {
+	d.fields = fields
+}
+
+// Returns the identity of a message
+func (d *Dedup) identity(logParts format.LogParts) string {
+	var b strings.Builder
+	for _, field := range d.fields {
+		if value, ok := logParts[field]; ok {
+			fmt.Fprintf(&b, "%v", value)
+		}
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// Handle passes on the messages and the summaries while holding the lock of d,
+// so that a summary always follows its message
+func (d *Dedup) Handle(logParts format.LogParts, messageLength int64, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		d.next.Handle(logParts, messageLength, err)
+		return
+	}
+
+	id := d.identity(logParts)
+	if e := d.entries[id]; e != nil {
+		e.last = logParts
+		e.count++
+		e.messageLength += messageLength
+		e.err = err
+		return
+	}
+
+	e := &dedupEntry{}
+	d.entries[id] = e
+	e.timer = time.AfterFunc(d.window, func() { d.endWindow(id, e) })
+	d.next.Handle(logParts, messageLength, err)
+}
+
+func (d *Dedup) endWindow(id string, e *dedupEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.entries[id] != e {
+		// Flushed on close
+		return
+	}
+	if e.count == 0 {
+		delete(d.entries, id)
+		return
+	}
+	d.summarize(e)
+	e.timer = time.AfterFunc(d.window, func() { d.endWindow(id, e) })
+}
+
+// Passes on the summary of the duplicates of an entry, and resets it. Must be
+// called with mu held
+func (d *Dedup) summarize(e *dedupEntry) {
+	logParts := e.last
+	key := textKey(logParts)
+	logParts[key] = fmt.Sprintf("message repeated %d times: [%s]", e.count, stringField(logParts, key))
+	logParts["repeated"] = e.count
+	d.next.Handle(logParts, e.messageLength, e.err)
+
+	e.last = nil
+	e.count = 0
+	e.messageLength = 0
+	e.err = nil
+}
+
+// Close passes on the pending summaries, the next messages being passed on as
+// is
+func (d *Dedup) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	for id, e := range d.entries {
+		e.timer.Stop()
+		if e.count > 0 {
+			d.summarize(e)
+		}
+		delete(d.entries, id)
+	}
+	return nil
+}
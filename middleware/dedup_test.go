@@ -0,0 +1,86 @@
+package middleware_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/middleware"
+)
+
+func flap(client string, port string) format.LogParts {
+	return format.LogParts{"client": client, "hostname": "sw-1", "tag": "LINK", "severity": 3, "content": "Interface " + port + " changed state to down"}
+}
+
+func (s *MiddlewareSuite) TestDedup(c *C) {
+	h := new(handlerRecorder)
+	d := middleware.NewDedup(h)
+	d.SetWindow(time.Hour)
+
+	for i := 0; i < 5; i++ {
+		d.Handle(flap("10.0.0.1:514", "Gi0/1"), 40, nil)
+	}
+	d.Handle(flap("10.0.0.1:514", "Gi0/2"), 40, nil)
+	d.Handle(flap("10.0.0.2:514", "Gi0/1"), 40, nil)
+	c.Assert(h.logParts, HasLen, 3)
+
+	c.Assert(d.Close(), IsNil)
+	c.Assert(h.logParts, HasLen, 4)
+	c.Check(h.logParts[3]["content"], Equals, "message repeated 4 times: [Interface Gi0/1 changed state to down]")
+	c.Check(h.logParts[3]["repeated"], Equals, 4)
+	c.Check(h.logParts[3]["client"], Equals, "10.0.0.1:514")
+}
+
+func (s *MiddlewareSuite) TestDedupFields(c *C) {
+	h := new(handlerRecorder)
+	d := middleware.NewDedup(h)
+	d.SetWindow(time.Hour)
+	d.SetFields("hostname", "content")
+
+	d.Handle(flap("10.0.0.1:514", "Gi0/1"), 40, nil)
+	d.Handle(flap("10.0.0.2:514", "Gi0/1"), 40, nil)
+	d.Close()
+	c.Assert(h.logParts, HasLen, 2)
+	c.Check(h.logParts[1]["repeated"], Equals, 1)
+}
+
+func (s *MiddlewareSuite) TestDedupWindows(c *C) {
+	type message struct {
+		logParts      format.LogParts
+		messageLength int64
+	}
+	messages := make(chan message, 10)
+	d := middleware.NewDedup(syslog.HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+		messages <- message{logParts, messageLength}
+	}))
+	d.SetWindow(30 * time.Millisecond)
+
+	receive := func() message {
+		select {
+		case m := <-messages:
+			return m
+		case <-time.After(5 * time.Second):
+			c.Fatal("no message")
+		}
+		return message{}
+	}
+
+	d.Handle(flap("10.0.0.1:514", "Gi0/1"), 40, nil)
+	c.Check(receive().logParts["repeated"], IsNil)
+	d.Handle(flap("10.0.0.1:514", "Gi0/1"), 40, nil)
+	d.Handle(flap("10.0.0.1:514", "Gi0/1"), 40, nil)
+	m := receive()
+	c.Check(m.logParts["repeated"], Equals, 2)
+	c.Check(m.messageLength, Equals, int64(80))
+
+	// Still counted in the next window
+	d.Handle(flap("10.0.0.1:514", "Gi0/1"), 40, nil)
+	c.Check(receive().logParts["repeated"], Equals, 1)
+
+	// A window without duplicates ends the collapsing
+	time.Sleep(100 * time.Millisecond)
+	d.Handle(flap("10.0.0.1:514", "Gi0/1"), 40, nil)
+	c.Check(receive().logParts["repeated"], IsNil)
+	c.Assert(d.Close(), IsNil)
+}
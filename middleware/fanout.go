@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// Tee delivers every message to all the handlers in turn, each one getting its
+// own copy of the fields. A handler panicking does not prevent the next ones
+// from getting the message, the first panic being raised again once they all
+// got it, wrap the handlers with Recover to handle them
+func Tee(handlers ...syslog.Handler) syslog.Handler {
+	return syslog.HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+		var recovered interface{}
+		onPanic := func(r interface{}, _ format.LogParts) {
+			if recovered == nil {
+				recovered = r
+			}
+		}
+		for i, h := range handlers {
+			lp := logParts
+			if i < len(handlers)-1 {
+				lp = CopyLogParts(logParts)
+			}
+			handleSafely(h, onPanic, lp, messageLength, err)
+		}
+		if recovered != nil {
+			panic(recovered)
+		}
+	})
+}
+
+// Case associates a handler with the messages matching a filter
+type Case struct {
+	Filter  FilterFunc
+	Handler syslog.Handler
+}
+
+// When returns a new Case
+func When(f FilterFunc, h syslog.Handler) Case {
+	return Case{Filter: f, Handler: h}
+}
+
+// Route delivers every message to the handler of the first matching case, or
+// to fallback when none matches. A nil fallback drops those messages
+func Route(fallback syslog.Handler, cases ...Case) syslog.Handler {
+	return syslog.HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+		for _, c := range cases {
+			if c.Filter(logParts) {
+				c.Handler.Handle(logParts, messageLength, err)
+				return
+			}
+		}
+		if fallback != nil {
+			fallback.Handle(logParts, messageLength, err)
+		}
+	})
+}
+
+type fanOutMessage struct {
+	logParts      format.LogParts
+	messageLength int64
+	err           error
+}
+
+type fanOutBranch struct {
+	dropped uint64 // first for 64-bit alignment of atomic operations
+	handler syslog.Handler
+	queue   chan fanOutMessage
+}
+
+// FanOut delivers every message to several handlers asynchronously, each one
+// from its own goroutine and queue, so that a slow or panicking handler does
+// not hold up the others. When the queue of a handler is full, the message is
+// dropped for that handler only
+type FanOut struct {
+	branches []*fanOutBranch
+	onPanic  func(interface{}, format.LogParts)
+	wait     sync.WaitGroup
+	mu       sync.RWMutex
+	closed   bool
+}
+
+// NewFanOut returns a new FanOut, queueing up to queueSize messages per handler
+func NewFanOut(queueSize int, handlers ...syslog.Handler) *FanOut {
+	f := &FanOut{}
+	for _, h := range handlers {
+		b := &fanOutBranch{
+			handler: h,
+			queue:   make(chan fanOutMessage, queueSize),
+		}
+		f.branches = append(f.branches, b)
+
+		f.wait.Add(1)
+		go f.run(b)
+	}
+	return f
+}
+
+// Sets the function notified of the panics of the handlers
+func (f *FanOut) SetPanicHandler(onPanic func(recovered interface{}, logParts format.LogParts)) {
+	f.onPanic = onPanic
+}
+
+func (f *FanOut) run(b *fanOutBranch) {
+	defer f.wait.Done()
+	for msg := range b.queue {
+		handleSafely(b.handler, f.onPanic, msg.logParts, msg.messageLength, msg.err)
+	}
+}
+
+func (f *FanOut) Handle(logParts format.LogParts, messageLength int64, err error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		return
+	}
+
+	for i, b := range f.branches {
+		lp := logParts
+		if i < len(f.branches)-1 {
+			lp = CopyLogParts(logParts)
+		}
+		select {
+		case b.queue <- fanOutMessage{lp, messageLength, err}:
+		default:
+			atomic.AddUint64(&b.dropped, 1)
+		}
+	}
+}
+
+// Dropped returns the number of messages dropped for each handler, in the
+// order they were given
+func (f *FanOut) Dropped() []uint64 {
+	dropped := make([]uint64, len(f.branches))
+	for i, b := range f.branches {
+		dropped[i] = atomic.LoadUint64(&b.dropped)
+	}
+	return dropped
+}
+
+// Close stops accepting messages, and waits for the queued ones to be handled
+func (f *FanOut) Close() error {
+	f.mu.Lock()
+	if !f.closed {
+		f.closed = true
+		for _, b := range f.branches {
+			close(b.queue)
+		}
+	}
+	f.mu.Unlock()
+
+	f.wait.Wait()
+	return nil
+}
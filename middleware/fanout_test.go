@@ -0,0 +1,109 @@
+package middleware_test
+
+import (
+	"sync"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/middleware"
+)
+
+func (s *MiddlewareSuite) TestTee(c *C) {
+	a := new(handlerRecorder)
+	b := new(handlerRecorder)
+	panicking := syslog.HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+		logParts["tag"] = "modified"
+		panic("boom")
+	})
+
+	tee := middleware.Tee(a, panicking, b)
+	c.Check(func() { tee.Handle(format.LogParts{"tag": "foo"}, 0, nil) }, PanicMatches, "boom")
+
+	c.Assert(a.logParts, HasLen, 1)
+	c.Assert(b.logParts, HasLen, 1)
+	c.Check(a.logParts[0]["tag"], Equals, "foo")
+	c.Check(b.logParts[0]["tag"], Equals, "foo")
+}
+
+func (s *MiddlewareSuite) TestRoute(c *C) {
+	security := new(handlerRecorder)
+	pager := new(handlerRecorder)
+	archive := new(handlerRecorder)
+
+	handler := middleware.Route(archive,
+		middleware.When(middleware.Facility(syslog.Auth, syslog.AuthPriv), security),
+		middleware.When(middleware.And(middleware.SeverityAtLeast(syslog.Error), middleware.Hostname("core-*")), pager),
+	)
+
+	handler.Handle(format.LogParts{"facility": 10, "severity": 2, "hostname": "core-1"}, 0, nil)
+	handler.Handle(format.LogParts{"facility": 1, "severity": 2, "hostname": "core-1"}, 0, nil)
+	handler.Handle(format.LogParts{"facility": 1, "severity": 2, "hostname": "edge-1"}, 0, nil)
+
+	c.Check(security.logParts, HasLen, 1)
+	c.Check(pager.logParts, HasLen, 1)
+	c.Check(archive.logParts, HasLen, 1)
+	c.Check(archive.logParts[0]["hostname"], Equals, "edge-1")
+
+	// No fallback
+	middleware.Route(nil, middleware.When(middleware.Facility(4), security)).Handle(format.LogParts{"facility": 1}, 0, nil)
+	c.Check(security.logParts, HasLen, 1)
+}
+
+func (s *MiddlewareSuite) TestFanOut(c *C) {
+	a := new(handlerRecorder)
+	b := new(handlerRecorder)
+	var recovered []interface{}
+	var mu sync.Mutex
+	panicking := syslog.HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+		panic("boom")
+	})
+
+	fanOut := middleware.NewFanOut(10, a, panicking, b)
+	fanOut.SetPanicHandler(func(r interface{}, logParts format.LogParts) {
+		mu.Lock()
+		recovered = append(recovered, r)
+		mu.Unlock()
+	})
+	for i := 0; i < 3; i++ {
+		fanOut.Handle(format.LogParts{"i": i}, 0, nil)
+	}
+	c.Assert(fanOut.Close(), IsNil)
+
+	c.Check(a.logParts, HasLen, 3)
+	c.Check(b.logParts, HasLen, 3)
+	c.Check(b.logParts[2]["i"], Equals, 2)
+	c.Check(recovered, HasLen, 3)
+	c.Check(fanOut.Dropped(), DeepEquals, []uint64{0, 0, 0})
+
+	// Closed
+	fanOut.Handle(format.LogParts{}, 0, nil)
+	c.Check(a.logParts, HasLen, 3)
+}
+
+func (s *MiddlewareSuite) TestFanOutSlowHandler(c *C) {
+	fast := new(handlerRecorder)
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	slow := syslog.HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+	})
+
+	fanOut := middleware.NewFanOut(1, slow, fast)
+	fanOut.Handle(format.LogParts{}, 0, nil)
+	<-started
+	// The slow handler is busy with the first message and has one queued
+	for i := 0; i < 3; i++ {
+		fanOut.Handle(format.LogParts{}, 0, nil)
+	}
+	close(release)
+	c.Assert(fanOut.Close(), IsNil)
+
+	c.Check(fanOut.Dropped()[0], Equals, uint64(2))
+	// The fast handler is never held up by the slow one
+	c.Check(uint64(len(fast.logParts))+fanOut.Dropped()[1], Equals, uint64(4))
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"path"
+	"regexp"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// FilterFunc is a predicate over a parsed message
+type FilterFunc func(logParts format.LogParts) bool
+
+// All matches every message
+func All(logParts format.LogParts) bool {
+	return true
+}
+
+// And matches the messages matching all the filters
+func And(filters ...FilterFunc) FilterFunc {
+	return func(logParts format.LogParts) bool {
+		for _, f := range filters {
+			if !f(logParts) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches the messages matching any of the filters
+func Or(filters ...FilterFunc) FilterFunc {
+	return func(logParts format.LogParts) bool {
+		for _, f := range filters {
+			if f(logParts) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches the messages not matching f
+func Not(f FilterFunc) FilterFunc {
+	return func(logParts format.LogParts) bool {
+		return !f(logParts)
+	}
+}
+
+// Facility matches the messages of any of the given facilities
+func Facility(facilities ...syslog.Facility) FilterFunc {
+	return func(logParts format.LogParts) bool {
+		facility, ok := logParts["facility"].(int)
+		if !ok {
+			return false
+		}
+		for _, f := range facilities {
+			if syslog.Facility(facility) == f {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// SeverityAtLeast matches the messages of the given severity or more severe,
+// e.g. SeverityAtLeast(syslog.Error) matches emerg to err
+func SeverityAtLeast(severity syslog.Severity) FilterFunc {
+	return func(logParts format.LogParts) bool {
+		s, ok := logParts["severity"].(int)
+		return ok && syslog.Severity(s).AtLeast(severity)
+	}
+}
+
+// Hostname matches the messages whose hostname matches any of the glob
+// patterns, as understood by path.Match
+func Hostname(patterns ...string) FilterFunc {
+	return func(logParts format.LogParts) bool {
+		return matchGlob(stringField(logParts, "hostname"), patterns)
+	}
+}
+
+// Tag matches the messages whose RFC3164 tag or RFC5424 app name matches any
+// of the glob patterns, as understood by path.Match
+func Tag(patterns ...string) FilterFunc {
+	return func(logParts format.LogParts) bool {
+		if tag, ok := logParts["tag"].(string); ok && matchGlob(tag, patterns) {
+			return true
+		}
+		return matchGlob(stringField(logParts, "app_name"), patterns)
+	}
+}
+
+// Message matches the messages whose RFC5424 message or RFC3164 content
+// matches re
+func Message(re *regexp.Regexp) FilterFunc {
+	return func(logParts format.LogParts) bool {
+		if msg, ok := logParts["message"].(string); ok {
+			return re.MatchString(msg)
+		}
+		return re.MatchString(stringField(logParts, "content"))
+	}
+}
+
+// Field matches the messages whose given field is a string matching re
+func Field(key string, re *regexp.Regexp) FilterFunc {
+	return func(logParts format.LogParts) bool {
+		value, ok := logParts[key].(string)
+		return ok && re.MatchString(value)
+	}
+}
+
+func matchGlob(s string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func stringField(logParts format.LogParts, key string) string {
+	s, _ := logParts[key].(string)
+	return s
+}
@@ -0,0 +1,66 @@
+package middleware_test
+
+import (
+	"regexp"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/middleware"
+)
+
+var (
+	filterRFC3164 = format.LogParts{
+		"hostname": "core-router1",
+		"tag":      "sshd",
+		"content":  "Failed password for root from 10.0.0.1",
+		"facility": 10,
+		"severity": 5,
+	}
+	filterRFC5424 = format.LogParts{
+		"hostname": "web1.example.com",
+		"app_name": "nginx",
+		"message":  "GET /health 200",
+		"facility": 16,
+		"severity": 6,
+	}
+)
+
+func (s *MiddlewareSuite) TestFilters(c *C) {
+	fixtures := []struct {
+		filter   middleware.FilterFunc
+		rfc3164  bool
+		rfc5424  bool
+		fixtures string
+	}{
+		{middleware.All, true, true, "All"},
+		{middleware.Facility(syslog.Auth, syslog.AuthPriv), true, false, "Facility"},
+		{middleware.SeverityAtLeast(syslog.Notice), true, false, "SeverityAtLeast"},
+		{middleware.SeverityAtLeast(syslog.Debug), true, true, "SeverityAtLeast all"},
+		{middleware.Hostname("core-*"), true, false, "Hostname glob"},
+		{middleware.Hostname("other", "web?.example.com"), false, true, "Hostname globs"},
+		{middleware.Tag("sshd"), true, false, "Tag"},
+		{middleware.Tag("ngin*"), false, true, "Tag app name"},
+		{middleware.Message(regexp.MustCompile(`^GET /health`)), false, true, "Message"},
+		{middleware.Message(regexp.MustCompile(`Failed password`)), true, false, "Message content"},
+		{middleware.Field("hostname", regexp.MustCompile(`example\.com$`)), false, true, "Field"},
+		{middleware.And(middleware.Facility(10), middleware.Tag("sshd")), true, false, "And"},
+		{middleware.And(middleware.Facility(10), middleware.Tag("nginx")), false, false, "And none"},
+		{middleware.Or(middleware.Facility(10), middleware.Tag("nginx")), true, true, "Or"},
+		{middleware.Not(middleware.Facility(10)), false, true, "Not"},
+	}
+
+	for _, f := range fixtures {
+		c.Check(f.filter(filterRFC3164), Equals, f.rfc3164, Commentf("%s", f.fixtures))
+		c.Check(f.filter(filterRFC5424), Equals, f.rfc5424, Commentf("%s", f.fixtures))
+	}
+}
+
+func (s *MiddlewareSuite) TestFiltersMissingFields(c *C) {
+	logParts := format.LogParts{}
+	c.Check(middleware.Facility(0)(logParts), Equals, false)
+	c.Check(middleware.SeverityAtLeast(syslog.Debug)(logParts), Equals, false)
+	c.Check(middleware.Hostname("*")(logParts), Equals, true)
+	c.Check(middleware.Tag("sshd")(logParts), Equals, false)
+	c.Check(middleware.Message(regexp.MustCompile("."))(logParts), Equals, false)
+}
@@ -0,0 +1,84 @@
+// Package middleware provides composable building blocks around syslog
+// handlers: filters, transforms, fan-out and routing
+package middleware
+
+import (
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// Middleware wraps a handler into another one
+type Middleware func(syslog.Handler) syslog.Handler
+
+// Chain wraps h in the middlewares, the first one being the outermost, i.e.
+// the first to see the messages
+func Chain(h syslog.Handler, middlewares ...Middleware) syslog.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// Filter only passes on the messages matching f
+func Filter(f FilterFunc) Middleware {
+	return func(next syslog.Handler) syslog.Handler {
+		return syslog.HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+			if f(logParts) {
+				next.Handle(logParts, messageLength, err)
+			}
+		})
+	}
+}
+
+// Drop discards the messages matching f
+func Drop(f FilterFunc) Middleware {
+	return Filter(Not(f))
+}
+
+// Map passes on the messages transformed by fn, a nil result drops the message
+func Map(fn func(format.LogParts) format.LogParts) Middleware {
+	return func(next syslog.Handler) syslog.Handler {
+		return syslog.HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+			if logParts = fn(logParts); logParts != nil {
+				next.Handle(logParts, messageLength, err)
+			}
+		})
+	}
+}
+
+// Set sets a field on every message
+func Set(key string, value interface{}) Middleware {
+	return Map(func(logParts format.LogParts) format.LogParts {
+		logParts[key] = value
+		return logParts
+	})
+}
+
+// Recover recovers the panics of the next handlers, and reports them to
+// onPanic if not nil
+func Recover(onPanic func(recovered interface{}, logParts format.LogParts)) Middleware {
+	return func(next syslog.Handler) syslog.Handler {
+		return syslog.HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+			handleSafely(next, onPanic, logParts, messageLength, err)
+		})
+	}
+}
+
+func handleSafely(h syslog.Handler, onPanic func(interface{}, format.LogParts), logParts format.LogParts, messageLength int64, err error) {
+	defer func() {
+		if r := recover(); r != nil && onPanic != nil {
+			onPanic(r, logParts)
+		}
+	}()
+	h.Handle(logParts, messageLength, err)
+}
+
+// CopyLogParts returns a shallow copy of logParts, so that a handler can modify
+// its fields without the others seeing it
+func CopyLogParts(logParts format.LogParts) format.LogParts {
+	c := make(format.LogParts, len(logParts))
+	for k, v := range logParts {
+		c[k] = v
+	}
+	return c
+}
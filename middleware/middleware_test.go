@@ -0,0 +1,108 @@
+package middleware_test
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/middleware"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MiddlewareSuite struct{}
+
+var _ = Suite(&MiddlewareSuite{})
+
+type handlerRecorder struct {
+	logParts []format.LogParts
+}
+
+func (h *handlerRecorder) Handle(logParts format.LogParts, messageLength int64, err error) {
+	h.logParts = append(h.logParts, logParts)
+}
+
+func (s *MiddlewareSuite) TestChainOrder(c *C) {
+	var order []string
+	mark := func(name string) middleware.Middleware {
+		return func(next syslog.Handler) syslog.Handler {
+			return syslog.HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+				order = append(order, name)
+				next.Handle(logParts, messageLength, err)
+			})
+		}
+	}
+
+	h := new(handlerRecorder)
+	middleware.Chain(h, mark("a"), mark("b"), mark("c")).Handle(format.LogParts{}, 0, nil)
+
+	c.Check(order, DeepEquals, []string{"a", "b", "c"})
+	c.Check(h.logParts, HasLen, 1)
+}
+
+func (s *MiddlewareSuite) TestChainNoMiddleware(c *C) {
+	h := new(handlerRecorder)
+	c.Check(middleware.Chain(h), Equals, syslog.Handler(h))
+}
+
+func (s *MiddlewareSuite) TestFilterDrop(c *C) {
+	h := new(handlerRecorder)
+	handler := middleware.Chain(h,
+		middleware.Filter(middleware.SeverityAtLeast(syslog.Warning)),
+		middleware.Drop(middleware.Tag("healthcheck")),
+	)
+
+	handler.Handle(format.LogParts{"severity": 3, "tag": "app"}, 0, nil)
+	handler.Handle(format.LogParts{"severity": 6, "tag": "app"}, 0, nil)
+	handler.Handle(format.LogParts{"severity": 3, "tag": "healthcheck"}, 0, nil)
+
+	c.Assert(h.logParts, HasLen, 1)
+	c.Check(h.logParts[0]["severity"], Equals, 3)
+	c.Check(h.logParts[0]["tag"], Equals, "app")
+}
+
+func (s *MiddlewareSuite) TestMap(c *C) {
+	h := new(handlerRecorder)
+	handler := middleware.Chain(h,
+		middleware.Map(func(logParts format.LogParts) format.LogParts {
+			if logParts["drop"] == true {
+				return nil
+			}
+			logParts["hostname"] = "rewritten"
+			return logParts
+		}),
+		middleware.Set("env", "prod"),
+	)
+
+	handler.Handle(format.LogParts{"hostname": "a"}, 0, nil)
+	handler.Handle(format.LogParts{"hostname": "b", "drop": true}, 0, nil)
+
+	c.Assert(h.logParts, HasLen, 1)
+	c.Check(h.logParts[0], DeepEquals, format.LogParts{"hostname": "rewritten", "env": "prod"})
+}
+
+func (s *MiddlewareSuite) TestRecover(c *C) {
+	var recovered interface{}
+	handler := middleware.Chain(syslog.HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+		panic("boom")
+	}), middleware.Recover(func(r interface{}, logParts format.LogParts) {
+		recovered = r
+	}))
+
+	handler.Handle(format.LogParts{}, 0, nil)
+	c.Check(recovered, Equals, "boom")
+}
+
+func (s *MiddlewareSuite) TestChannelHandler(c *C) {
+	channel := make(syslog.LogPartsChannel, 2)
+	handler := middleware.Chain(syslog.NewChannelHandler(channel), middleware.Filter(middleware.Facility(syslog.Auth, syslog.AuthPriv)))
+
+	handler.Handle(format.LogParts{"facility": 4}, 0, nil)
+	handler.Handle(format.LogParts{"facility": 1}, 0, nil)
+	handler.Handle(format.LogParts{"facility": 10}, 0, nil)
+
+	c.Check((<-channel)["facility"], Equals, 4)
+	c.Check((<-channel)["facility"], Equals, 10)
+	c.Check(channel, HasLen, 0)
+}
@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+const (
+	multilineTimeoutDefault  = time.Second
+	multilineMaxLinesDefault = 500
+)
+
+// ContinuationFunc tells whether the text of a message continues the previous
+// message of its stream, e.g. is a line of a stack trace
+type ContinuationFunc func(text string) bool
+
+// Indented matches the text starting with white space
+func Indented(text string) bool {
+	r, _ := utf8.DecodeRuneInString(text)
+	return unicode.IsSpace(r)
+}
+
+// StartsWith matches the text starting with any of the prefixes
+func StartsWith(prefixes ...string) ContinuationFunc {
+	return func(text string) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(text, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchesRegexp matches the text matching re
+func MatchesRegexp(re *regexp.Regexp) ContinuationFunc {
+	return re.MatchString
+}
+
+// AnyContinuation matches the text matched by any of the functions
+func AnyContinuation(continuations ...ContinuationFunc) ContinuationFunc {
+	return func(text string) bool {
+		for _, f := range continuations {
+			if f(text) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// StackTraceContinuation matches the lines of Java and Python stack traces.
+// RFC3164 parsing trims the leading spaces of the content, hence the prefixes
+var StackTraceContinuation = AnyContinuation(
+	Indented,
+	StartsWith("at ", "Caused by:", "Suppressed:", "... ", `File "`),
+)
+
+// A message of a stream, waiting for its continuations
+type multilinePending struct {
+	logParts      format.LogParts
+	key           string // of the text field, message or content
+	lines         []string
+	messageLength int64
+	err           error
+	generation    uint64 // not to flush a later message on timeout
+	timer         *time.Timer
+}
+
+// Multiline merges the consecutive messages of a source stream, i.e. with the
+// same client, hostname, tag or app name and proc ID, whose text continues the
+// first one into a single message, their lines being joined with new lines and
+// the "multiline" field added, set to true.
+//
+// A message is held until the next message of its stream does not continue
+// it, it reaches the maximum number of lines, or no message of the stream was
+// received for the timeout. Close flushes the pending messages
+type Multiline struct {
+	next         syslog.Handler
+	continuation ContinuationFunc
+	timeout      time.Duration
+	maxLines     int
+
+	mu         sync.Mutex
+	pending    map[string]*multilinePending // by stream
+	generation uint64
+	closed     bool
+}
+
+// NewMultiline returns a new Multiline, passing the merged messages on to next
+func NewMultiline(next syslog.Handler, continuation ContinuationFunc) *Multiline {
+	return &Multiline{
+		next:         next,
+		continuation: continuation,
+		timeout:      multilineTimeoutDefault,
+		maxLines:     multilineMaxLinesDefault,
+		pending:      make(map[string]*multilinePending),
+	}
+}
+
+// Sets the time after the last message of a stream flushing its pending
+// message, defaults to 1 second
+func (m *Multiline) SetTimeout(d time.Duration) {
+	m.timeout = d
+}
+
+// Sets the maximum number of lines of a merged message, no limit if 0,
+// defaults to 500
+func (m *Multiline) SetMaxLines(n int) {
+	m.maxLines = n
+}
+
+// Returns the stream of a message
+func streamKey(logParts format.LogParts) string {
+	return strings.Join([]string{
+		stringField(logParts, "client"),
+		stringField(logParts, "hostname"),
+		stringField(logParts, "tag"),
+		stringField(logParts, "app_name"),
+		stringField(logParts, "proc_id"),
+	}, "\x00")
+}
+
+// Returns the text field of a message, message or content
+func textKey(logParts format.LogParts) string {
+	if _, ok := logParts["message"].(string); ok {
+		return "message"
+	}
+	return "content"
+}
+
+// Handle passes on the merged messages while holding the lock of m, so that
+// the messages of a stream keep their order
+func (m *Multiline) Handle(logParts format.LogParts, messageLength int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		m.next.Handle(logParts, messageLength, err)
+		return
+	}
+
+	stream := streamKey(logParts)
+	key := textKey(logParts)
+	text := stringField(logParts, key)
+	if p := m.pending[stream]; p != nil {
+		if m.continuation(text) {
+			p.lines = append(p.lines, text)
+			p.messageLength += messageLength
+			if m.maxLines > 0 && len(p.lines) >= m.maxLines {
+				m.flush(stream)
+			} else {
+				m.startTimer(stream, p)
+			}
+			return
+		}
+		m.flush(stream)
+	}
+
+	p := &multilinePending{
+		logParts:      logParts,
+		key:           key,
+		lines:         []string{text},
+		messageLength: messageLength,
+		err:           err,
+	}
+	m.pending[stream] = p
+	if m.maxLines == 1 {
+		m.flush(stream)
+		return
+	}
+	m.startTimer(stream, p)
+}
+
+// (Re)starts the timeout of a pending message. Must be called with mu held
+func (m *Multiline) startTimer(stream string, p *multilinePending) {
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	m.generation++
+	p.generation = m.generation
+	generation := p.generation
+	p.timer = time.AfterFunc(m.timeout, func() { m.flushExpired(stream, generation) })
+}
+
+func (m *Multiline) flushExpired(stream string, generation uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p := m.pending[stream]; p != nil && p.generation == generation {
+		m.flush(stream)
+	}
+}
+
+// Passes on the pending message of a stream. Must be called with mu held
+func (m *Multiline) flush(stream string) {
+	p := m.pending[stream]
+	delete(m.pending, stream)
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+
+	logParts := p.logParts
+	if len(p.lines) > 1 {
+		logParts[p.key] = strings.Join(p.lines, "\n")
+		logParts["multiline"] = true
+	}
+	m.next.Handle(logParts, p.messageLength, p.err)
+}
+
+// Close flushes the pending messages, the next ones being passed on as is
+func (m *Multiline) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	for stream := range m.pending {
+		m.flush(stream)
+	}
+	return nil
+}
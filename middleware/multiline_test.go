@@ -0,0 +1,83 @@
+package middleware_test
+
+import (
+	"regexp"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/middleware"
+)
+
+func line(client string, tag string, content string) format.LogParts {
+	return format.LogParts{"client": client, "hostname": "app-1", "tag": tag, "content": content}
+}
+
+func (s *MiddlewareSuite) TestMultiline(c *C) {
+	h := new(handlerRecorder)
+	m := middleware.NewMultiline(h, middleware.StackTraceContinuation)
+	m.SetTimeout(time.Hour)
+
+	m.Handle(line("10.0.0.1:514", "java", "Exception in thread \"main\" java.lang.IllegalStateException: boom"), 70, nil)
+	m.Handle(line("10.0.0.1:514", "java", "at com.example.Main.run(Main.java:12)"), 40, nil)
+	// Another stream in between
+	m.Handle(line("10.0.0.2:514", "java", "started"), 10, nil)
+	m.Handle(line("10.0.0.1:514", "java", "Caused by: java.io.IOException: closed"), 45, nil)
+	m.Handle(line("10.0.0.1:514", "java", "... 3 more"), 15, nil)
+	m.Handle(line("10.0.0.1:514", "java", "next message"), 20, nil)
+
+	c.Assert(h.logParts, HasLen, 1)
+	c.Check(h.logParts[0]["content"], Equals, "Exception in thread \"main\" java.lang.IllegalStateException: boom\n"+
+		"at com.example.Main.run(Main.java:12)\n"+
+		"Caused by: java.io.IOException: closed\n"+
+		"... 3 more")
+	c.Check(h.logParts[0]["multiline"], Equals, true)
+
+	c.Assert(m.Close(), IsNil)
+	c.Assert(h.logParts, HasLen, 3)
+	for _, logParts := range h.logParts[1:] {
+		_, ok := logParts["multiline"]
+		c.Check(ok, Equals, false)
+	}
+
+	// Passed on as is once closed
+	m.Handle(line("10.0.0.1:514", "java", "late"), 4, nil)
+	c.Check(h.logParts, HasLen, 4)
+}
+
+func (s *MiddlewareSuite) TestMultilineMaxLines(c *C) {
+	var lengths []int64
+	h := syslog.HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+		lengths = append(lengths, messageLength)
+	})
+	m := middleware.NewMultiline(h, middleware.MatchesRegexp(regexp.MustCompile(`^\s*#\d+ `)))
+	m.SetTimeout(time.Hour)
+	m.SetMaxLines(3)
+
+	for _, text := range []string{"Traceback", "#1 a", "#2 b", "#3 c", "#4 d"} {
+		m.Handle(format.LogParts{"message": text}, 1, nil)
+	}
+	c.Check(lengths, DeepEquals, []int64{3})
+	m.Close()
+	c.Check(lengths, DeepEquals, []int64{3, 2})
+}
+
+func (s *MiddlewareSuite) TestMultilineTimeout(c *C) {
+	merged := make(chan format.LogParts, 1)
+	m := middleware.NewMultiline(syslog.HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+		merged <- logParts
+	}), middleware.AnyContinuation(middleware.Indented, middleware.StartsWith("at ")))
+	m.SetTimeout(20 * time.Millisecond)
+
+	m.Handle(format.LogParts{"app_name": "app", "message": "error"}, 5, nil)
+	m.Handle(format.LogParts{"app_name": "app", "message": "\tat main"}, 8, nil)
+
+	select {
+	case logParts := <-merged:
+		c.Check(logParts["message"], Equals, "error\n\tat main")
+	case <-time.After(5 * time.Second):
+		c.Fatal("pending message not flushed")
+	}
+	c.Assert(m.Close(), IsNil)
+}
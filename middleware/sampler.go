@@ -0,0 +1,205 @@
+package middleware
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+const samplerSummaryIntervalDefault = time.Minute
+
+// The messages of a source, i.e. of a hostname and an app name or tag
+type samplerSource struct {
+	hostname   string
+	app        string
+	tokens     float64
+	refilled   time.Time
+	sampledOut int
+	throttled  int
+}
+
+// Sampler reduces the floods of low severity messages: the messages of each
+// severity are sampled at its rate, and the messages of each source, i.e. of
+// a hostname and an app name or tag, are throttled above a rate. The messages
+// from emerg to err, and those without a severity, are always passed on.
+//
+// At the end of every summary interval, a summary is passed on for each
+// source whose messages were suppressed, with the syslog facility and the
+// notice severity, its message telling how many, and the "suppressed",
+// "sampled_out" and "throttled" fields holding the counts. Close passes on the
+// pending summaries
+type Sampler struct {
+	next            syslog.Handler
+	rates           [syslog.Debug + 1]float64 // by severity
+	limit           float64                   // per second and source, unlimited if 0
+	burst           int
+	summaryInterval time.Duration
+
+	mu      sync.Mutex
+	rng     *rand.Rand
+	sources map[string]*samplerSource
+	timer   *time.Timer // of the next summaries, nil if none pending
+	closed  bool
+}
+
+// NewSampler returns a new Sampler passing on all the messages to next until
+// sample rates or a rate limit are set
+func NewSampler(next syslog.Handler) *Sampler {
+	s := &Sampler{
+		next:            next,
+		summaryInterval: samplerSummaryIntervalDefault,
+		rng:             rand.New(rand.NewSource(time.Now().UnixNano())),
+		sources:         make(map[string]*samplerSource),
+	}
+	for i := range s.rates {
+		s.rates[i] = 1
+	}
+	return s
+}
+
+// Sets the fraction of the messages of severity passed on, from 0 to 1.
+// Ignored for the severities from emerg to err
+func (s *Sampler) SetSampleRate(severity syslog.Severity, rate float64) {
+	if severity >= 0 && severity <= syslog.Debug {
+		s.rates[severity] = rate
+	}
+}
+
+// Sets the messages per second passed on for each source, after sampling, in
+// bursts of up to burst messages. Unlimited if 0, the default
+func (s *Sampler) SetRateLimit(perSecond float64, burst int) {
+	s.limit = perSecond
+	s.burst = burst
+	if s.burst < 1 {
+		s.burst = 1
+	}
+}
+
+// Sets the interval of the summaries, defaults to 1 minute
+func (s *Sampler) SetSummaryInterval(d time.Duration) {
+	s.summaryInterval = d
+}
+
+// Returns the source of a message, its app name or else its tag
+func sourceOf(logParts format.LogParts) (hostname string, app string) {
+	app = stringField(logParts, "app_name")
+	if app == "" || app == "-" {
+		app = stringField(logParts, "tag")
+	}
+	return stringField(logParts, "hostname"), app
+}
+
+func (s *Sampler) Handle(logParts format.LogParts, messageLength int64, err error) {
+	severity, ok := logParts["severity"].(int)
+	if !ok || syslog.Severity(severity).AtLeast(syslog.Error) || severity > int(syslog.Debug) ||
+		(s.rates[severity] >= 1 && s.limit == 0) {
+		s.next.Handle(logParts, messageLength, err)
+		return
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		s.next.Handle(logParts, messageLength, err)
+		return
+	}
+	hostname, app := sourceOf(logParts)
+	key := hostname + "\x00" + app
+	src := s.sources[key]
+	if src == nil {
+		src = &samplerSource{hostname: hostname, app: app, tokens: float64(s.burst), refilled: time.Now()}
+		s.sources[key] = src
+		if s.timer == nil {
+			s.timer = time.AfterFunc(s.summaryInterval, s.summarize)
+		}
+	}
+
+	pass := true
+	if rate := s.rates[severity]; rate < 1 && s.rng.Float64() >= rate {
+		src.sampledOut++
+		pass = false
+	} else if s.limit > 0 {
+		now := time.Now()
+		src.tokens += now.Sub(src.refilled).Seconds() * s.limit
+		if src.tokens > float64(s.burst) {
+			src.tokens = float64(s.burst)
+		}
+		src.refilled = now
+		if src.tokens >= 1 {
+			src.tokens--
+		} else {
+			src.throttled++
+			pass = false
+		}
+	}
+	s.mu.Unlock()
+
+	if pass {
+		s.next.Handle(logParts, messageLength, err)
+	}
+}
+
+// Takes the summaries of the sources with suppressed messages, and forgets the
+// sources, their rate limits starting over. Must be called with mu held
+func (s *Sampler) takeSummaries() []format.LogParts {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	var summaries []format.LogParts
+	now := time.Now()
+	for key, src := range s.sources {
+		delete(s.sources, key)
+		suppressed := src.sampledOut + src.throttled
+		if suppressed == 0 {
+			continue
+		}
+		summaries = append(summaries, format.LogParts{
+			"timestamp":   now,
+			"priority":    int(syslog.Syslog)*8 + int(syslog.Notice),
+			"facility":    int(syslog.Syslog),
+			"severity":    int(syslog.Notice),
+			"hostname":    src.hostname,
+			"app_name":    src.app,
+			"message":     fmt.Sprintf("suppressed %d messages: %d sampled out, %d throttled", suppressed, src.sampledOut, src.throttled),
+			"suppressed":  suppressed,
+			"sampled_out": src.sampledOut,
+			"throttled":   src.throttled,
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i]["hostname"].(string)+"\x00"+summaries[i]["app_name"].(string) <
+			summaries[j]["hostname"].(string)+"\x00"+summaries[j]["app_name"].(string)
+	})
+	return summaries
+}
+
+func (s *Sampler) summarize() {
+	s.mu.Lock()
+	summaries := s.takeSummaries()
+	s.mu.Unlock()
+
+	for _, summary := range summaries {
+		s.next.Handle(summary, 0, nil)
+	}
+}
+
+// Close passes on the pending summaries, the next messages being passed on as
+// is
+func (s *Sampler) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	summaries := s.takeSummaries()
+	s.mu.Unlock()
+
+	for _, summary := range summaries {
+		s.next.Handle(summary, 0, nil)
+	}
+	return nil
+}
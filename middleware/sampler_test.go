@@ -0,0 +1,80 @@
+package middleware_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/middleware"
+)
+
+func (s *MiddlewareSuite) TestSamplerRates(c *C) {
+	h := new(handlerRecorder)
+	sampler := middleware.NewSampler(h)
+	sampler.SetSummaryInterval(time.Hour)
+	sampler.SetSampleRate(syslog.Debug, 0.1)
+	sampler.SetSampleRate(syslog.Informational, 0)
+	sampler.SetSampleRate(syslog.Error, 0)
+
+	for i := 0; i < 1000; i++ {
+		sampler.Handle(format.LogParts{"severity": int(syslog.Debug), "hostname": "app-1", "app_name": "api"}, 0, nil)
+	}
+	sampler.Handle(format.LogParts{"severity": int(syslog.Informational), "hostname": "app-1", "tag": "cron"}, 0, nil)
+	sampler.Handle(format.LogParts{"severity": int(syslog.Error), "hostname": "app-1", "tag": "cron"}, 0, nil)
+	sampler.Handle(format.LogParts{"hostname": "app-1", "tag": "cron"}, 0, nil)
+
+	passed := len(h.logParts)
+	c.Check(passed > 2+50 && passed < 2+150, Equals, true, Commentf("%d passed", passed))
+
+	c.Assert(sampler.Close(), IsNil)
+	summaries := h.logParts[passed:]
+	c.Assert(summaries, HasLen, 2)
+	c.Check(summaries[0]["app_name"], Equals, "api")
+	c.Check(summaries[0]["suppressed"], Equals, 1000-(passed-2))
+	c.Check(summaries[1]["app_name"], Equals, "cron")
+	c.Check(summaries[1]["message"], Equals, "suppressed 1 messages: 1 sampled out, 0 throttled")
+	c.Check(summaries[1]["severity"], Equals, int(syslog.Notice))
+	c.Check(summaries[1]["facility"], Equals, int(syslog.Syslog))
+}
+
+func (s *MiddlewareSuite) TestSamplerRateLimit(c *C) {
+	h := new(handlerRecorder)
+	sampler := middleware.NewSampler(h)
+	sampler.SetSummaryInterval(time.Hour)
+	sampler.SetRateLimit(1, 3)
+
+	for i := 0; i < 10; i++ {
+		sampler.Handle(format.LogParts{"severity": int(syslog.Informational), "hostname": "app-1", "app_name": "api"}, 0, nil)
+		sampler.Handle(format.LogParts{"severity": int(syslog.Informational), "hostname": "app-2", "app_name": "api"}, 0, nil)
+		sampler.Handle(format.LogParts{"severity": int(syslog.Critical), "hostname": "app-1", "app_name": "api"}, 0, nil)
+	}
+	c.Check(h.logParts, HasLen, 3+3+10)
+
+	sampler.Close()
+	c.Assert(h.logParts, HasLen, 3+3+10+2)
+	c.Check(h.logParts[16]["hostname"], Equals, "app-1")
+	c.Check(h.logParts[16]["throttled"], Equals, 7)
+	c.Check(h.logParts[17]["hostname"], Equals, "app-2")
+}
+
+func (s *MiddlewareSuite) TestSamplerSummaryInterval(c *C) {
+	summaries := make(chan format.LogParts, 10)
+	sampler := middleware.NewSampler(syslog.HandlerFunc(func(logParts format.LogParts, messageLength int64, err error) {
+		if _, ok := logParts["suppressed"]; ok {
+			summaries <- logParts
+		}
+	}))
+	sampler.SetSummaryInterval(20 * time.Millisecond)
+	sampler.SetSampleRate(syslog.Debug, 0)
+
+	sampler.Handle(format.LogParts{"severity": int(syslog.Debug), "hostname": "app-1", "app_name": "api"}, 0, nil)
+	select {
+	case summary := <-summaries:
+		c.Check(summary["sampled_out"], Equals, 1)
+	case <-time.After(5 * time.Second):
+		c.Fatal("no summary")
+	}
+	c.Assert(sampler.Close(), IsNil)
+	c.Check(summaries, HasLen, 0)
+}
@@ -0,0 +1,109 @@
+package payload
+
+import (
+	"bytes"
+	"strings"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+const cefHeaderFields = 7
+
+// CEF decodes ArcSight Common Event Format payloads:
+//
+//	CEF:Version|Device Vendor|Device Product|Device Version|Signature ID|Name|Severity|Extension
+//
+// The header is set in the "cef" field, with the extension key/values under
+// "extensions"
+type CEF struct{}
+
+func (d *CEF) Decode(msg []byte, logParts format.LogParts) bool {
+	payload, ok := findPayload(msg, logParts, "CEF:")
+	if !ok {
+		return false
+	}
+
+	fields := splitEscaped(payload, '|', cefHeaderFields+1)
+	if len(fields) < cefHeaderFields {
+		return false
+	}
+
+	extension := ""
+	if len(fields) > cefHeaderFields {
+		extension = fields[cefHeaderFields]
+	}
+
+	logParts["cef"] = map[string]interface{}{
+		"version":        fields[0],
+		"device_vendor":  fields[1],
+		"device_product": fields[2],
+		"device_version": fields[3],
+		"signature_id":   fields[4],
+		"name":           fields[5],
+		"severity":       fields[6],
+		"extensions":     parseCEFExtension([]byte(extension)),
+	}
+	logParts["payload_format"] = "cef"
+
+	return true
+}
+
+// Parses space separated key=value pairs, where the values may contain
+// spaces: a value extends up to the last space before the next key
+func parseCEFExtension(ext []byte) map[string]string {
+	fields := make(map[string]string)
+	key := ""
+	valueStart := 0
+
+	for i := 0; i < len(ext); i++ {
+		switch ext[i] {
+		case '\\':
+			i++
+		case '=':
+			keyStart := i
+			for keyStart > 0 && ext[keyStart-1] != ' ' {
+				keyStart--
+			}
+			// An unescaped '=' inside a value
+			if keyStart < valueStart || keyStart == i {
+				continue
+			}
+			if key != "" {
+				fields[key] = unescapeCEFValue(bytes.TrimSpace(ext[valueStart:keyStart]))
+			}
+			key = string(ext[keyStart:i])
+			valueStart = i + 1
+		}
+	}
+
+	if key != "" && valueStart <= len(ext) {
+		fields[key] = unescapeCEFValue(bytes.TrimSpace(ext[valueStart:]))
+	}
+
+	return fields
+}
+
+func unescapeCEFValue(value []byte) string {
+	if bytes.IndexByte(value, '\\') < 0 {
+		return string(value)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == '\\' && i+1 < len(value) {
+			i++
+			switch value[i] {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			default:
+				c = value[i]
+			}
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
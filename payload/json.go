@@ -0,0 +1,123 @@
+package payload
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+const (
+	DefaultMaxDepth = 16
+	DefaultMaxSize  = 64 * 1024
+)
+
+// JSON decodes a JSON object, either bare or behind the "@cee:" cookie of the
+// CEE log syntax (as understood by rsyslog mmjsonparse), and merges it into
+// the "fields" field. Numbers are kept as json.Number
+type JSON struct {
+	// Payloads nested deeper than MaxDepth or larger than MaxSize bytes are
+	// left undecoded, DefaultMaxDepth and DefaultMaxSize are used when zero
+	MaxDepth int
+	MaxSize  int
+}
+
+func (d *JSON) Decode(msg []byte, logParts format.LogParts) bool {
+	payloadFormat := "cee"
+	payload, ok := findPayload(msg, logParts, "@cee:")
+	if !ok {
+		payloadFormat = "json"
+		payload = msg
+	}
+
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 || payload[0] != '{' {
+		return false
+	}
+
+	if len(payload) > orDefault(d.MaxSize, DefaultMaxSize) || !withinDepth(payload, orDefault(d.MaxDepth, DefaultMaxDepth)) {
+		return false
+	}
+
+	var fields map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil || fields == nil {
+		return false
+	}
+	// Only trailing spaces are allowed after the object
+	if _, err := decoder.Token(); err != io.EOF {
+		return false
+	}
+
+	mergeFields(logParts, fields)
+	logParts["payload_format"] = payloadFormat
+
+	return true
+}
+
+// Reports whether the objects and arrays of a JSON document are nested at
+// most maxDepth deep, without decoding it
+func withinDepth(payload []byte, maxDepth int) bool {
+	depth := 0
+	inString := false
+
+	for i := 0; i < len(payload); i++ {
+		c := payload[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > maxDepth {
+				return false
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+
+	return true
+}
+
+// Merges fields into the "fields" field of logParts, recursively for the
+// objects present on both sides
+func mergeFields(logParts format.LogParts, fields map[string]interface{}) {
+	existing, ok := logParts["fields"].(map[string]interface{})
+	if !ok {
+		logParts["fields"] = fields
+		return
+	}
+
+	merge(existing, fields)
+}
+
+func merge(dst map[string]interface{}, src map[string]interface{}) {
+	for k, v := range src {
+		if srcMap, ok := v.(map[string]interface{}); ok {
+			if dstMap, ok := dst[k].(map[string]interface{}); ok {
+				merge(dstMap, srcMap)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+func orDefault(v int, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
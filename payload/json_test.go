@@ -0,0 +1,91 @@
+package payload
+
+import (
+	"encoding/json"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+func (s *PayloadSuite) TestJSON_CEE(c *C) {
+	f := NewFormat(&format.RFC3164{}, &JSON{})
+
+	find := `<13>May  1 20:51:40 myhostname @cee: {"msg":"login","user":{"name":"bob","id":42},"tags":["a","b"]}`
+	parser := f.GetParser([]byte(find))
+	c.Assert(parser.Parse(), IsNil)
+
+	logParts := parser.Dump()
+	c.Check(logParts["payload_format"], Equals, "cee")
+	c.Check(logParts["fields"], DeepEquals, map[string]interface{}{
+		"msg": "login",
+		"user": map[string]interface{}{
+			"name": "bob",
+			"id":   json.Number("42"),
+		},
+		"tags": []interface{}{"a", "b"},
+	})
+}
+
+func (s *PayloadSuite) TestJSON_Bare(c *C) {
+	f := NewFormat(&format.RFC5424{}, &JSON{})
+
+	find := `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - {"latency": 12.5, "ok": true}`
+	parser := f.GetParser([]byte(find))
+	c.Assert(parser.Parse(), IsNil)
+
+	logParts := parser.Dump()
+	c.Check(logParts["payload_format"], Equals, "json")
+	c.Check(logParts["fields"], DeepEquals, map[string]interface{}{
+		"latency": json.Number("12.5"),
+		"ok":      true,
+	})
+}
+
+func (s *PayloadSuite) TestJSON_Merge(c *C) {
+	logParts := format.LogParts{
+		"message": `{"user":{"id":42},"new":"x"}`,
+		"fields": map[string]interface{}{
+			"user": map[string]interface{}{"name": "bob"},
+			"old":  "y",
+		},
+	}
+	c.Assert(Decode(logParts, &JSON{}), Equals, true)
+	c.Check(logParts["fields"], DeepEquals, map[string]interface{}{
+		"user": map[string]interface{}{"name": "bob", "id": json.Number("42")},
+		"old":  "y",
+		"new":  "x",
+	})
+}
+
+func (s *PayloadSuite) TestJSON_Invalid(c *C) {
+	fixtures := []string{
+		`plain message`,
+		`["not", "an", "object"]`,
+		`{"unterminated": `,
+		`{"a": 1} trailing`,
+		`@cee: not json`,
+	}
+
+	for _, f := range fixtures {
+		logParts := format.LogParts{"message": f}
+		c.Check(Decode(logParts, &JSON{}), Equals, false, Commentf("%s", f))
+		c.Check(logParts["fields"], IsNil)
+	}
+}
+
+func (s *PayloadSuite) TestJSON_Limits(c *C) {
+	deep := strings.Repeat(`{"a":`, 5) + "1" + strings.Repeat("}", 5)
+	logParts := format.LogParts{"message": deep}
+	c.Check(Decode(logParts, &JSON{MaxDepth: 4}), Equals, false)
+	c.Check(Decode(logParts, &JSON{MaxDepth: 5}), Equals, true)
+
+	// brackets inside strings do not count
+	logParts = format.LogParts{"message": `{"a":"{{{{[[[[\"{{"}`}
+	c.Check(Decode(logParts, &JSON{MaxDepth: 1}), Equals, true)
+
+	large := `{"a":"` + strings.Repeat("x", 100) + `"}`
+	logParts = format.LogParts{"message": large}
+	c.Check(Decode(logParts, &JSON{MaxSize: 100}), Equals, false)
+	c.Check(Decode(logParts, &JSON{MaxSize: 200}), Equals, true)
+}
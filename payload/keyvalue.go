@@ -0,0 +1,105 @@
+package payload
+
+import (
+	"strconv"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+const DefaultMaxFields = 128
+
+// KeyValue decodes logfmt style key=value pairs, with optionally double
+// quoted values, and merges them into the "fields" field:
+//
+//	msg="user logged in" user=bob latency=12ms
+//
+// Words which are not key=value pairs are skipped, at least one pair must be
+// found. Values are kept as strings
+type KeyValue struct {
+	// Only the first MaxFields pairs are kept, and payloads larger than
+	// MaxSize bytes are left undecoded. DefaultMaxFields and DefaultMaxSize
+	// are used when zero
+	MaxFields int
+	MaxSize   int
+}
+
+func (d *KeyValue) Decode(msg []byte, logParts format.LogParts) bool {
+	if len(msg) > orDefault(d.MaxSize, DefaultMaxSize) {
+		return false
+	}
+
+	maxFields := orDefault(d.MaxFields, DefaultMaxFields)
+	fields := make(map[string]interface{})
+
+	for i := 0; i < len(msg) && len(fields) < maxFields; {
+		for i < len(msg) && msg[i] == ' ' {
+			i++
+		}
+
+		from := i
+		for i < len(msg) && msg[i] != ' ' && msg[i] != '=' && msg[i] != '"' {
+			i++
+		}
+		key := string(msg[from:i])
+
+		if i >= len(msg) || msg[i] != '=' || key == "" {
+			// Not a pair, skip the word
+			i = skipWord(msg, i)
+			continue
+		}
+		i++
+
+		value, to, ok := parseValue(msg, i)
+		i = to
+		if ok {
+			fields[key] = value
+		}
+	}
+
+	if len(fields) == 0 {
+		return false
+	}
+
+	mergeFields(logParts, fields)
+	logParts["payload_format"] = "kv"
+
+	return true
+}
+
+// Parses the value at i, either a double quoted string with Go escapes or
+// everything up to the next space
+func parseValue(msg []byte, i int) (string, int, bool) {
+	if i < len(msg) && msg[i] == '"' {
+		for to := i + 1; to < len(msg); to++ {
+			switch msg[to] {
+			case '\\':
+				to++
+			case '"':
+				value, err := strconv.Unquote(string(msg[i : to+1]))
+				return value, to + 1, err == nil
+			}
+		}
+		// Unterminated quote
+		return "", len(msg), false
+	}
+
+	from := i
+	for i < len(msg) && msg[i] != ' ' {
+		i++
+	}
+
+	return string(msg[from:i]), i, true
+}
+
+// Skips to the end of the word at i, including any quoted part of it
+func skipWord(msg []byte, i int) int {
+	for i < len(msg) && msg[i] != ' ' {
+		if msg[i] == '"' {
+			_, to, _ := parseValue(msg, i)
+			i = to
+			continue
+		}
+		i++
+	}
+	return i
+}
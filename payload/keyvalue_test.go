@@ -0,0 +1,59 @@
+package payload
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+func (s *PayloadSuite) TestKeyValue_Valid(c *C) {
+	f := NewFormat(&format.RFC3164{}, &JSON{}, &KeyValue{})
+
+	find := `<13>May  1 20:51:40 myhostname myprogram[42]: msg="user \"bob\" logged in" user=bob latency=12ms empty= path=/a=b`
+	parser := f.GetParser([]byte(find))
+	c.Assert(parser.Parse(), IsNil)
+
+	logParts := parser.Dump()
+	c.Check(logParts["payload_format"], Equals, "kv")
+	c.Check(logParts["fields"], DeepEquals, map[string]interface{}{
+		"msg":     `user "bob" logged in`,
+		"user":    "bob",
+		"latency": "12ms",
+		"empty":   "",
+		"path":    "/a=b",
+	})
+}
+
+func (s *PayloadSuite) TestKeyValue_SkipWords(c *C) {
+	logParts := format.LogParts{
+		"content": `Failed password for "invalid user" root from 10.0.0.1 port=22 = ssh2 proto="ssh`,
+	}
+	c.Assert(Decode(logParts, &KeyValue{}), Equals, true)
+	c.Check(logParts["fields"], DeepEquals, map[string]interface{}{
+		"port": "22",
+	})
+}
+
+func (s *PayloadSuite) TestKeyValue_NoPairs(c *C) {
+	fixtures := []string{
+		`plain message`,
+		`a = b`,
+		`=value`,
+	}
+
+	for _, f := range fixtures {
+		logParts := format.LogParts{"content": f}
+		c.Check(Decode(logParts, &KeyValue{}), Equals, false, Commentf("%s", f))
+		c.Check(logParts["fields"], IsNil)
+	}
+}
+
+func (s *PayloadSuite) TestKeyValue_Limits(c *C) {
+	logParts := format.LogParts{"content": "a=1 b=2 c=3"}
+	c.Assert(Decode(logParts, &KeyValue{MaxFields: 2}), Equals, true)
+	c.Check(logParts["fields"], DeepEquals, map[string]interface{}{"a": "1", "b": "2"})
+
+	logParts = format.LogParts{"content": "a=" + strings.Repeat("x", 100)}
+	c.Check(Decode(logParts, &KeyValue{MaxSize: 100}), Equals, false)
+}
@@ -0,0 +1,108 @@
+package payload
+
+import (
+	"strconv"
+	"strings"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// LEEF decodes IBM Log Event Extended Format 1.0 and 2.0 payloads:
+//
+//	LEEF:1.0|Vendor|Product|Version|EventID|key=value<tab>key=value
+//	LEEF:2.0|Vendor|Product|Version|EventID|Delimiter|key=value<delimiter>key=value
+//
+// The header is set in the "leef" field, with the key/values under
+// "attributes"
+type LEEF struct{}
+
+func (d *LEEF) Decode(msg []byte, logParts format.LogParts) bool {
+	payload, ok := findPayload(msg, logParts, "LEEF:")
+	if !ok {
+		return false
+	}
+
+	version := string(payload)
+	if i := strings.IndexByte(version, '|'); i >= 0 {
+		version = version[:i]
+	}
+
+	// LEEF 2.0 adds the delimiter to the header
+	n := 0
+	switch {
+	case strings.HasPrefix(version, "1."):
+		n = 6
+	case strings.HasPrefix(version, "2."):
+		n = 7
+	default:
+		return false
+	}
+
+	fields := splitEscaped(payload, '|', n)
+	if len(fields) < 5 {
+		return false
+	}
+
+	delimiter := "\t"
+	if n == 7 && len(fields) > 5 {
+		if d, ok := parseLEEFDelimiter(fields[5]); ok {
+			delimiter = d
+		}
+	}
+
+	attributes := ""
+	if len(fields) == n {
+		attributes = fields[n-1]
+	} else if n == 7 && len(fields) == 6 && strings.IndexByte(fields[5], '=') >= 0 {
+		// LEEF 2.0 sent without the delimiter field
+		attributes = fields[5]
+	}
+
+	logParts["leef"] = map[string]interface{}{
+		"version":         version,
+		"vendor":          fields[1],
+		"product":         fields[2],
+		"product_version": fields[3],
+		"event_id":        fields[4],
+		"delimiter":       delimiter,
+		"attributes":      parseLEEFAttributes(attributes, delimiter),
+	}
+	logParts["payload_format"] = "leef"
+
+	return true
+}
+
+// The delimiter is either a single character or its hex code, as "x09" or
+// "0x09"
+func parseLEEFDelimiter(s string) (string, bool) {
+	if len(s) == 1 {
+		return s, true
+	}
+
+	hex := strings.TrimPrefix(s, "0")
+	if !strings.HasPrefix(hex, "x") || len(hex) < 2 || len(hex) > 5 {
+		return "", false
+	}
+	hex = hex[1:]
+
+	r, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return "", false
+	}
+
+	return string(rune(r)), true
+}
+
+func parseLEEFAttributes(s string, delimiter string) map[string]string {
+	attributes := make(map[string]string)
+
+	for _, pair := range strings.Split(s, delimiter) {
+		i := strings.IndexByte(pair, '=')
+		if i <= 0 {
+			continue
+		}
+		attributes[strings.TrimSpace(pair[:i])] = pair[i+1:]
+	}
+
+	return attributes
+}
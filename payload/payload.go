@@ -0,0 +1,133 @@
+// Package payload decodes the structured payloads carried in the body of
+// syslog messages (CEF, LEEF, ...) into fields of the parsed message
+package payload
+
+import (
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// Decoder extracts structured fields from the body of a parsed message
+type Decoder interface {
+	// Decode adds the fields found in msg to logParts, it returns false if
+	// msg is not in the format of the decoder
+	Decode(msg []byte, logParts format.LogParts) bool
+}
+
+// Format wraps a format.Format, the body of every message it parses is
+// handed to the decoders in turn until one of them recognizes it. The
+// matching decoder records the payload format in the "payload_format" field
+type Format struct {
+	format.Format
+	Decoders []Decoder
+}
+
+// NewFormat returns a new Format decoding the payloads of the messages parsed
+// by f. As KeyValue accepts almost anything, it should come last:
+//
+//	payload.NewFormat(syslog.Automatic, &payload.CEF{}, &payload.LEEF{}, &payload.JSON{}, &payload.KeyValue{})
+func NewFormat(f format.Format, decoders ...Decoder) *Format {
+	return &Format{
+		Format:   f,
+		Decoders: decoders,
+	}
+}
+
+func (f *Format) GetParser(line []byte) format.LogParser {
+	return &parser{
+		LogParser: f.Format.GetParser(line),
+		decoders:  f.Decoders,
+	}
+}
+
+type parser struct {
+	format.LogParser
+	decoders []Decoder
+	logParts format.LogParts
+}
+
+func (p *parser) Parse() error {
+	err := p.LogParser.Parse()
+	p.logParts = p.LogParser.Dump()
+	Decode(p.logParts, p.decoders...)
+	return err
+}
+
+func (p *parser) Dump() format.LogParts {
+	if p.logParts == nil {
+		return p.LogParser.Dump()
+	}
+	return p.logParts
+}
+
+// Decode runs the decoders on the body of an already parsed message, i.e. its
+// "message" (RFC5424) or "content" (RFC3164) field. It returns false if none
+// of them recognized it
+func Decode(logParts format.LogParts, decoders ...Decoder) bool {
+	msg, ok := Body(logParts)
+	if !ok || len(msg) == 0 {
+		return false
+	}
+
+	for _, decoder := range decoders {
+		if decoder.Decode([]byte(msg), logParts) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Body returns the body of a parsed message
+func Body(logParts format.LogParts) (string, bool) {
+	if msg, ok := logParts["message"].(string); ok {
+		return msg, true
+	}
+	msg, ok := logParts["content"].(string)
+	return msg, ok
+}
+
+// Returns the body of a message prefixed by cookie, taking into account that
+// an RFC3164 parser will have read something like "CEF:0|..." as the tag
+// "CEF" followed by the content "0|..."
+func findPayload(msg []byte, logParts format.LogParts, cookie string) ([]byte, bool) {
+	if tag, _ := logParts["tag"].(string); tag+":" == cookie && len(msg) > 0 && msg[0] != ' ' {
+		return msg, true
+	}
+
+	for i := 0; i+len(cookie) <= len(msg); i++ {
+		if (i == 0 || msg[i-1] == ' ') && string(msg[i:i+len(cookie)]) == cookie {
+			return msg[i+len(cookie):], true
+		}
+	}
+
+	return nil, false
+}
+
+// Splits s on unescaped occurrences of sep, up to n fields, the last one
+// holding the rest of s as is. Backslash escapes are removed from the other
+// fields
+func splitEscaped(s []byte, sep byte, n int) []string {
+	var fields []string
+	var field []byte
+
+	for i := 0; i < len(s); i++ {
+		if len(fields) == n-1 {
+			return append(fields, string(s[i:]))
+		}
+
+		c := s[i]
+		if c == '\\' && i+1 < len(s) && (s[i+1] == sep || s[i+1] == '\\') {
+			field = append(field, s[i+1])
+			i++
+			continue
+		}
+		if c == sep {
+			fields = append(fields, string(field))
+			field = field[:0]
+			continue
+		}
+		field = append(field, c)
+	}
+
+	return append(fields, string(field))
+}
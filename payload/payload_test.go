@@ -0,0 +1,165 @@
+package payload
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type PayloadSuite struct{}
+
+var _ = Suite(&PayloadSuite{})
+
+func (s *PayloadSuite) TestFormat_RFC5424(c *C) {
+	f := NewFormat(&format.RFC5424{}, &CEF{}, &LEEF{})
+
+	find := `<134>1 2019-01-11T10:00:00Z fw01 - - - - CEF:0|Security|threatmanager|1.0|100|worm successfully stopped|10|src=10.0.0.1 dst=2.1.2.2 spt=1232`
+	parser := f.GetParser([]byte(find))
+	c.Assert(parser.Parse(), IsNil)
+
+	logParts := parser.Dump()
+	c.Check(logParts["hostname"], Equals, "fw01")
+	c.Check(logParts["payload_format"], Equals, "cef")
+	c.Check(logParts["cef"], DeepEquals, map[string]interface{}{
+		"version":        "0",
+		"device_vendor":  "Security",
+		"device_product": "threatmanager",
+		"device_version": "1.0",
+		"signature_id":   "100",
+		"name":           "worm successfully stopped",
+		"severity":       "10",
+		"extensions": map[string]string{
+			"src": "10.0.0.1",
+			"dst": "2.1.2.2",
+			"spt": "1232",
+		},
+	})
+}
+
+func (s *PayloadSuite) TestFormat_RFC3164Tag(c *C) {
+	f := NewFormat(&format.RFC3164{}, &CEF{}, &LEEF{})
+
+	find := `<134>Jan 11 10:00:00 fw01 LEEF:1.0|Microsoft|MSExchange|4.0 SP1|15345|src=192.0.2.0	dst=172.50.123.1	sev=5`
+	parser := f.GetParser([]byte(find))
+	c.Assert(parser.Parse(), IsNil)
+
+	logParts := parser.Dump()
+	c.Check(logParts["tag"], Equals, "LEEF")
+	c.Check(logParts["payload_format"], Equals, "leef")
+	c.Check(logParts["leef"], DeepEquals, map[string]interface{}{
+		"version":         "1.0",
+		"vendor":          "Microsoft",
+		"product":         "MSExchange",
+		"product_version": "4.0 SP1",
+		"event_id":        "15345",
+		"delimiter":       "\t",
+		"attributes": map[string]string{
+			"src": "192.0.2.0",
+			"dst": "172.50.123.1",
+			"sev": "5",
+		},
+	})
+}
+
+func (s *PayloadSuite) TestFormat_NoPayload(c *C) {
+	f := NewFormat(&format.RFC3164{}, &CEF{}, &LEEF{})
+
+	find := `<13>May  1 20:51:40 myhostname myprogram: ciao`
+	parser := f.GetParser([]byte(find))
+	c.Assert(parser.Parse(), IsNil)
+
+	logParts := parser.Dump()
+	c.Check(logParts["content"], Equals, "ciao")
+	c.Check(logParts["payload_format"], IsNil)
+	c.Check(logParts["cef"], IsNil)
+	c.Check(f.GetSplitFunc(), IsNil)
+}
+
+func (s *PayloadSuite) TestCEF_Escaping(c *C) {
+	logParts := format.LogParts{
+		"content": `CEF:0|Ven\|dor|Prod\\uct|1.0|sig|Name \| with pipe|Low|msg=a \= b and c\\d\nnext request=http://x/?a=b&c=d cs1Label=Rule Name cs1=Block all`,
+	}
+	c.Assert(Decode(logParts, &CEF{}), Equals, true)
+
+	cef := logParts["cef"].(map[string]interface{})
+	c.Check(cef["device_vendor"], Equals, "Ven|dor")
+	c.Check(cef["device_product"], Equals, `Prod\uct`)
+	c.Check(cef["name"], Equals, "Name | with pipe")
+	c.Check(cef["severity"], Equals, "Low")
+	c.Check(cef["extensions"], DeepEquals, map[string]string{
+		"msg":      "a = b and c\\d\nnext",
+		"request":  "http://x/?a=b&c=d",
+		"cs1Label": "Rule Name",
+		"cs1":      "Block all",
+	})
+}
+
+func (s *PayloadSuite) TestCEF_Prefixed(c *C) {
+	logParts := format.LogParts{
+		"message": `Jan 11 10:00:00 host CEF:1|Vendor|Product|2|sig|Name|5|`,
+	}
+	c.Assert(Decode(logParts, &CEF{}), Equals, true)
+
+	cef := logParts["cef"].(map[string]interface{})
+	c.Check(cef["version"], Equals, "1")
+	c.Check(cef["severity"], Equals, "5")
+	c.Check(cef["extensions"], DeepEquals, map[string]string{})
+}
+
+func (s *PayloadSuite) TestCEF_Invalid(c *C) {
+	fixtures := []string{
+		"CEF:0|Vendor|Product|1.0",
+		"NOTCEF:0|Vendor|Product|1.0|sig|Name|5|",
+		"plain message",
+	}
+
+	for _, f := range fixtures {
+		logParts := format.LogParts{"message": f}
+		c.Check(Decode(logParts, &CEF{}), Equals, false, Commentf("%s", f))
+		c.Check(logParts["cef"], IsNil)
+	}
+}
+
+func (s *PayloadSuite) TestLEEF_2Delimiters(c *C) {
+	fixtures := []struct {
+		msg       string
+		delimiter string
+	}{
+		{"LEEF:2.0|Lancope|StealthWatch|1.0|41|^|src=10.0.1.8^dst=10.0.0.5^sev=5", "^"},
+		{"LEEF:2.0|Lancope|StealthWatch|1.0|41|x5E|src=10.0.1.8^dst=10.0.0.5^sev=5", "^"},
+		{"LEEF:2.0|Lancope|StealthWatch|1.0|41|0x5E|src=10.0.1.8^dst=10.0.0.5^sev=5", "^"},
+		{"LEEF:2.0|Lancope|StealthWatch|1.0|41|x09|src=10.0.1.8\tdst=10.0.0.5\tsev=5", "\t"},
+		{"LEEF:2.0|Lancope|StealthWatch|1.0|41|src=10.0.1.8\tdst=10.0.0.5\tsev=5", "\t"},
+	}
+
+	for _, f := range fixtures {
+		logParts := format.LogParts{"message": f.msg}
+		c.Assert(Decode(logParts, &LEEF{}), Equals, true, Commentf("%s", f.msg))
+
+		leef := logParts["leef"].(map[string]interface{})
+		c.Check(leef["version"], Equals, "2.0")
+		c.Check(leef["event_id"], Equals, "41")
+		c.Check(leef["delimiter"], Equals, f.delimiter, Commentf("%s", f.msg))
+		c.Check(leef["attributes"], DeepEquals, map[string]string{
+			"src": "10.0.1.8",
+			"dst": "10.0.0.5",
+			"sev": "5",
+		}, Commentf("%s", f.msg))
+	}
+}
+
+func (s *PayloadSuite) TestLEEF_Invalid(c *C) {
+	fixtures := []string{
+		"LEEF:3.0|Vendor|Product|1.0|41|",
+		"LEEF:1.0|Vendor|Product",
+		"plain message",
+	}
+
+	for _, f := range fixtures {
+		logParts := format.LogParts{"message": f}
+		c.Check(Decode(logParts, &LEEF{}), Equals, false, Commentf("%s", f))
+	}
+}
@@ -0,0 +1,212 @@
+package syslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// Facility is the facility of a message, i.e. the subsystem it comes from
+type Facility int
+
+const (
+	Kern        Facility = iota // kernel messages
+	User                        // user-level messages
+	Mail                        // mail system
+	Daemon                      // system daemons
+	Auth                        // security/authorization messages
+	Syslog                      // messages generated internally by syslogd
+	LPR                         // line printer subsystem
+	News                        // network news subsystem
+	UUCP                        // UUCP subsystem
+	Cron                        // clock daemon
+	AuthPriv                    // security/authorization messages, private
+	FTP                         // FTP daemon
+	NTP                         // NTP subsystem
+	Security                    // log audit
+	Console                     // log alert
+	SolarisCron                 // clock daemon, on Solaris
+	Local0                      // local use 0
+	Local1                      // local use 1
+	Local2                      // local use 2
+	Local3                      // local use 3
+	Local4                      // local use 4
+	Local5                      // local use 5
+	Local6                      // local use 6
+	Local7                      // local use 7
+)
+
+// Severity is the severity of a message, lower values being more severe
+type Severity int
+
+const (
+	Emergency     Severity = iota // system is unusable
+	Alert                         // action must be taken immediately
+	Critical                      // critical conditions
+	Error                         // error conditions
+	Warning                       // warning conditions
+	Notice                        // normal but significant condition
+	Informational                 // informational messages
+	Debug                         // debug-level messages
+)
+
+var facilityKeywords = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// Names accepted by ParseFacility besides the keywords. The facilities 13 to
+// 15 are named differently across systems
+var facilityAliases = map[string]Facility{
+	"audit":    Security,
+	"logaudit": Security,
+	"alert":    Console,
+	"logalert": Console,
+	"clock":    SolarisCron,
+}
+
+var severityKeywords = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+// Names accepted by ParseSeverity besides the keywords
+var severityAliases = map[string]Severity{
+	"panic":         Emergency,
+	"emergency":     Emergency,
+	"critical":      Critical,
+	"error":         Error,
+	"warn":          Warning,
+	"informational": Informational,
+}
+
+// String returns the keyword of the facility, e.g. "local3"
+func (f Facility) String() string {
+	if !f.Valid() {
+		return "facility(" + strconv.Itoa(int(f)) + ")"
+	}
+	return facilityKeywords[f]
+}
+
+// Valid tells whether the facility is one of the 24 defined ones
+func (f Facility) Valid() bool {
+	return f >= Kern && f <= Local7
+}
+
+// ParseFacility returns the facility of the given keyword, alias or number,
+// case insensitively
+func ParseFacility(s string) (Facility, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for i, keyword := range facilityKeywords {
+		if s == keyword {
+			return Facility(i), nil
+		}
+	}
+	if f, ok := facilityAliases[s]; ok {
+		return f, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && Facility(n).Valid() {
+		return Facility(n), nil
+	}
+	return 0, fmt.Errorf("unknown facility %q", s)
+}
+
+func (f Facility) MarshalText() ([]byte, error) {
+	if !f.Valid() {
+		return nil, fmt.Errorf("invalid facility %d", int(f))
+	}
+	return []byte(f.String()), nil
+}
+
+func (f *Facility) UnmarshalText(text []byte) error {
+	parsed, err := ParseFacility(string(text))
+	if err != nil {
+		return err
+	}
+	*f = parsed
+	return nil
+}
+
+// UnmarshalJSON accepts the facility as a string, or as a number
+func (f *Facility) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return f.UnmarshalText(data)
+	}
+	return f.UnmarshalText([]byte(s))
+}
+
+// String returns the keyword of the severity, e.g. "err"
+func (s Severity) String() string {
+	if !s.Valid() {
+		return "severity(" + strconv.Itoa(int(s)) + ")"
+	}
+	return severityKeywords[s]
+}
+
+// Valid tells whether the severity is one of the 8 defined ones
+func (s Severity) Valid() bool {
+	return s >= Emergency && s <= Debug
+}
+
+// AtLeast tells whether s is as severe as other or more, e.g.
+// Error.AtLeast(Warning) is true
+func (s Severity) AtLeast(other Severity) bool {
+	return s <= other
+}
+
+// ParseSeverity returns the severity of the given keyword, alias or number,
+// case insensitively
+func ParseSeverity(str string) (Severity, error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	for i, keyword := range severityKeywords {
+		if str == keyword {
+			return Severity(i), nil
+		}
+	}
+	if s, ok := severityAliases[str]; ok {
+		return s, nil
+	}
+	if n, err := strconv.Atoi(str); err == nil && Severity(n).Valid() {
+		return Severity(n), nil
+	}
+	return 0, fmt.Errorf("unknown severity %q", str)
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	if !s.Valid() {
+		return nil, fmt.Errorf("invalid severity %d", int(s))
+	}
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	parsed, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// UnmarshalJSON accepts the severity as a string, or as a number
+func (s *Severity) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return s.UnmarshalText(data)
+	}
+	return s.UnmarshalText([]byte(str))
+}
+
+// Adds the keywords of the facility and severity of a message, when valid
+func addNameFields(logParts format.LogParts) {
+	if f, ok := logParts["facility"].(int); ok && Facility(f).Valid() {
+		logParts["facility_name"] = Facility(f).String()
+	}
+	if s, ok := logParts["severity"].(int); ok && Severity(s).Valid() {
+		logParts["severity_name"] = Severity(s).String()
+	}
+}
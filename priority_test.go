@@ -0,0 +1,124 @@
+package syslog
+
+import (
+	"encoding/json"
+
+	. "gopkg.in/check.v1"
+)
+
+type PrioritySuite struct{}
+
+var _ = Suite(&PrioritySuite{})
+
+func (s *PrioritySuite) TestFacilityString(c *C) {
+	c.Check(Kern.String(), Equals, "kern")
+	c.Check(AuthPriv.String(), Equals, "authpriv")
+	c.Check(NTP.String(), Equals, "ntp")
+	c.Check(Security.String(), Equals, "security")
+	c.Check(Console.String(), Equals, "console")
+	c.Check(SolarisCron.String(), Equals, "solaris-cron")
+	c.Check(Local3.String(), Equals, "local3")
+	c.Check(Facility(24).String(), Equals, "facility(24)")
+	c.Check(Facility(-1).Valid(), Equals, false)
+}
+
+func (s *PrioritySuite) TestParseFacility(c *C) {
+	fixtures := map[string]Facility{
+		"kern":     Kern,
+		"LOCAL3":   Local3,
+		" auth ":   Auth,
+		"authpriv": AuthPriv,
+		"audit":    Security,
+		"alert":    Console,
+		"clock":    SolarisCron,
+		"10":       AuthPriv,
+		"23":       Local7,
+	}
+	for name, expected := range fixtures {
+		f, err := ParseFacility(name)
+		c.Check(err, IsNil)
+		c.Check(f, Equals, expected, Commentf("%s", name))
+	}
+
+	for _, name := range []string{"", "local8", "24", "-1"} {
+		_, err := ParseFacility(name)
+		c.Check(err, ErrorMatches, "unknown facility .*", Commentf("%s", name))
+	}
+}
+
+func (s *PrioritySuite) TestSeverityString(c *C) {
+	c.Check(Emergency.String(), Equals, "emerg")
+	c.Check(Error.String(), Equals, "err")
+	c.Check(Warning.String(), Equals, "warning")
+	c.Check(Informational.String(), Equals, "info")
+	c.Check(Severity(8).String(), Equals, "severity(8)")
+}
+
+func (s *PrioritySuite) TestParseSeverity(c *C) {
+	fixtures := map[string]Severity{
+		"emerg":   Emergency,
+		"panic":   Emergency,
+		"err":     Error,
+		"Error":   Error,
+		"warn":    Warning,
+		"warning": Warning,
+		"info":    Informational,
+		"7":       Debug,
+	}
+	for name, expected := range fixtures {
+		sev, err := ParseSeverity(name)
+		c.Check(err, IsNil)
+		c.Check(sev, Equals, expected, Commentf("%s", name))
+	}
+
+	for _, name := range []string{"", "fatal", "8"} {
+		_, err := ParseSeverity(name)
+		c.Check(err, ErrorMatches, "unknown severity .*", Commentf("%s", name))
+	}
+}
+
+func (s *PrioritySuite) TestAtLeast(c *C) {
+	c.Check(Error.AtLeast(Warning), Equals, true)
+	c.Check(Warning.AtLeast(Warning), Equals, true)
+	c.Check(Notice.AtLeast(Warning), Equals, false)
+	c.Check(Emergency.AtLeast(Debug), Equals, true)
+}
+
+func (s *PrioritySuite) TestJSON(c *C) {
+	type priority struct {
+		Facility Facility `json:"facility"`
+		Severity Severity `json:"severity"`
+	}
+
+	b, err := json.Marshal(priority{Local3, Warning})
+	c.Assert(err, IsNil)
+	c.Check(string(b), Equals, `{"facility":"local3","severity":"warning"}`)
+
+	var p priority
+	c.Assert(json.Unmarshal([]byte(`{"facility":"authpriv","severity":"err"}`), &p), IsNil)
+	c.Check(p, Equals, priority{AuthPriv, Error})
+
+	c.Assert(json.Unmarshal([]byte(`{"facility":4,"severity":6}`), &p), IsNil)
+	c.Check(p, Equals, priority{Auth, Informational})
+
+	c.Check(json.Unmarshal([]byte(`{"facility":"nope"}`), &p), ErrorMatches, `unknown facility "nope"`)
+	c.Check(json.Unmarshal([]byte(`{"severity":9}`), &p), ErrorMatches, `unknown severity "9"`)
+
+	_, err = json.Marshal(priority{Facility(42), Warning})
+	c.Check(err, ErrorMatches, ".*invalid facility 42")
+}
+
+func (s *PrioritySuite) TestNameFields(c *C) {
+	handler := new(HandlerMock)
+	server := NewServer()
+	server.SetFormat(RFC3164)
+	server.SetHandler(handler)
+
+	server.parser([]byte("<34>Oct 11 22:14:15 mymachine su: 'su root' failed"), "192.0.2.1:514", "")
+	c.Check(handler.LastLogParts["facility_name"], IsNil)
+
+	server.SetNameFields(true)
+	server.parser([]byte("<34>Oct 11 22:14:15 mymachine su: 'su root' failed"), "192.0.2.1:514", "")
+	c.Check(handler.LastLogParts["facility_name"], Equals, "auth")
+	c.Check(handler.LastLogParts["severity_name"], Equals, "crit")
+}
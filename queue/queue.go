@@ -0,0 +1,550 @@
+// Package queue provides a durable queue of records on disk, a write-ahead log
+// made of segmented append-only files with checksums, so that the records
+// survive process restarts and the outages of their consumer
+package queue
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	SegmentSizeDefault     = 64 << 20
+	CheckpointEveryDefault = 1000
+
+	// Beyond it the length of a record is considered corrupted
+	RecordSizeMax = 16 << 20
+
+	recordHeaderSize = 8 // length and checksum
+	segmentSuffix    = ".wal"
+	checkpointName   = "checkpoint"
+)
+
+var (
+	ErrFull     = errors.New("queue full")
+	ErrClosed   = errors.New("queue closed")
+	ErrCanceled = errors.New("queue read canceled")
+	ErrTooLarge = errors.New("record too large")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// An append-only file of records, named after its ID
+type segment struct {
+	id   uint64
+	size int64
+	file *os.File // for reading, opened on demand
+}
+
+// A position in the queue
+type position struct {
+	segment uint64
+	offset  int64
+}
+
+// Queue is a durable FIFO queue of records, appended by any number of
+// goroutines and read by a single consumer.
+//
+// The consumer commits the records it is done with, the position after them
+// being checkpointed to disk every CheckpointEveryDefault commits, when it
+// reads all the records, and on Close. The records are delivered at least
+// once: those not checkpointed yet are read again after a crash. Each record
+// is stored with a CRC-32C checksum: a torn record at the end of the queue
+// is discarded when opening it, and a corrupted record makes the consumer
+// skip the rest of its segment.
+//
+// The segments are deleted once checkpointed. The records are refused with
+// ErrFull when the segments reach the disk budget
+type Queue struct {
+	dropped   uint64 // first for 64-bit alignment of atomic operations
+	corrupted uint64
+
+	dir             string
+	maxBytes        int64
+	segmentSize     int64
+	checkpointEvery int
+	sync            bool
+
+	mu         sync.Mutex
+	segments   []*segment // oldest first, the last one being written
+	writer     *os.File
+	bytes      int64
+	read       position // of the next record to read
+	committed  position
+	checkpoint position // on disk
+	commits    int      // since the checkpoint
+	notify     chan struct{}
+	closed     chan struct{}
+}
+
+// Open opens the queue stored in dir, creating it if needed, its segments
+// taking up to maxBytes, unlimited if 0
+func Open(dir string, maxBytes int64) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	q := &Queue{
+		dir:             dir,
+		maxBytes:        maxBytes,
+		segmentSize:     SegmentSizeDefault,
+		checkpointEvery: CheckpointEveryDefault,
+		notify:          make(chan struct{}, 1),
+		closed:          make(chan struct{}),
+	}
+	if err := q.load(); err != nil {
+		q.closeFiles()
+		return nil, err
+	}
+	return q, nil
+}
+
+// Sets the size from which the records go to a new segment, defaults to
+// SegmentSizeDefault. It is limited to half the disk budget
+func (q *Queue) SetSegmentSize(size int64) {
+	q.mu.Lock()
+	q.segmentSize = size
+	q.mu.Unlock()
+}
+
+// Sets the number of commits triggering a checkpoint, defaults to
+// CheckpointEveryDefault
+func (q *Queue) SetCheckpointEvery(n int) {
+	q.mu.Lock()
+	q.checkpointEvery = n
+	q.mu.Unlock()
+}
+
+// Sets whether every record is synced to disk before Append returns, not to
+// be lost on a system crash. Defaults to false, the records then only
+// surviving a crash of the process
+func (q *Queue) SetSync(sync bool) {
+	q.mu.Lock()
+	q.sync = sync
+	q.mu.Unlock()
+}
+
+func (q *Queue) segmentPath(id uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%016x%s", id, segmentSuffix))
+}
+
+// Loads the checkpoint and the segments, discarding a torn record at the end
+func (q *Queue) load() error {
+	files, err := ioutil.ReadDir(q.dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 16, 64)
+		if err != nil {
+			continue
+		}
+		q.segments = append(q.segments, &segment{id: id, size: f.Size()})
+		q.bytes += f.Size()
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i].id < q.segments[j].id })
+
+	cp, err := q.readCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	if len(q.segments) == 0 {
+		id := cp.segment
+		if id == 0 {
+			id = 1
+		}
+		q.segments = append(q.segments, &segment{id: id})
+	} else if err := q.truncateTorn(q.segments[len(q.segments)-1]); err != nil {
+		return err
+	}
+
+	// The checkpointed segment may have been deleted, or be missing
+	if first := q.segments[0]; cp.segment < first.id || q.find(cp.segment) == nil {
+		cp = position{segment: first.id}
+	}
+	q.checkpoint, q.committed, q.read = cp, cp, cp
+
+	last := q.segments[len(q.segments)-1]
+	q.writer, err = os.OpenFile(q.segmentPath(last.id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	return err
+}
+
+func (q *Queue) readCheckpoint() (position, error) {
+	b, err := ioutil.ReadFile(filepath.Join(q.dir, checkpointName))
+	if os.IsNotExist(err) {
+		return position{}, nil
+	}
+	if err != nil {
+		return position{}, err
+	}
+	if len(b) != 20 || crc32.Checksum(b[:16], crcTable) != binary.BigEndian.Uint32(b[16:]) {
+		return position{}, fmt.Errorf("corrupted checkpoint in %s", q.dir)
+	}
+	return position{
+		segment: binary.BigEndian.Uint64(b),
+		offset:  int64(binary.BigEndian.Uint64(b[8:])),
+	}, nil
+}
+
+// Writes the committed position, and deletes the segments before it. Must be
+// called with mu held
+func (q *Queue) writeCheckpoint() error {
+	q.commits = 0
+	if q.committed == q.checkpoint {
+		return nil
+	}
+	b := make([]byte, 20)
+	binary.BigEndian.PutUint64(b, q.committed.segment)
+	binary.BigEndian.PutUint64(b[8:], uint64(q.committed.offset))
+	binary.BigEndian.PutUint32(b[16:], crc32.Checksum(b[:16], crcTable))
+
+	tmp := filepath.Join(q.dir, checkpointName+".tmp")
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(q.dir, checkpointName)); err != nil {
+		return err
+	}
+	q.checkpoint = q.committed
+
+	for len(q.segments) > 1 && q.segments[0].id < q.checkpoint.segment {
+		s := q.segments[0]
+		if s.file != nil {
+			s.file.Close()
+		}
+		if err := os.Remove(q.segmentPath(s.id)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		q.bytes -= s.size
+		q.segments = q.segments[1:]
+	}
+	return nil
+}
+
+// Truncates the segment after its last complete and valid record
+func (q *Queue) truncateTorn(s *segment) error {
+	f, err := os.OpenFile(q.segmentPath(s.id), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var offset int64
+	for offset < s.size {
+		_, n, err := readRecord(f, offset, s.size)
+		if err != nil {
+			break
+		}
+		offset += n
+	}
+	if offset < s.size {
+		if err := f.Truncate(offset); err != nil {
+			return err
+		}
+		q.bytes -= s.size - offset
+		s.size = offset
+	}
+	return nil
+}
+
+var errCorrupted = errors.New("corrupted record")
+
+// Reads the record at offset of a segment of the given size, returning it
+// along with its size on disk
+func readRecord(r io.ReaderAt, offset int64, size int64) ([]byte, int64, error) {
+	if size-offset < recordHeaderSize {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	header := make([]byte, recordHeaderSize)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return nil, 0, err
+	}
+	length := int64(binary.BigEndian.Uint32(header))
+	if length > RecordSizeMax {
+		return nil, 0, errCorrupted
+	}
+	if size-offset-recordHeaderSize < length {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	record := make([]byte, length)
+	if _, err := r.ReadAt(record, offset+recordHeaderSize); err != nil {
+		return nil, 0, err
+	}
+	if crc32.Checksum(record, crcTable) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, 0, errCorrupted
+	}
+	return record, recordHeaderSize + length, nil
+}
+
+// Returns the segment of the given ID, nil if none. Must be called with mu held
+func (q *Queue) find(id uint64) *segment {
+	for _, s := range q.segments {
+		if s.id == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// Appends a record to the queue
+func (q *Queue) Append(record []byte) error {
+	if len(record) > RecordSizeMax {
+		return ErrTooLarge
+	}
+	size := int64(recordHeaderSize + len(record))
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case <-q.closed:
+		return ErrClosed
+	default:
+	}
+
+	if q.maxBytes > 0 && q.bytes+size > q.maxBytes {
+		if err := q.free(); err != nil {
+			return err
+		}
+	}
+	if q.maxBytes > 0 && q.bytes+size > q.maxBytes {
+		atomic.AddUint64(&q.dropped, 1)
+		return ErrFull
+	}
+
+	last := q.segments[len(q.segments)-1]
+	if last.size > 0 && last.size+size > q.segmentLimit() {
+		if err := q.roll(); err != nil {
+			return err
+		}
+		last = q.segments[len(q.segments)-1]
+	}
+
+	b := make([]byte, size)
+	binary.BigEndian.PutUint32(b, uint32(len(record)))
+	binary.BigEndian.PutUint32(b[4:], crc32.Checksum(record, crcTable))
+	copy(b[recordHeaderSize:], record)
+	n, err := q.writer.Write(b)
+	last.size += int64(n)
+	q.bytes += int64(n)
+	if err != nil {
+		// Do not append after a torn record
+		q.roll()
+		return err
+	}
+	if q.sync {
+		if err := q.writer.Sync(); err != nil {
+			return err
+		}
+	}
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Returns the size from which the records go to a new segment, at most half
+// the disk budget so that the segments committed can be deleted while the
+// others are kept. Must be called with mu held
+func (q *Queue) segmentLimit() int64 {
+	if q.maxBytes > 0 && q.segmentSize > q.maxBytes/2 {
+		return q.maxBytes / 2
+	}
+	return q.segmentSize
+}
+
+// Frees the segments committed, the one being written included when all its
+// records are, by starting a new one. Must be called with mu held
+func (q *Queue) free() error {
+	if s := q.find(q.committed.segment); s != nil && q.committed.offset >= s.size {
+		// Committed up to the end of a segment, so from the start of the next
+		i := q.indexOf(s)
+		if i == len(q.segments)-1 && s.size > 0 {
+			if err := q.roll(); err != nil {
+				return err
+			}
+		}
+		if i < len(q.segments)-1 {
+			next := position{segment: q.segments[i+1].id}
+			if q.read == q.committed {
+				q.read = next
+			}
+			q.committed = next
+		}
+	}
+	if q.committed != q.checkpoint {
+		return q.writeCheckpoint()
+	}
+	return nil
+}
+
+// Starts a new segment. Must be called with mu held
+func (q *Queue) roll() error {
+	id := q.segments[len(q.segments)-1].id + 1
+	writer, err := os.OpenFile(q.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	q.writer.Close()
+	q.writer = writer
+	q.segments = append(q.segments, &segment{id: id})
+	return nil
+}
+
+// Next returns the next record, waiting for one to be appended if needed,
+// until cancel is closed or the queue closed. Commit tells when the consumer
+// is done with it
+func (q *Queue) Next(cancel <-chan struct{}) ([]byte, error) {
+	for {
+		record, err := q.next()
+		if record != nil || err != nil {
+			return record, err
+		}
+		select {
+		case <-q.notify:
+		case <-cancel:
+			return nil, ErrCanceled
+		case <-q.closed:
+			return nil, ErrClosed
+		}
+	}
+}
+
+// Returns the next record, nil if there is none yet
+func (q *Queue) next() ([]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case <-q.closed:
+		return nil, ErrClosed
+	default:
+	}
+
+	for {
+		s := q.find(q.read.segment)
+		last := s == q.segments[len(q.segments)-1]
+		if q.read.offset >= s.size {
+			if last {
+				// All read, a good time to checkpoint
+				return nil, q.writeCheckpoint()
+			}
+			q.read = position{segment: q.segments[q.indexOf(s)+1].id}
+			continue
+		}
+
+		if s.file == nil {
+			f, err := os.Open(q.segmentPath(s.id))
+			if err != nil {
+				return nil, err
+			}
+			s.file = f
+		}
+		record, n, err := readRecord(s.file, q.read.offset, s.size)
+		if err == nil {
+			q.read.offset += n
+			return record, nil
+		}
+		if err != errCorrupted && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+
+		// Skip the rest of the segment
+		atomic.AddUint64(&q.corrupted, 1)
+		q.read.offset = s.size
+		if last {
+			if err := q.roll(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Returns the index of a segment. Must be called with mu held
+func (q *Queue) indexOf(s *segment) int {
+	for i, other := range q.segments {
+		if other == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// Commit tells that the consumer is done with the records returned by Next so
+// far, to be checkpointed
+func (q *Queue) Commit() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case <-q.closed:
+		return ErrClosed
+	default:
+	}
+
+	q.committed = q.read
+	q.commits++
+	if q.commits >= q.checkpointEvery {
+		return q.writeCheckpoint()
+	}
+	return nil
+}
+
+// Size returns the size in bytes of the segments on disk
+func (q *Queue) Size() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.bytes
+}
+
+// Dropped returns the number of records refused as the queue was full
+func (q *Queue) Dropped() uint64 {
+	return atomic.LoadUint64(&q.dropped)
+}
+
+// Corrupted returns the number of corrupted records met by the consumer, the
+// rest of their segment being skipped
+func (q *Queue) Corrupted() uint64 {
+	return atomic.LoadUint64(&q.corrupted)
+}
+
+func (q *Queue) closeFiles() {
+	if q.writer != nil {
+		q.writer.Close()
+	}
+	for _, s := range q.segments {
+		if s.file != nil {
+			s.file.Close()
+		}
+	}
+}
+
+// Close checkpoints the committed records and closes the queue, Next then
+// returning ErrClosed
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case <-q.closed:
+		return nil
+	default:
+	}
+	close(q.closed)
+	err := q.writeCheckpoint()
+	q.closeFiles()
+	return err
+}
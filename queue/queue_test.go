@@ -0,0 +1,196 @@
+package queue
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type QueueSuite struct{}
+
+var _ = Suite(&QueueSuite{})
+
+func open(c *C, dir string, maxBytes int64) *Queue {
+	q, err := Open(dir, maxBytes)
+	c.Assert(err, IsNil)
+	q.SetSegmentSize(100)
+	return q
+}
+
+func appendRecords(c *C, q *Queue, from int, to int) {
+	for i := from; i < to; i++ {
+		c.Assert(q.Append([]byte(fmt.Sprintf("record %02d", i))), IsNil)
+	}
+}
+
+// Reads n records, committing them if commit
+func readRecords(c *C, q *Queue, n int, commit bool) []string {
+	var records []string
+	for i := 0; i < n; i++ {
+		record, err := q.Next(nil)
+		c.Assert(err, IsNil)
+		records = append(records, string(record))
+		if commit {
+			c.Assert(q.Commit(), IsNil)
+		}
+	}
+	return records
+}
+
+func segments(c *C, dir string) []string {
+	names, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	c.Assert(err, IsNil)
+	sort.Strings(names)
+	return names
+}
+
+func (s *QueueSuite) TestAppendNext(c *C) {
+	dir := c.MkDir()
+	q := open(c, dir, 0)
+	appendRecords(c, q, 0, 20)
+	// 17 bytes per record, 5 per segment
+	c.Check(segments(c, dir), HasLen, 4)
+	c.Check(q.Size(), Equals, int64(20*17))
+
+	c.Check(readRecords(c, q, 12, true)[11], Equals, "record 11")
+	c.Check(readRecords(c, q, 3, false), DeepEquals, []string{"record 12", "record 13", "record 14"})
+	c.Assert(q.Close(), IsNil)
+	// Deleted once checkpointed
+	c.Check(segments(c, dir), HasLen, 2)
+
+	_, err := q.Next(nil)
+	c.Check(err, Equals, ErrClosed)
+	c.Check(q.Append([]byte("late")), Equals, ErrClosed)
+
+	// The records not committed are read again
+	q = open(c, dir, 0)
+	c.Check(readRecords(c, q, 8, true), DeepEquals, []string{
+		"record 12", "record 13", "record 14", "record 15", "record 16", "record 17", "record 18", "record 19",
+	})
+	c.Assert(q.Close(), IsNil)
+}
+
+func (s *QueueSuite) TestNextWaits(c *C) {
+	q := open(c, c.MkDir(), 0)
+	defer q.Close()
+
+	cancel := make(chan struct{})
+	close(cancel)
+	_, err := q.Next(cancel)
+	c.Check(err, Equals, ErrCanceled)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Append([]byte("late"))
+	}()
+	record, err := q.Next(nil)
+	c.Assert(err, IsNil)
+	c.Check(string(record), Equals, "late")
+}
+
+func (s *QueueSuite) TestCrashRecovery(c *C) {
+	dir := c.MkDir()
+	q := open(c, dir, 0)
+	q.SetCheckpointEvery(4)
+	appendRecords(c, q, 0, 12)
+	readRecords(c, q, 6, true)
+	// Crash: no Close, and a record torn while being written
+	names := segments(c, dir)
+	f, err := os.OpenFile(names[len(names)-1], os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte{0, 0, 0, 9, 1, 2, 3, 4, 'r', 'e'})
+	c.Assert(err, IsNil)
+	f.Close()
+
+	q = open(c, dir, 0)
+	// The records after the last checkpoint, at 4, are read again
+	c.Check(readRecords(c, q, 8, true), DeepEquals, []string{
+		"record 04", "record 05", "record 06", "record 07", "record 08", "record 09", "record 10", "record 11",
+	})
+	appendRecords(c, q, 12, 13)
+	c.Check(readRecords(c, q, 1, true), DeepEquals, []string{"record 12"})
+	c.Check(q.Corrupted(), Equals, uint64(0))
+	c.Assert(q.Close(), IsNil)
+}
+
+func (s *QueueSuite) TestCorruptedRecord(c *C) {
+	dir := c.MkDir()
+	q := open(c, dir, 0)
+	appendRecords(c, q, 0, 12)
+	c.Assert(q.Close(), IsNil)
+
+	// Corrupt the second record of the first segment
+	names := segments(c, dir)
+	b, err := ioutil.ReadFile(names[0])
+	c.Assert(err, IsNil)
+	b[17+recordHeaderSize] ^= 0xff
+	c.Assert(ioutil.WriteFile(names[0], b, 0644), IsNil)
+
+	q = open(c, dir, 0)
+	c.Check(readRecords(c, q, 8, true), DeepEquals, []string{
+		"record 00", "record 05", "record 06", "record 07", "record 08", "record 09", "record 10", "record 11",
+	})
+	c.Check(q.Corrupted(), Equals, uint64(1))
+	c.Assert(q.Close(), IsNil)
+
+	// Corrupted checkpoint
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, checkpointName), []byte("garbage"), 0644), IsNil)
+	_, err = Open(dir, 0)
+	c.Check(err, ErrorMatches, "corrupted checkpoint in .*")
+}
+
+func (s *QueueSuite) TestDiskBudget(c *C) {
+	q := open(c, c.MkDir(), 10*17)
+	defer q.Close()
+	appendRecords(c, q, 0, 10)
+	c.Check(q.Append([]byte("record 10")), Equals, ErrFull)
+	c.Check(q.Dropped(), Equals, uint64(1))
+
+	// Consuming frees the segments
+	readRecords(c, q, 7, true)
+	appendRecords(c, q, 10, 15)
+	c.Check(q.Append([]byte("record 15")), Equals, ErrFull)
+	c.Check(readRecords(c, q, 8, true)[7], Equals, "record 14")
+
+	c.Check(q.Append(make([]byte, RecordSizeMax+1)), Equals, ErrTooLarge)
+}
+
+func (s *QueueSuite) TestDiskBudgetBelowSegmentSize(c *C) {
+	dir := c.MkDir()
+	q, err := Open(dir, 1000)
+	c.Assert(err, IsNil)
+	record := make([]byte, 100-recordHeaderSize)
+	appendFull := func(n int) {
+		for i := 0; i < n; i++ {
+			c.Assert(q.Append(record), IsNil)
+		}
+		c.Check(q.Append(record), Equals, ErrFull)
+		c.Check(q.Size(), Equals, int64(1000))
+	}
+	appendFull(10)
+	// Segments of half the budget
+	c.Check(segments(c, dir), HasLen, 2)
+
+	// Consuming the records frees their segments, the one written included
+	readRecords(c, q, 5, true)
+	appendFull(5)
+	readRecords(c, q, 10, true)
+	appendFull(10)
+	c.Check(segments(c, dir), HasLen, 2)
+	readRecords(c, q, 10, true)
+	c.Assert(q.Close(), IsNil)
+
+	// Also after a restart
+	q, err = Open(dir, 1000)
+	c.Assert(err, IsNil)
+	defer q.Close()
+	appendFull(10)
+}
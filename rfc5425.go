@@ -0,0 +1,171 @@
+package syslog
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+	"strings"
+)
+
+const (
+	rfc5425MaxMessageSizeDefault = 65536
+	rfc5425MaxMessageSizeMin     = 2048
+)
+
+var errCertificateNotPinned = errors.New("certificate fingerprint not pinned")
+
+// RFC5425Options are the options of an RFC5425 TLS listener
+type RFC5425Options struct {
+	// The size of the largest message read, a connection sending a longer one
+	// being closed. Defaults to 65536, at least 2048 as required by RFC5425
+	MaxMessageSize int
+	// The fingerprints of the certificates the clients must present, as
+	// "sha-256:" followed by the hexadecimal digest, its bytes optionally
+	// separated by colons (sha-1, sha-384 and sha-512 as well). When set, the
+	// certificates are matched against them instead of being validated with
+	// the client CAs of the configuration (RFC5425 section 5.2). Otherwise,
+	// when the configuration has client CAs, the clients must present a
+	// certificate they validate
+	Fingerprints []string
+}
+
+// The RFC5425 listener options with their defaults
+func (o RFC5425Options) withDefaults() RFC5425Options {
+	if o.MaxMessageSize == 0 {
+		o.MaxMessageSize = rfc5425MaxMessageSizeDefault
+	} else if o.MaxMessageSize < rfc5425MaxMessageSizeMin {
+		o.MaxMessageSize = rfc5425MaxMessageSizeMin
+	}
+	return o
+}
+
+// An RFC5425 TLS listener
+type rfc5425Listener struct {
+	net.Listener
+	maxMessageSize int
+}
+
+// A connection of an RFC5425 listener
+type rfc5425Conn struct {
+	*tls.Conn
+	maxMessageSize int
+}
+
+func (l *rfc5425Listener) Accept() (net.Conn, error) {
+	connection, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &rfc5425Conn{connection.(*tls.Conn), l.maxMessageSize}, nil
+}
+
+// Configure the server for listen on a TCP addr for TLS as RFC5425 requires:
+// with TLS 1.2 at least, and messages framed with octet counting only, the
+// connections sending any other framing or a message larger than the maximum
+// size being closed. The connections are closed with a TLS close_notify, sent
+// back when the client closes, and a message cut short by the end of a
+// connection is discarded.
+//
+// The messages get the "tls_version" and "tls_cipher_suite" fields, set to the
+// names of the TLS version and cipher suite negotiated
+func (s *Server) ListenRFC5425(addr string, config *tls.Config, options RFC5425Options) error {
+	options = options.withDefaults()
+	config = config.Clone()
+	if config.MinVersion < tls.VersionTLS12 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	if len(options.Fingerprints) > 0 {
+		pins, err := parseFingerprints(options.Fingerprints)
+		if err != nil {
+			return err
+		}
+		// Without CAs requested, so that the clients send self-signed certificates
+		config.ClientAuth = tls.RequireAnyClientCert
+		config.ClientCAs = nil
+		config.VerifyPeerCertificate = pins.verify
+	} else if config.ClientCAs != nil && config.ClientAuth < tls.VerifyClientCertIfGiven {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	listener, err := tls.Listen("tcp", addr, config)
+	if err != nil {
+		return err
+	}
+
+	s.done = make(chan struct{})
+	s.listeners = append(s.listeners, &rfc5425Listener{listener, options.MaxMessageSize})
+	return nil
+}
+
+// A certificate fingerprint
+type fingerprint struct {
+	hash   func() hash.Hash
+	digest []byte
+}
+
+type fingerprints []fingerprint
+
+var fingerprintHashes = map[string]func() hash.Hash{
+	"sha-1":   sha1.New,
+	"sha-256": sha256.New,
+	"sha-384": sha512.New384,
+	"sha-512": sha512.New,
+}
+
+func parseFingerprints(values []string) (fingerprints, error) {
+	pins := make(fingerprints, 0, len(values))
+	for _, value := range values {
+		i := strings.IndexByte(value, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid fingerprint %q", value)
+		}
+		newHash, ok := fingerprintHashes[strings.ToLower(value[:i])]
+		if !ok {
+			return nil, fmt.Errorf("unsupported fingerprint hash %q", value[:i])
+		}
+		digest, err := hex.DecodeString(strings.Replace(value[i+1:], ":", "", -1))
+		if err != nil || len(digest) != newHash().Size() {
+			return nil, fmt.Errorf("invalid fingerprint %q", value)
+		}
+		pins = append(pins, fingerprint{newHash, digest})
+	}
+	return pins, nil
+}
+
+// Matches the certificate of the client against the fingerprints
+func (pins fingerprints) verify(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errCertificateNotPinned
+	}
+	for _, pin := range pins {
+		h := pin.hash()
+		h.Write(rawCerts[0])
+		if bytes.Equal(h.Sum(nil), pin.digest) {
+			return nil
+		}
+	}
+	return errCertificateNotPinned
+}
+
+// Returns the name of a TLS version
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("0x%04X", version)
+}
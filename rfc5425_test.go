@@ -0,0 +1,202 @@
+package syslog
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type RFC5425Suite struct {
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	server tls.Certificate
+	client tls.Certificate
+}
+
+var _ = Suite(&RFC5425Suite{})
+
+// Creates a certificate for name, signed by the CA, or self-signed without CA
+func (s *RFC5425Suite) certificate(c *C, name string, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if ca == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		ca, caKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	c.Assert(err, IsNil)
+	cert, err := x509.ParseCertificate(der)
+	c.Assert(err, IsNil)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, cert
+}
+
+func (s *RFC5425Suite) SetUpSuite(c *C) {
+	var ca tls.Certificate
+	ca, s.ca = s.certificate(c, "ca", nil, nil)
+	s.caKey = ca.PrivateKey.(*ecdsa.PrivateKey)
+	s.server, _ = s.certificate(c, "server", s.ca, s.caKey)
+	s.client, _ = s.certificate(c, "client", s.ca, s.caKey)
+}
+
+func (s *RFC5425Suite) listen(c *C, options RFC5425Options) (*Server, channelHandler) {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.ca)
+	handler := make(channelHandler, 10)
+	server := NewServer()
+	server.SetFormat(RFC5424)
+	server.SetHandler(handler)
+	// Client certificates required with the client CAs
+	c.Assert(server.ListenRFC5425("127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{s.server},
+		ClientCAs:    pool,
+	}, options), IsNil)
+	c.Assert(server.Boot(), IsNil)
+	return server, handler
+}
+
+func (s *RFC5425Suite) dial(c *C, server *Server, cert tls.Certificate) (*tls.Conn, error) {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.ca)
+	return tls.Dial("tcp", server.listeners[0].Addr().String(), &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ServerName:   "server",
+	})
+}
+
+// Checks that the server closes the connection without handling any message
+func (s *RFC5425Suite) checkRejected(c *C, conn *tls.Conn, handler channelHandler) {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err := ioutil.ReadAll(conn)
+	c.Check(err, Not(FitsTypeOf), net.Error(nil))
+	select {
+	case logParts := <-handler:
+		c.Errorf("unexpected message %v", logParts)
+	default:
+	}
+}
+
+func (s *RFC5425Suite) TestOctetCounting(c *C) {
+	server, handler := s.listen(c, RFC5425Options{})
+	defer server.Kill()
+
+	conn, err := s.dial(c, server, s.client)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	_, err = fmt.Fprint(conn, "24 <13>1 - host app - - - a26 <13>1 - host app - - - b\nc")
+	c.Assert(err, IsNil)
+
+	logParts := handler.receive(c)
+	c.Check(logParts["message"], Equals, "a")
+	c.Check(logParts["tls_peer"], Equals, "client")
+	c.Check(logParts["tls_version"], Equals, "TLS 1.3")
+	c.Check(logParts["tls_cipher_suite"], Matches, "TLS_.+")
+	c.Check(handler.receive(c)["message"], Equals, "b\nc")
+
+	// Answers the close_notify of the client with its own
+	c.Assert(conn.CloseWrite(), IsNil)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = ioutil.ReadAll(conn)
+	c.Check(err, IsNil)
+}
+
+func (s *RFC5425Suite) TestFramingRejected(c *C) {
+	server, handler := s.listen(c, RFC5425Options{MaxMessageSize: 100})
+	defer server.Kill()
+
+	for _, data := range []string{
+		"<13>1 - host app - - - a\n",
+		// At least 2048 bytes are read
+		"2049 <13>1 - host app - - - " + strings.Repeat("x", 2049-23),
+		// Cut short by the end of the connection
+		"30 <13>1 - host app - - - a",
+	} {
+		conn, err := s.dial(c, server, s.client)
+		c.Assert(err, IsNil)
+		_, err = fmt.Fprint(conn, data)
+		c.Assert(err, IsNil)
+		c.Assert(conn.CloseWrite(), IsNil)
+		s.checkRejected(c, conn, handler)
+		conn.Close()
+	}
+
+	conn, err := s.dial(c, server, s.client)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	_, err = fmt.Fprint(conn, "2048 <13>1 - host app - - - "+strings.Repeat("x", 2048-23))
+	c.Assert(err, IsNil)
+	c.Check(handler.receive(c)["message"], HasLen, 2048-23)
+}
+
+func (s *RFC5425Suite) TestClientCAs(c *C) {
+	server, handler := s.listen(c, RFC5425Options{})
+	defer server.Kill()
+
+	other, _ := s.certificate(c, "other", nil, nil)
+	for _, cert := range []tls.Certificate{{}, other} {
+		conn, err := s.dial(c, server, cert)
+		if err == nil {
+			fmt.Fprint(conn, "24 <13>1 - host app - - - a")
+			s.checkRejected(c, conn, handler)
+			conn.Close()
+		}
+	}
+}
+
+func (s *RFC5425Suite) TestFingerprints(c *C) {
+	pinned, cert := s.certificate(c, "pinned", nil, nil)
+	other, _ := s.certificate(c, "other", nil, nil)
+	digest := sha256.Sum256(cert.Raw)
+	hexDigest := strings.ToUpper(fmt.Sprintf("% x", digest[:]))
+	server, handler := s.listen(c, RFC5425Options{Fingerprints: []string{
+		"SHA-256:" + strings.Replace(hexDigest, " ", ":", -1),
+	}})
+	defer server.Kill()
+
+	conn, err := s.dial(c, server, pinned)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	_, err = fmt.Fprint(conn, "24 <13>1 - host app - - - a")
+	c.Assert(err, IsNil)
+	c.Check(handler.receive(c)["tls_peer"], Equals, "pinned")
+
+	// Neither another self-signed certificate, nor one signed by the CA
+	for _, cert := range []tls.Certificate{other, s.client} {
+		conn, err := s.dial(c, server, cert)
+		if err == nil {
+			// TLS 1.3 clients learn of the failure once reading
+			fmt.Fprint(conn, "24 <13>1 - host app - - - b")
+			s.checkRejected(c, conn, handler)
+			conn.Close()
+		}
+	}
+
+	for _, fingerprint := range []string{"sha-256:ab:cd", "md5:" + hexDigest[:47], "sha-256"} {
+		c.Check(NewServer().ListenRFC5425("127.0.0.1:0", &tls.Config{}, RFC5425Options{
+			Fingerprints: []string{fingerprint},
+		}), NotNil)
+	}
+}
@@ -0,0 +1,117 @@
+package rules
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// Reloader is a handler routing the messages following the rules of a file,
+// which it can reload on change without interrupting the traffic. When a new
+// version of the file is invalid, the previous rules stay in use
+type Reloader struct {
+	filename string
+	handlers map[string]syslog.Handler
+	ruleset  atomic.Value // *Ruleset
+	mu       sync.Mutex   // serializes the reloads
+	modTime  time.Time
+	size     int64
+	done     chan struct{}
+	doneOnce sync.Once
+	wait     sync.WaitGroup
+}
+
+// NewReloader returns a new Reloader, loading the rules file a first time
+func NewReloader(filename string, handlers map[string]syslog.Handler) (*Reloader, error) {
+	r := &Reloader{
+		filename: filename,
+		handlers: handlers,
+		done:     make(chan struct{}),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the rules file again, and switches to its rules if valid
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, err := os.Stat(r.filename)
+	if err != nil {
+		return err
+	}
+
+	ruleset, err := Load(r.filename, r.handlers)
+	if err != nil {
+		// Do not try again until the file changes
+		r.modTime, r.size = info.ModTime(), info.Size()
+		return err
+	}
+
+	r.ruleset.Store(ruleset)
+	r.modTime, r.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// Reloads the rules file if its modification time or size changed
+func (r *Reloader) reloadIfChanged() error {
+	info, err := os.Stat(r.filename)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	changed := !info.ModTime().Equal(r.modTime) || info.Size() != r.size
+	r.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+	return r.Reload()
+}
+
+// Watch checks the rules file for changes every interval, and reloads it when
+// it changed. The reload errors are reported to onError if not nil. It stops
+// on Close
+func (r *Reloader) Watch(interval time.Duration, onError func(error)) {
+	r.wait.Add(1)
+	go func() {
+		defer r.wait.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-ticker.C:
+				if err := r.reloadIfChanged(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
+
+// Ruleset returns the rules in use
+func (r *Reloader) Ruleset() *Ruleset {
+	return r.ruleset.Load().(*Ruleset)
+}
+
+func (r *Reloader) Handle(logParts format.LogParts, messageLength int64, err error) {
+	r.Ruleset().Handle(logParts, messageLength, err)
+}
+
+// Close stops watching the rules file
+func (r *Reloader) Close() error {
+	r.doneOnce.Do(func() { close(r.done) })
+	r.wait.Wait()
+	return nil
+}
@@ -0,0 +1,87 @@
+package rules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+func (s *RulesSuite) writeRules(c *C, filename string, config string, modTime time.Time) {
+	c.Assert(ioutil.WriteFile(filename, []byte(config), 0644), IsNil)
+	c.Assert(os.Chtimes(filename, modTime, modTime), IsNil)
+}
+
+func (s *RulesSuite) TestReloader(c *C) {
+	filename := filepath.Join(c.MkDir(), "rules.yml")
+	now := time.Now()
+	s.writeRules(c, filename, "default: archive", now)
+
+	r, err := NewReloader(filename, s.handlers)
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	r.Handle(format.LogParts{}, 0, nil)
+	c.Check(s.archive.logParts, HasLen, 1)
+
+	// Invalid rules are reported, the previous ones stay in use
+	s.writeRules(c, filename, "default: nowhere", now.Add(time.Second))
+	errors := make(chan error, 10)
+	r.Watch(10*time.Millisecond, func(err error) {
+		errors <- err
+	})
+	select {
+	case err := <-errors:
+		c.Check(err, ErrorMatches, `.*unknown handler "nowhere"`)
+	case <-time.After(5 * time.Second):
+		c.Fatal("no reload error")
+	}
+	r.Handle(format.LogParts{}, 0, nil)
+	c.Check(s.archive.logParts, HasLen, 2)
+
+	// Valid rules are switched to
+	s.writeRules(c, filename, "default: pager", now.Add(2*time.Second))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.pager.logParts) == 0 && time.Now().Before(deadline) {
+		r.Handle(format.LogParts{}, 0, nil)
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Check(s.pager.logParts, Not(HasLen), 0)
+	c.Check(errors, HasLen, 0)
+
+	c.Assert(r.Close(), IsNil)
+	c.Assert(r.Close(), IsNil)
+}
+
+func (s *RulesSuite) TestReloaderCloseConcurrently(c *C) {
+	filename := filepath.Join(c.MkDir(), "rules.yml")
+	s.writeRules(c, filename, "default: archive", time.Now())
+	r, err := NewReloader(filename, s.handlers)
+	c.Assert(err, IsNil)
+	r.Watch(time.Millisecond, nil)
+
+	var wait sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			r.Close()
+		}()
+	}
+	wait.Wait()
+}
+
+func (s *RulesSuite) TestReloaderInvalid(c *C) {
+	filename := filepath.Join(c.MkDir(), "rules.json")
+
+	_, err := NewReloader(filename, s.handlers)
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	s.writeRules(c, filename, `{"default": "nowhere"}`, time.Now())
+	_, err = NewReloader(filename, s.handlers)
+	c.Check(err, ErrorMatches, `.*rules.json: default: unknown handler "nowhere"`)
+}
@@ -0,0 +1,417 @@
+// Package rules routes syslog messages to named handlers following
+// declarative rules, loaded from a YAML or JSON file:
+//
+//	default: archive
+//	rules:
+//	  - name: security
+//	    match:
+//	      facility: [auth, authpriv]
+//	    actions:
+//	      - route: security
+//	      - stop: true
+//	  - name: pager
+//	    match:
+//	      severity: "<= err"
+//	      hostname: ["core-*"]
+//	    actions:
+//	      - set: {priority_class: page}
+//	      - route: pager
+//
+// The rules are evaluated in order, and the actions of every matching rule are
+// applied in order, until a drop or stop action. A message that was not routed
+// by any rule, and not dropped, goes to the default handler if any
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/internal/syslogparser/rfc5424"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/middleware"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the content of a rules file
+type Config struct {
+	// Name of the handler receiving the messages no rule routed
+	Default string `json:"default" yaml:"default"`
+	Rules   []Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule applies its actions to the messages matching all its conditions
+type Rule struct {
+	Name    string   `json:"name" yaml:"name"`
+	Match   Match    `json:"match" yaml:"match"`
+	Actions []Action `json:"actions" yaml:"actions"`
+}
+
+// Match holds the conditions of a rule, all of which must hold. An empty Match
+// matches every message
+type Match struct {
+	// Facility names or numbers, any of which matches
+	Facility []string `json:"facility" yaml:"facility"`
+	// Severity name or number, optionally preceded by a comparison operator
+	// among <=, <, >=, > and =. As in syslog, lower is more severe, so
+	// "<= err" matches emerg to err
+	Severity string `json:"severity" yaml:"severity"`
+	// Glob patterns over the hostname, any of which matches
+	Hostname []string `json:"hostname" yaml:"hostname"`
+	// Glob patterns over the RFC5424 app name or RFC3164 tag, any of which
+	// matches
+	AppName []string `json:"app_name" yaml:"app_name"`
+	// Regular expression over the RFC5424 message or RFC3164 content
+	Message string `json:"message" yaml:"message"`
+	// Regular expressions over RFC5424 structured data parameters, by SD-ID
+	// then by parameter name
+	SD map[string]map[string]string `json:"sd" yaml:"sd"`
+	// Regular expressions over any string field
+	Fields map[string]string `json:"fields" yaml:"fields"`
+}
+
+// Action is one of drop, route, set or stop, exactly one field must be given
+type Action struct {
+	// Discards the message, and stops the evaluation of the rules
+	Drop bool `json:"drop" yaml:"drop"`
+	// Name of the handler the message is delivered to
+	Route string `json:"route" yaml:"route"`
+	// Fields set on the message
+	Set map[string]string `json:"set" yaml:"set"`
+	// Stops the evaluation of the rules
+	Stop bool `json:"stop" yaml:"stop"`
+}
+
+type actionKind int
+
+const (
+	actionDrop actionKind = iota
+	actionRoute
+	actionSet
+	actionStop
+)
+
+type action struct {
+	kind    actionKind
+	handler syslog.Handler
+	set     map[string]string
+}
+
+type rule struct {
+	match   middleware.FilterFunc
+	sd      []sdMatch
+	actions []action
+}
+
+// Matches the messages whose structured data has the parameter of an element
+// matching re
+type sdMatch struct {
+	id   string
+	name string
+	re   *regexp.Regexp
+}
+
+// The structured data of a message, parsed once for all the rules
+type structuredData struct {
+	parsed   bool
+	elements map[string]map[string]string // nil if invalid
+}
+
+func (sd *structuredData) get(logParts format.LogParts) map[string]map[string]string {
+	if !sd.parsed {
+		s, _ := logParts["structured_data"].(string)
+		sd.elements, _ = rfc5424.ParseStructuredDataElements(s)
+		sd.parsed = true
+	}
+	return sd.elements
+}
+
+func (r *rule) matches(logParts format.LogParts, sd *structuredData) bool {
+	if !r.match(logParts) {
+		return false
+	}
+	for _, m := range r.sd {
+		value, ok := sd.get(logParts)[m.id][m.name]
+		if !ok || !m.re.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Ruleset is a compiled rules configuration, it is a handler routing the
+// messages following the rules
+type Ruleset struct {
+	rules          []rule
+	defaultHandler syslog.Handler
+}
+
+// Load reads and compiles a rules file
+func Load(filename string, handlers map[string]syslog.Handler) (*Ruleset, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := Parse(data, handlers)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	return r, nil
+}
+
+// Parse decodes and compiles a rules configuration, in JSON if it starts with
+// '{' or else in YAML. Unknown keys are reported as errors
+func Parse(data []byte, handlers map[string]syslog.Handler) (*Ruleset, error) {
+	config := new(Config)
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return nil, err
+		}
+	} else if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, err
+	}
+
+	return Compile(config, handlers)
+}
+
+// Compile validates a rules configuration, and resolves its handler names
+func Compile(config *Config, handlers map[string]syslog.Handler) (*Ruleset, error) {
+	r := &Ruleset{}
+
+	if config.Default != "" {
+		h, ok := handlers[config.Default]
+		if !ok {
+			return nil, fmt.Errorf("default: unknown handler %q", config.Default)
+		}
+		r.defaultHandler = h
+	}
+
+	for i, c := range config.Rules {
+		compiled, err := compileRule(c, handlers)
+		if err != nil {
+			name := c.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("rule %s: %v", name, err)
+		}
+		r.rules = append(r.rules, compiled)
+	}
+
+	return r, nil
+}
+
+func compileRule(c Rule, handlers map[string]syslog.Handler) (rule, error) {
+	var r rule
+
+	match, sd, err := compileMatch(c.Match)
+	if err != nil {
+		return r, err
+	}
+	r.match, r.sd = match, sd
+
+	if len(c.Actions) == 0 {
+		return r, fmt.Errorf("no action")
+	}
+
+	for i, a := range c.Actions {
+		compiled, err := compileAction(a, handlers)
+		if err != nil {
+			return r, fmt.Errorf("action #%d: %v", i+1, err)
+		}
+		r.actions = append(r.actions, compiled)
+	}
+
+	return r, nil
+}
+
+func compileAction(a Action, handlers map[string]syslog.Handler) (action, error) {
+	var compiled action
+	n := 0
+
+	if a.Drop {
+		compiled.kind = actionDrop
+		n++
+	}
+	if a.Route != "" {
+		h, ok := handlers[a.Route]
+		if !ok {
+			return compiled, fmt.Errorf("unknown handler %q", a.Route)
+		}
+		compiled.kind = actionRoute
+		compiled.handler = h
+		n++
+	}
+	if len(a.Set) > 0 {
+		compiled.kind = actionSet
+		compiled.set = a.Set
+		n++
+	}
+	if a.Stop {
+		compiled.kind = actionStop
+		n++
+	}
+
+	if n != 1 {
+		return compiled, fmt.Errorf("exactly one of drop, route, set or stop expected")
+	}
+
+	return compiled, nil
+}
+
+func compileMatch(m Match) (middleware.FilterFunc, []sdMatch, error) {
+	var filters []middleware.FilterFunc
+	var sd []sdMatch
+
+	if len(m.Facility) > 0 {
+		facilities := make([]syslog.Facility, 0, len(m.Facility))
+		for _, name := range m.Facility {
+			f, err := syslog.ParseFacility(name)
+			if err != nil {
+				return nil, nil, err
+			}
+			facilities = append(facilities, f)
+		}
+		filters = append(filters, middleware.Facility(facilities...))
+	}
+
+	if m.Severity != "" {
+		f, err := compileSeverity(m.Severity)
+		if err != nil {
+			return nil, nil, err
+		}
+		filters = append(filters, f)
+	}
+
+	if len(m.Hostname) > 0 {
+		if err := checkGlobs(m.Hostname); err != nil {
+			return nil, nil, err
+		}
+		filters = append(filters, middleware.Hostname(m.Hostname...))
+	}
+
+	if len(m.AppName) > 0 {
+		if err := checkGlobs(m.AppName); err != nil {
+			return nil, nil, err
+		}
+		filters = append(filters, middleware.Tag(m.AppName...))
+	}
+
+	if m.Message != "" {
+		re, err := regexp.Compile(m.Message)
+		if err != nil {
+			return nil, nil, fmt.Errorf("message: %v", err)
+		}
+		filters = append(filters, middleware.Message(re))
+	}
+
+	for id, params := range m.SD {
+		for name, expr := range params {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, nil, fmt.Errorf("sd %s %s: %v", id, name, err)
+			}
+			sd = append(sd, sdMatch{id, name, re})
+		}
+	}
+
+	for key, expr := range m.Fields {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("field %s: %v", key, err)
+		}
+		filters = append(filters, middleware.Field(key, re))
+	}
+
+	return middleware.And(filters...), sd, nil
+}
+
+func compileSeverity(expr string) (middleware.FilterFunc, error) {
+	expr = strings.TrimSpace(expr)
+	op := "="
+	for _, o := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(expr, o) {
+			op = o
+			expr = expr[len(o):]
+			break
+		}
+	}
+
+	severity, err := syslog.ParseSeverity(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(logParts format.LogParts) bool {
+		n, ok := logParts["severity"].(int)
+		if !ok {
+			return false
+		}
+		s := syslog.Severity(n)
+		switch op {
+		case "<=":
+			return s <= severity
+		case ">=":
+			return s >= severity
+		case "<":
+			return s < severity
+		case ">":
+			return s > severity
+		}
+		return s == severity
+	}, nil
+}
+
+func checkGlobs(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+func (r *Ruleset) Handle(logParts format.LogParts, messageLength int64, err error) {
+	routed := false
+	var sd structuredData
+
+rules:
+	for i := range r.rules {
+		rule := &r.rules[i]
+		if !rule.matches(logParts, &sd) {
+			continue
+		}
+		for _, a := range rule.actions {
+			switch a.kind {
+			case actionDrop:
+				return
+			case actionRoute:
+				// Later actions must not modify what this handler got
+				a.handler.Handle(middleware.CopyLogParts(logParts), messageLength, err)
+				routed = true
+			case actionSet:
+				for k, v := range a.set {
+					logParts[k] = v
+				}
+				if _, ok := a.set["structured_data"]; ok {
+					sd = structuredData{}
+				}
+			case actionStop:
+				break rules
+			}
+		}
+	}
+
+	if !routed && r.defaultHandler != nil {
+		r.defaultHandler.Handle(logParts, messageLength, err)
+	}
+}
@@ -0,0 +1,209 @@
+package rules
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type RulesSuite struct {
+	security *handlerRecorder
+	pager    *handlerRecorder
+	archive  *handlerRecorder
+	handlers map[string]syslog.Handler
+}
+
+var _ = Suite(&RulesSuite{})
+
+type handlerRecorder struct {
+	logParts []format.LogParts
+}
+
+func (h *handlerRecorder) Handle(logParts format.LogParts, messageLength int64, err error) {
+	h.logParts = append(h.logParts, logParts)
+}
+
+func (s *RulesSuite) SetUpTest(c *C) {
+	s.security = new(handlerRecorder)
+	s.pager = new(handlerRecorder)
+	s.archive = new(handlerRecorder)
+	s.handlers = map[string]syslog.Handler{
+		"security": s.security,
+		"pager":    s.pager,
+		"archive":  s.archive,
+	}
+}
+
+const rulesYAML = `
+default: archive
+rules:
+  - name: security
+    match:
+      facility: [auth, authpriv]
+    actions:
+      - route: security
+      - stop: true
+  - name: healthchecks
+    match:
+      app_name: [haproxy]
+      message: "GET /health"
+    actions:
+      - drop: true
+  - name: pager
+    match:
+      severity: "<= err"
+      hostname: ["core-*"]
+    actions:
+      - set: {priority_class: page}
+      - route: pager
+  - name: internal
+    match:
+      sd:
+        origin:
+          ip: "^10\\."
+    actions:
+      - set: {zone: internal}
+`
+
+const rulesJSON = `{
+	"default": "archive",
+	"rules": [
+		{
+			"name": "security",
+			"match": {"facility": ["auth", "authpriv"]},
+			"actions": [{"route": "security"}, {"stop": true}]
+		},
+		{
+			"name": "healthchecks",
+			"match": {"app_name": ["haproxy"], "message": "GET /health"},
+			"actions": [{"drop": true}]
+		},
+		{
+			"name": "pager",
+			"match": {"severity": "<= err", "hostname": ["core-*"]},
+			"actions": [{"set": {"priority_class": "page"}}, {"route": "pager"}]
+		},
+		{
+			"name": "internal",
+			"match": {"sd": {"origin": {"ip": "^10\\."}}},
+			"actions": [{"set": {"zone": "internal"}}]
+		}
+	]
+}`
+
+func (s *RulesSuite) TestRoute(c *C) {
+	for _, config := range []string{rulesYAML, rulesJSON} {
+		s.SetUpTest(c)
+		ruleset, err := Parse([]byte(config), s.handlers)
+		c.Assert(err, IsNil)
+
+		// Security, stops before the pager rule
+		ruleset.Handle(format.LogParts{"facility": 10, "severity": 2, "hostname": "core-1"}, 0, nil)
+		// Pager
+		ruleset.Handle(format.LogParts{"facility": 1, "severity": 3, "hostname": "core-2"}, 0, nil)
+		// Dropped
+		ruleset.Handle(format.LogParts{"facility": 1, "severity": 6, "app_name": "haproxy", "message": "GET /health 200"}, 0, nil)
+		// Archived, with a field set
+		ruleset.Handle(format.LogParts{"facility": 1, "severity": 6, "hostname": "core-3", "structured_data": `[origin ip="10.1.2.3"]`}, 0, nil)
+
+		c.Assert(s.security.logParts, HasLen, 1)
+		c.Check(s.security.logParts[0]["hostname"], Equals, "core-1")
+		c.Assert(s.pager.logParts, HasLen, 1)
+		c.Check(s.pager.logParts[0]["hostname"], Equals, "core-2")
+		c.Check(s.pager.logParts[0]["priority_class"], Equals, "page")
+		c.Assert(s.archive.logParts, HasLen, 1)
+		c.Check(s.archive.logParts[0]["hostname"], Equals, "core-3")
+		c.Check(s.archive.logParts[0]["zone"], Equals, "internal")
+	}
+}
+
+func (s *RulesSuite) TestStructuredDataSet(c *C) {
+	ruleset, err := Parse([]byte(`
+default: archive
+rules:
+  - match: {sd: {origin: {ip: "^10\\."}}}
+    actions: [{set: {structured_data: '[origin ip="192.0.2.1"]'}}]
+  - match: {sd: {origin: {ip: "^192\\."}}}
+    actions: [{route: security}]
+`), s.handlers)
+	c.Assert(err, IsNil)
+
+	// Parsed again once set
+	ruleset.Handle(format.LogParts{"structured_data": `[origin ip="10.1.2.3"]`}, 0, nil)
+	ruleset.Handle(format.LogParts{"structured_data": `[origin ip="10.1.2.3"`}, 0, nil)
+	c.Assert(s.security.logParts, HasLen, 1)
+	c.Assert(s.archive.logParts, HasLen, 1)
+}
+
+func (s *RulesSuite) TestSeverity(c *C) {
+	fixtures := []struct {
+		expr    string
+		matches []int
+	}{
+		{"err", []int{3}},
+		{"= 3", []int{3}},
+		{"<= err", []int{0, 1, 2, 3}},
+		{"<err", []int{0, 1, 2}},
+		{">= notice", []int{5, 6, 7}},
+		{"> info", []int{7}},
+		{"WARN", []int{4}},
+	}
+
+	for _, f := range fixtures {
+		filter, err := compileSeverity(f.expr)
+		c.Assert(err, IsNil)
+		var matches []int
+		for severity := 0; severity < 8; severity++ {
+			if filter(format.LogParts{"severity": severity}) {
+				matches = append(matches, severity)
+			}
+		}
+		c.Check(matches, DeepEquals, f.matches, Commentf("%s", f.expr))
+	}
+}
+
+func (s *RulesSuite) TestValidation(c *C) {
+	fixtures := []struct {
+		config string
+		err    string
+	}{
+		{"default: nowhere", `default: unknown handler "nowhere"`},
+		{"rules: [{name: a, actions: [{route: nowhere}]}]", `rule a: action #1: unknown handler "nowhere"`},
+		{"rules: [{name: a}]", `rule a: no action`},
+		{"rules: [{actions: [{}]}]", `rule #1: action #1: exactly one of drop, route, set or stop expected`},
+		{"rules: [{actions: [{drop: true, stop: true}]}]", `rule #1: action #1: exactly one of drop, route, set or stop expected`},
+		{"rules: [{match: {facility: [nope]}, actions: [{drop: true}]}]", `rule #1: unknown facility "nope"`},
+		{"rules: [{match: {severity: '<= 9'}, actions: [{drop: true}]}]", `rule #1: unknown severity "9"`},
+		{"rules: [{match: {hostname: ['[a']}, actions: [{drop: true}]}]", `rule #1: invalid pattern "\[a"`},
+		{"rules: [{match: {message: '(a'}, actions: [{drop: true}]}]", `rule #1: message: error parsing regexp.*`},
+		{"rules: [{match: {sd: {origin: {ip: '(a'}}}, actions: [{drop: true}]}]", `rule #1: sd origin ip: error parsing regexp.*`},
+		{"rules: [{match: {fields: {tag: '(a'}}, actions: [{drop: true}]}]", `rule #1: field tag: error parsing regexp.*`},
+		{"rules: [{mach: {}, actions: [{drop: true}]}]", `(?s).*field mach not found.*`},
+		{`{"rules": [{"mach": {}}]}`, `json: unknown field "mach"`},
+	}
+
+	for _, f := range fixtures {
+		_, err := Parse([]byte(f.config), s.handlers)
+		c.Check(err, ErrorMatches, f.err, Commentf("%s", f.config))
+	}
+}
+
+func (s *RulesSuite) TestSetDoesNotLeakToRoutedCopies(c *C) {
+	ruleset, err := Parse([]byte(`
+rules:
+  - actions:
+    - route: archive
+    - set: {tag: modified}
+    - route: pager
+`), s.handlers)
+	c.Assert(err, IsNil)
+
+	ruleset.Handle(format.LogParts{"tag": "foo"}, 0, nil)
+
+	c.Check(s.archive.logParts[0]["tag"], Equals, "foo")
+	c.Check(s.pager.logParts[0]["tag"], Equals, "modified")
+}
@@ -0,0 +1,125 @@
+package syslog
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// ControlEscaping selects how the ASCII control characters of messages are
+// escaped, so that they can not inject lines into files or sequences into
+// terminals
+type ControlEscaping int
+
+const (
+	// Leave the control characters as received (default)
+	ControlRaw ControlEscaping = iota
+	// Escape them as # followed by their octal value, like rsyslog does, e.g.
+	// #012 for a new line
+	ControlOctal
+	// Escape them with a backslash like Go and C do, e.g. \n for a new line and
+	// \x1b for ESC, backslashes being doubled except in the structured data
+	ControlBackslash
+)
+
+// The fields holding the text of the messages
+var messageFields = map[string]bool{"message": true, "content": true}
+
+// Sets how the control characters of the text fields of messages are escaped,
+// defaults to ControlRaw. With keepNewlines, the new lines of the text of
+// multi-line messages, e.g. stack traces, are kept and the "multiline" field
+// is added to them, set to true
+func (s *Server) SetControlEscaping(escaping ControlEscaping, keepNewlines bool) {
+	s.controlEscaping = escaping
+	s.keepNewlines = keepNewlines
+}
+
+// Sets the maximum length in bytes of the text of messages, longer ones being
+// cut and the "truncated" field added to them, set to true. Unlimited if 0, the
+// default
+func (s *Server) SetMaxMessageLength(length int) {
+	s.maxMessageLength = length
+}
+
+// Returns whether messages go through the sanitization stage
+func (s *Server) sanitizing() bool {
+	return s.controlEscaping != ControlRaw || s.maxMessageLength > 0
+}
+
+// Truncates the text and escapes the control characters of a message
+func (s *Server) sanitize(logParts format.LogParts) {
+	if s.maxMessageLength > 0 {
+		for key := range messageFields {
+			if text, ok := logParts[key].(string); ok && len(text) > s.maxMessageLength {
+				logParts[key] = truncateText(text, s.maxMessageLength)
+				logParts["truncated"] = true
+			}
+		}
+	}
+	if s.controlEscaping == ControlRaw {
+		return
+	}
+
+	for key, value := range logParts {
+		text, ok := value.(string)
+		if !ok {
+			continue
+		}
+		keepNewlines := false
+		if s.keepNewlines && messageFields[key] && strings.IndexByte(text, '\n') >= 0 {
+			keepNewlines = true
+			logParts["multiline"] = true
+		}
+		// The structured data escapes its quotes with backslashes already
+		doubleBackslashes := key != "structured_data"
+		logParts[key] = escapeControl(text, s.controlEscaping, keepNewlines, doubleBackslashes)
+	}
+}
+
+// Cuts text to at most length bytes, without splitting a UTF-8 sequence
+func truncateText(text string, length int) string {
+	for cut := length; cut >= 0 && cut > length-utf8.UTFMax; cut-- {
+		if utf8.RuneStart(text[cut]) {
+			return text[:cut]
+		}
+	}
+	// Not UTF-8
+	return text[:length]
+}
+
+var backslashEscapes = map[byte]string{
+	'\a': `\a`, '\b': `\b`, '\f': `\f`, '\n': `\n`, '\r': `\r`, '\t': `\t`, '\v': `\v`, '\\': `\\`,
+}
+
+// Escapes the control characters of text, new lines excepted if keepNewlines.
+// With ControlBackslash, backslashes are doubled if doubleBackslashes
+func escapeControl(text string, escaping ControlEscaping, keepNewlines bool, doubleBackslashes bool) string {
+	var b strings.Builder
+	start := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c >= 0x20 && c != 0x7f && (c != '\\' || escaping != ControlBackslash || !doubleBackslashes) {
+			continue
+		}
+		if c == '\n' && keepNewlines {
+			continue
+		}
+		b.WriteString(text[start:i])
+		start = i + 1
+		switch {
+		case escaping == ControlOctal:
+			fmt.Fprintf(&b, "#%03o", c)
+		case backslashEscapes[c] != "":
+			b.WriteString(backslashEscapes[c])
+		default:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		}
+	}
+	if start == 0 {
+		return text
+	}
+	b.WriteString(text[start:])
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package syslog
+
+import (
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+type SanitizeSuite struct{}
+
+var _ = Suite(&SanitizeSuite{})
+
+func (s *SanitizeSuite) parse(c *C, server *Server, f format.Format, line string) format.LogParts {
+	handler := new(HandlerMock)
+	server.SetFormat(f)
+	server.SetHandler(handler)
+	server.parser([]byte(line), "10.0.0.1:514", "")
+	return handler.LastLogParts
+}
+
+const exampleRFC5424Multiline = "<165>1 2003-10-11T22:14:15.003Z host app - - - panic: boom\n\tat main.go:12\x1b[0m \\"
+
+func (s *SanitizeSuite) TestRaw(c *C) {
+	logParts := s.parse(c, NewServer(), RFC5424, exampleRFC5424Multiline)
+	c.Check(logParts["message"], Equals, "panic: boom\n\tat main.go:12\x1b[0m \\")
+	_, ok := logParts["multiline"]
+	c.Check(ok, Equals, false)
+}
+
+func (s *SanitizeSuite) TestOctal(c *C) {
+	server := NewServer()
+	server.SetControlEscaping(ControlOctal, false)
+	logParts := s.parse(c, server, RFC5424, exampleRFC5424Multiline)
+	c.Check(logParts["message"], Equals, "panic: boom#012#011at main.go:12#033[0m \\")
+	_, ok := logParts["multiline"]
+	c.Check(ok, Equals, false)
+
+	logParts = s.parse(c, server, RFC3164, "<13>May  1 20:51:40 host prog: a\rb\x7f")
+	c.Check(logParts["content"], Equals, "a#015b#177")
+}
+
+func (s *SanitizeSuite) TestBackslash(c *C) {
+	server := NewServer()
+	server.SetControlEscaping(ControlBackslash, false)
+	logParts := s.parse(c, server, RFC5424, exampleRFC5424Multiline)
+	c.Check(logParts["message"], Equals, `panic: boom\n\tat main.go:12\x1b[0m \\`)
+
+	// The escaping of the structured data is kept
+	logParts = s.parse(c, server, RFC5424, `<165>1 2003-10-11T22:14:15.003Z host app - - [meta quote="a\"b\\c"] text\`)
+	c.Check(logParts["structured_data"], Equals, `[meta quote="a\"b\\c"]`)
+	c.Check(logParts["message"], Equals, `text\\`)
+}
+
+func (s *SanitizeSuite) TestKeepNewlines(c *C) {
+	server := NewServer()
+	server.SetControlEscaping(ControlBackslash, true)
+	logParts := s.parse(c, server, RFC5424, exampleRFC5424Multiline)
+	c.Check(logParts["message"], Equals, "panic: boom\n\\tat main.go:12\\x1b[0m \\\\")
+	c.Check(logParts["multiline"], Equals, true)
+
+	logParts = s.parse(c, server, RFC5424, "<165>1 2003-10-11T22:14:15.003Z host app - - - single\tline")
+	c.Check(logParts["message"], Equals, `single\tline`)
+	_, ok := logParts["multiline"]
+	c.Check(ok, Equals, false)
+}
+
+func (s *SanitizeSuite) TestMaxMessageLength(c *C) {
+	server := NewServer()
+	server.SetMaxMessageLength(10)
+	logParts := s.parse(c, server, RFC3164, "<13>May  1 20:51:40 host prog: 0123456789abc")
+	c.Check(logParts["content"], Equals, "0123456789")
+	c.Check(logParts["truncated"], Equals, true)
+
+	logParts = s.parse(c, server, RFC3164, "<13>May  1 20:51:40 host prog: 0123456789")
+	c.Check(logParts["content"], Equals, "0123456789")
+	_, ok := logParts["truncated"]
+	c.Check(ok, Equals, false)
+
+	// Truncated before escaping, on a rune boundary
+	server.SetControlEscaping(ControlOctal, false)
+	logParts = s.parse(c, server, RFC5424, "<165>1 2003-10-11T22:14:15.003Z host app - - - 012345678é\n")
+	c.Check(logParts["message"], Equals, "012345678")
+	logParts = s.parse(c, server, RFC5424, "<165>1 2003-10-11T22:14:15.003Z host app - - - 01234567\n9é")
+	c.Check(logParts["message"], Equals, "01234567#0129")
+
+	c.Check(truncateText("\xe9\xe9\xe9\xe9\xe9\xe9", 4), Equals, "\xe9\xe9\xe9\xe9")
+	c.Check(truncateText("éé", 1), Equals, "")
+}
@@ -2,26 +2,32 @@ package syslog
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/tls"
 	"errors"
+	"io"
 	"net"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 
 	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/queue"
 )
 
 var (
 	RFC3164   = &format.RFC3164{}   // RFC3164: http://www.ietf.org/rfc/rfc3164.txt
 	RFC5424   = &format.RFC5424{}   // RFC5424: http://www.ietf.org/rfc/rfc5424.txt
-	RFC6587   = &format.RFC6587{}   // RFC6587: http://www.ietf.org/rfc/rfc6587.txt - octet counting variant
+	RFC6587   = &format.RFC6587{}   // RFC6587: http://www.ietf.org/rfc/rfc6587.txt - octet counting and non-transparent framing
 	Automatic = &format.Automatic{} // Automatically identify the format
+	Cisco     = &format.Cisco{}     // Cisco IOS, NX-OS and ASA messages
 )
 
 const (
 	datagramChannelBufferSize     = 10
 	datagramReadBufferSizeDefault = 64 * 1024
+	datagramMaxSizeDefault        = 65536
+	datagramMaxSizeMin            = 2048
 )
 
 // A function type which gets the TLS peer name from the connection. Can return
@@ -31,6 +37,7 @@ type TlsPeerNameFunc func(tlsConn *tls.Conn) (tlsPeer string, ok bool)
 type Server struct {
 	listeners               []net.Listener
 	connections             []net.PacketConn
+	datagramListeners       []datagramListener // of the connections
 	wait                    sync.WaitGroup
 	done                    chan struct{}
 	datagramChannel         chan DatagramMessage
@@ -42,6 +49,17 @@ type Server struct {
 	datagramPool            sync.Pool
 	datagramReadBufferSize  int
 	datagramChannelSize     int
+	hostnamePolicy          HostnamePolicy
+	hostnameCache           *hostnameCache
+	nameFields              bool
+	charsetPolicy           CharsetPolicy
+	charsetDecoders         []charsetNetwork // most specific networks first
+	controlEscaping         ControlEscaping
+	keepNewlines            bool
+	maxMessageLength        int
+	queue                   *queue.Queue
+	closeHandlerOnStop      bool
+	closeHandler            sync.Once
 }
 
 // NewServer returns a new Server
@@ -50,9 +68,11 @@ func NewServer() *Server {
 		tlsPeerNameFunc:        defaultTlsPeerName,
 		datagramReadBufferSize: datagramReadBufferSizeDefault,
 		datagramChannelSize:    datagramChannelBufferSize,
+		hostnamePolicy:         HostnameSourceIP,
+		hostnameCache:          newHostnameCache(net.DefaultResolver, hostnameCacheTTLDefault, hostnameLookupTimeoutDefault),
 		datagramPool: sync.Pool{
 			New: func() interface{} {
-				return make([]byte, 65536)
+				return make([]byte, datagramMaxSizeDefault)
 			},
 		},
 	}
@@ -88,6 +108,32 @@ func (s *Server) SetTlsPeerNameFunc(tlsPeerNameFunc TlsPeerNameFunc) {
 	s.tlsPeerNameFunc = tlsPeerNameFunc
 }
 
+// Sets the policy used to fill in the hostname of messages that do not carry
+// one, defaults to HostnameSourceIP
+func (s *Server) SetHostnamePolicy(policy HostnamePolicy) {
+	s.hostnamePolicy = policy
+}
+
+// Sets the resolver used by the HostnameReverseDNS policy. Lookups are cached
+// for ttl and abandoned after timeout, in which case the client IP is used
+func (s *Server) SetHostnameResolver(resolver Resolver, ttl time.Duration, timeout time.Duration) {
+	s.hostnameCache = newHostnameCache(resolver, ttl, timeout)
+}
+
+// Sets whether the "facility_name" and "severity_name" fields are added to the
+// messages, holding the keywords of their facility and severity, e.g. "auth"
+// and "err"
+func (s *Server) SetNameFields(enabled bool) {
+	s.nameFields = enabled
+}
+
+// Sets whether Wait closes the handler once the server stopped, if it
+// implements io.Closer, e.g. to close the channel of a MessageChannelHandler
+// or flush a BatchHandler. Defaults to false
+func (s *Server) SetCloseHandlerOnStop(close bool) {
+	s.closeHandlerOnStop = close
+}
+
 // Default TLS peer name function - returns the CN of the certificate
 func defaultTlsPeerName(tlsConn *tls.Conn) (tlsPeer string, ok bool) {
 	state := tlsConn.ConnectionState()
@@ -98,8 +144,43 @@ func defaultTlsPeerName(tlsConn *tls.Conn) (tlsPeer string, ok bool) {
 	return cn, true
 }
 
+// DatagramOptions are the options of a UDP or unixgram listener
+type DatagramOptions struct {
+	// Whether a datagram may carry several messages framed as the format
+	// splits them, all of them being handled. By default a datagram carries a
+	// single message, as required by RFC5426: of a datagram holding several
+	// octet counted frames, only the first one is handled, with the
+	// "extra_frames" field set to true
+	MultipleFrames bool
+	// The size of the largest datagram read, longer ones being truncated, and
+	// the "truncated" field added to their message, set to true. Defaults to
+	// 65536, at least 2048 as recommended by RFC5426
+	MaxSize int
+}
+
+// The datagram listener options with their defaults
+func (o DatagramOptions) withDefaults() DatagramOptions {
+	if o.MaxSize == 0 {
+		o.MaxSize = datagramMaxSizeDefault
+	} else if o.MaxSize < datagramMaxSizeMin {
+		o.MaxSize = datagramMaxSizeMin
+	}
+	return o
+}
+
+// A UDP or unixgram listener
+type datagramListener struct {
+	options DatagramOptions
+	pool    *sync.Pool // of buffers of the maximum size
+}
+
 // Configure the server for listen on an UDP addr
 func (s *Server) ListenUDP(addr string) error {
+	return s.ListenUDPWithOptions(addr, DatagramOptions{})
+}
+
+// Configure the server for listen on an UDP addr, with options
+func (s *Server) ListenUDPWithOptions(addr string, options DatagramOptions) error {
 	udpAddr, err := net.ResolveUDPAddr("udp", addr)
 	if err != nil {
 		return err
@@ -114,12 +195,17 @@ func (s *Server) ListenUDP(addr string) error {
 		return err
 	}
 
-	s.connections = append(s.connections, connection)
+	s.addDatagramListener(connection, options)
 	return nil
 }
 
 // Configure the server for listen on an unix socket
 func (s *Server) ListenUnixgram(addr string) error {
+	return s.ListenUnixgramWithOptions(addr, DatagramOptions{})
+}
+
+// Configure the server for listen on an unix socket, with options
+func (s *Server) ListenUnixgramWithOptions(addr string, options DatagramOptions) error {
 	unixAddr, err := net.ResolveUnixAddr("unixgram", addr)
 	if err != nil {
 		return err
@@ -134,10 +220,25 @@ func (s *Server) ListenUnixgram(addr string) error {
 		return err
 	}
 
-	s.connections = append(s.connections, connection)
+	s.addDatagramListener(connection, options)
 	return nil
 }
 
+func (s *Server) addDatagramListener(connection net.PacketConn, options DatagramOptions) {
+	options = options.withDefaults()
+	pool := &s.datagramPool
+	if options.MaxSize != datagramMaxSizeDefault {
+		size := options.MaxSize
+		pool = &sync.Pool{
+			New: func() interface{} {
+				return make([]byte, size)
+			},
+		}
+	}
+	s.connections = append(s.connections, connection)
+	s.datagramListeners = append(s.datagramListeners, datagramListener{options: options, pool: pool})
+}
+
 // Configure the server for listen on a TCP addr
 func (s *Server) ListenTCP(addr string) error {
 	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
@@ -177,6 +278,11 @@ func (s *Server) Boot() error {
 		return errors.New("please set a valid handler")
 	}
 
+	// Datagram only servers need it too, to stop parsing on Kill
+	if s.done == nil {
+		s.done = make(chan struct{})
+	}
+
 	for _, listener := range s.listeners {
 		s.goAcceptConnection(listener)
 	}
@@ -185,8 +291,12 @@ func (s *Server) Boot() error {
 		s.goParseDatagrams()
 	}
 
-	for _, connection := range s.connections {
-		s.goReceiveDatagrams(connection)
+	for i, connection := range s.connections {
+		s.goReceiveDatagrams(connection, s.datagramListeners[i])
+	}
+
+	if s.queue != nil {
+		s.goConsumeQueue()
 	}
 
 	return nil
@@ -216,17 +326,21 @@ func (s *Server) goAcceptConnection(listener net.Listener) {
 
 func (s *Server) goScanConnection(connection net.Conn) {
 	scanner := bufio.NewScanner(connection)
-	if sf := s.format.GetSplitFunc(); sf != nil {
+	rfc5425, isRFC5425 := connection.(*rfc5425Conn)
+	if isRFC5425 {
+		// Octet counted frames only, up to the maximum message size
+		connection = rfc5425.Conn
+		scanner.Buffer(make([]byte, 4096), rfc5425.maxMessageSize+len(strconv.Itoa(rfc5425.maxMessageSize))+1)
+		scanner.Split(format.RFC5425SplitFunc(rfc5425.maxMessageSize))
+	} else if sf := s.format.GetSplitFunc(); sf != nil {
 		scanner.Split(sf)
 	}
 
-	remoteAddr := connection.RemoteAddr()
-	var client string
-	if remoteAddr != nil {
-		client = remoteAddr.String()
+	var conn connectionInfo
+	if remoteAddr := connection.RemoteAddr(); remoteAddr != nil {
+		conn.client = remoteAddr.String()
 	}
 
-	tlsPeer := ""
 	if tlsConn, ok := connection.(*tls.Conn); ok {
 		// Handshake now so we get the TLS peer information
 		if err := tlsConn.Handshake(); err != nil {
@@ -235,22 +349,27 @@ func (s *Server) goScanConnection(connection net.Conn) {
 		}
 		if s.tlsPeerNameFunc != nil {
 			var ok bool
-			tlsPeer, ok = s.tlsPeerNameFunc(tlsConn)
+			conn.tlsPeer, ok = s.tlsPeerNameFunc(tlsConn)
 			if !ok {
 				connection.Close()
 				return
 			}
 		}
+		if isRFC5425 {
+			state := tlsConn.ConnectionState()
+			conn.tlsVersion = tlsVersionName(state.Version)
+			conn.tlsCipherSuite = tls.CipherSuiteName(state.CipherSuite)
+		}
 	}
 
 	var scanCloser *ScanCloser
 	scanCloser = &ScanCloser{scanner, connection}
 
 	s.wait.Add(1)
-	go s.scan(scanCloser, client, tlsPeer)
+	go s.scan(scanCloser, conn)
 }
 
-func (s *Server) scan(scanCloser *ScanCloser, client string, tlsPeer string) {
+func (s *Server) scan(scanCloser *ScanCloser, conn connectionInfo) {
 loop:
 	for {
 		select {
@@ -261,18 +380,108 @@ loop:
 		if s.readTimeoutMilliseconds > 0 {
 			_ = scanCloser.closer.SetReadDeadline(time.Now().Add(time.Duration(s.readTimeoutMilliseconds) * time.Millisecond))
 		}
-		if scanCloser.Scan() {
-			s.parser([]byte(scanCloser.Text()), client, tlsPeer)
-		} else {
+		if !scanCloser.Scan() {
 			break loop
 		}
+		if s.queue != nil {
+			s.enqueue(0, scanCloser.Bytes(), conn)
+		} else {
+			s.parse([]byte(scanCloser.Text()), conn, false, false)
+		}
 	}
 	scanCloser.closer.Close()
 
 	s.wait.Done()
 }
 
+// Parses the messages of a datagram
+func (s *Server) parseDatagram(msg DatagramMessage) {
+	conn := connectionInfo{client: msg.client}
+	for _, frame := range splitDatagram(s.format.GetSplitFunc(), msg.message, msg.frames, msg.truncated) {
+		s.parse(frame.Message, conn, frame.Truncated, frame.ExtraFrames)
+	}
+}
+
+// DatagramFrame is a message of a datagram, as split by SplitDatagram
+type DatagramFrame struct {
+	Message []byte
+	// Whether it was cut by the truncation of the datagram, the "truncated"
+	// field being added to it
+	Truncated bool
+	// Whether frames followed it in a datagram carrying a single message, the
+	// "extra_frames" field being added to it
+	ExtraFrames bool
+}
+
+// SplitDatagram splits a datagram into the messages the server handles when
+// receiving it on a listener with the given options, so that tools replaying
+// datagrams parse them the same way
+func SplitDatagram(f format.Format, datagram []byte, options DatagramOptions) []DatagramFrame {
+	options = options.withDefaults()
+	truncated := len(datagram) >= options.MaxSize
+	if truncated {
+		datagram = datagram[:options.MaxSize]
+	}
+	datagram = trimDatagram(datagram)
+	if len(datagram) == 0 {
+		return nil
+	}
+	return splitDatagram(f.GetSplitFunc(), datagram, options.MultipleFrames, truncated)
+}
+
+// Removes the trailing control characters and NULs of a datagram
+func trimDatagram(datagram []byte) []byte {
+	n := len(datagram)
+	for ; (n > 0) && (datagram[n-1] < 32); n-- {
+	}
+	return datagram[:n]
+}
+
+// Splits a datagram in its messages. A datagram carrying a single message is
+// only unframed when its frame spans all of it, as formats like RFC6587 have
+// octet counts, unless its first frame is octet counted: the other frames are
+// then left out, and the message flagged. A datagram carrying several
+// messages is split in all its frames
+func splitDatagram(sf bufio.SplitFunc, data []byte, multiple bool, truncated bool) []DatagramFrame {
+	if sf == nil {
+		return []DatagramFrame{{Message: data, Truncated: truncated}}
+	}
+
+	var frames []DatagramFrame
+	for len(data) > 0 {
+		advance, token, err := sf(data, true)
+		if err != nil || advance <= 0 || token == nil {
+			// Not framed, or a frame cut by the truncation
+			return append(frames, DatagramFrame{Message: data, Truncated: truncated})
+		}
+		if !multiple {
+			frame := DatagramFrame{Message: token, Truncated: truncated}
+			if advance < len(data) {
+				if bytes.HasPrefix(data, token) {
+					// Not octet counted, e.g. a multiline message
+					frame.Message = data
+				} else {
+					frame.Truncated = false
+					frame.ExtraFrames = true
+				}
+			}
+			return append(frames, frame)
+		}
+		data = data[advance:]
+		frames = append(frames, DatagramFrame{Message: token, Truncated: truncated && len(data) == 0})
+	}
+	return frames
+}
+
 func (s *Server) parser(line []byte, client string, tlsPeer string) {
+	s.parse(line, connectionInfo{client: client, tlsPeer: tlsPeer}, false, false)
+}
+
+// Parses a message and passes it on to the handler, flagged as truncated if
+// its datagram was, and as having extra frames if its datagram carried other
+// messages left out
+func (s *Server) parse(line []byte, conn connectionInfo, truncated bool, extraFrames bool) {
+	client := conn.client
 	parser := s.format.GetParser(line)
 	err := parser.Parse()
 	if err != nil {
@@ -280,15 +489,30 @@ func (s *Server) parser(line []byte, client string, tlsPeer string) {
 	}
 
 	logParts := parser.Dump()
+	if s.charsetPolicy != CharsetRaw {
+		s.decodeCharset(logParts, client)
+	}
+	if s.sanitizing() {
+		s.sanitize(logParts)
+	}
+	if truncated {
+		logParts["truncated"] = true
+	}
+	if extraFrames {
+		logParts["extra_frames"] = true
+	}
 	logParts["client"] = client
-	if logParts["hostname"] == "" && (s.format == RFC3164 || s.format == Automatic) {
-		if i := strings.Index(client, ":"); i > 1 {
-			logParts["hostname"] = client[:i]
-		} else {
-			logParts["hostname"] = client
-		}
+	if hostname, _ := logParts["hostname"].(string); hostname == "" || hostname == "-" {
+		logParts["hostname"] = s.fallbackHostname(client, conn.tlsPeer)
+	}
+	logParts["tls_peer"] = conn.tlsPeer
+	if conn.tlsVersion != "" {
+		logParts["tls_version"] = conn.tlsVersion
+		logParts["tls_cipher_suite"] = conn.tlsCipherSuite
+	}
+	if s.nameFields {
+		addNameFields(logParts)
 	}
-	logParts["tls_peer"] = tlsPeer
 
 	s.handler.Handle(logParts, int64(len(line)), err)
 }
@@ -320,9 +544,20 @@ func (s *Server) Kill() error {
 	return nil
 }
 
-// Waits until the server stops
+// Waits until the server stops, and closes the handler then if set to, see
+// SetCloseHandlerOnStop
 func (s *Server) Wait() {
 	s.wait.Wait()
+	if !s.closeHandlerOnStop {
+		return
+	}
+	s.closeHandler.Do(func() {
+		if closer, ok := s.handler.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				s.lastError = err
+			}
+		}
+	})
 }
 
 type TimeoutCloser interface {
@@ -335,32 +570,51 @@ type ScanCloser struct {
 	closer TimeoutCloser
 }
 
+// The connection a message was received from
+type connectionInfo struct {
+	client         string
+	tlsPeer        string
+	tlsVersion     string // of RFC5425 connections
+	tlsCipherSuite string
+}
+
 type DatagramMessage struct {
-	message []byte
-	client  string
+	message   []byte
+	client    string
+	frames    bool // whether it may carry several messages
+	truncated bool
+	pool      *sync.Pool // of its buffer
 }
 
-func (s *Server) goReceiveDatagrams(packetconn net.PacketConn) {
+func (s *Server) goReceiveDatagrams(packetconn net.PacketConn, listener datagramListener) {
 	s.wait.Add(1)
 	go func() {
 		defer s.wait.Done()
 		for {
-			buf := s.datagramPool.Get().([]byte)
+			buf := listener.pool.Get().([]byte)
 			n, addr, err := packetconn.ReadFrom(buf)
 			if err == nil {
+				// A datagram filling the buffer was likely cut
+				truncated := n == len(buf)
 				// Ignore trailing control characters and NULs
-				for ; (n > 0) && (buf[n-1] < 32); n-- {
-				}
-				if n > 0 {
+				if n = len(trimDatagram(buf[:n])); n > 0 {
 					var address string
 					if addr != nil {
 						address = addr.String()
 					}
+					msg := DatagramMessage{buf[:n], address, listener.options.MultipleFrames, truncated, listener.pool}
+					if s.queue != nil {
+						s.enqueueDatagram(msg)
+						listener.pool.Put(buf)
+						continue
+					}
 					select {
 					case <-s.done:
 						return
-					case s.datagramChannel <- DatagramMessage{buf[:n], address}:
+					case s.datagramChannel <- msg:
 					}
+				} else {
+					listener.pool.Put(buf)
 				}
 			} else {
 				// there has been an error. Either the server has been killed
@@ -390,14 +644,10 @@ func (s *Server) goParseDatagrams() {
 				if !ok {
 					return
 				}
-				if sf := s.format.GetSplitFunc(); sf != nil {
-					if _, token, err := sf(msg.message, true); err == nil {
-						s.parser(token, msg.client, "")
-					}
-				} else {
-					s.parser(msg.message, msg.client, "")
+				s.parseDatagram(msg)
+				if msg.pool != nil {
+					msg.pool.Put(msg.message[:cap(msg.message)])
 				}
-				s.datagramPool.Put(msg.message[:cap(msg.message)])
 			}
 		}
 	}()
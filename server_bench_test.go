@@ -82,7 +82,7 @@ func BenchmarkDatagramNoFormatting(b *testing.B) {
 	server.SetFormat(noopFormatter{})
 	server.SetHandler(handler)
 	reader, writer := io.Pipe()
-	server.goReceiveDatagrams(&fakePacketConn{PipeReader: reader})
+	server.goReceiveDatagrams(&fakePacketConn{PipeReader: reader}, datagramListener{pool: &server.datagramPool})
 	server.goParseDatagrams()
 	msg := []byte(exampleSyslog + "\n")
 	b.SetBytes(int64(len(msg)))
@@ -176,7 +176,7 @@ func (s *ServerSuite) TestUDP3164(c *C) {
 	server.SetHandler(handler)
 	server.SetTimeout(10)
 	server.goParseDatagrams()
-	server.datagramChannel <- DatagramMessage{[]byte(exampleSyslog), "0.0.0.0"}
+	server.datagramChannel <- DatagramMessage{message: []byte(exampleSyslog), client: "0.0.0.0"}
 	close(server.datagramChannel)
 	server.Wait()
 	c.Check(handler.LastLogParts["hostname"], Equals, "hostname")
@@ -193,7 +193,7 @@ func (s *ServerSuite) TestUDP3164NoTag(c *C) {
 	server.SetHandler(handler)
 	server.SetTimeout(10)
 	server.goParseDatagrams()
-	server.datagramChannel <- DatagramMessage{[]byte(exampleSyslogNoTSTagHost), "127.0.0.1:45789"}
+	server.datagramChannel <- DatagramMessage{message: []byte(exampleSyslogNoTSTagHost), client: "127.0.0.1:45789"}
 	close(server.datagramChannel)
 	server.Wait()
 	c.Check(handler.LastLogParts["hostname"], Equals, "127.0.0.1")
@@ -210,7 +210,7 @@ func (s *ServerSuite) TestUDPAutomatic3164NoPriority(c *C) {
 	server.SetHandler(handler)
 	server.SetTimeout(10)
 	server.goParseDatagrams()
-	server.datagramChannel <- DatagramMessage{[]byte(exampleSyslogNoPriority), "127.0.0.1:45789"}
+	server.datagramChannel <- DatagramMessage{message: []byte(exampleSyslogNoPriority), client: "127.0.0.1:45789"}
 	close(server.datagramChannel)
 	server.Wait()
 	c.Check(handler.LastLogParts["hostname"], Equals, "127.0.0.1")
@@ -229,7 +229,7 @@ func (s *ServerSuite) TestUDP6587(c *C) {
 	server.SetTimeout(10)
 	server.goParseDatagrams()
 	framedSyslog := []byte(fmt.Sprintf("%d %s", len(exampleRFC5424Syslog), exampleRFC5424Syslog))
-	server.datagramChannel <- DatagramMessage{[]byte(framedSyslog), "0.0.0.0"}
+	server.datagramChannel <- DatagramMessage{message: []byte(framedSyslog), client: "0.0.0.0"}
 	close(server.datagramChannel)
 	server.Wait()
 	c.Check(handler.LastLogParts["hostname"], Equals, "mymachine.example.com")
@@ -246,7 +246,7 @@ func (s *ServerSuite) TestUDPAutomatic3164(c *C) {
 	server.SetHandler(handler)
 	server.SetTimeout(10)
 	server.goParseDatagrams()
-	server.datagramChannel <- DatagramMessage{[]byte(exampleSyslog), "0.0.0.0"}
+	server.datagramChannel <- DatagramMessage{message: []byte(exampleSyslog), client: "0.0.0.0"}
 	close(server.datagramChannel)
 	server.Wait()
 	c.Check(handler.LastLogParts["hostname"], Equals, "hostname")
@@ -263,7 +263,7 @@ func (s *ServerSuite) TestUDPAutomatic5424(c *C) {
 	server.SetHandler(handler)
 	server.SetTimeout(10)
 	server.goParseDatagrams()
-	server.datagramChannel <- DatagramMessage{[]byte(exampleRFC5424Syslog), "0.0.0.0"}
+	server.datagramChannel <- DatagramMessage{message: []byte(exampleRFC5424Syslog), client: "0.0.0.0"}
 	close(server.datagramChannel)
 	server.Wait()
 	c.Check(handler.LastLogParts["hostname"], Equals, "mymachine.example.com")
@@ -281,7 +281,7 @@ func (s *ServerSuite) TestUDPAutomatic3164Plus6587OctetCount(c *C) {
 	server.SetTimeout(10)
 	server.goParseDatagrams()
 	framedSyslog := []byte(fmt.Sprintf("%d %s", len(exampleSyslog), exampleSyslog))
-	server.datagramChannel <- DatagramMessage{[]byte(framedSyslog), "0.0.0.0"}
+	server.datagramChannel <- DatagramMessage{message: []byte(framedSyslog), client: "0.0.0.0"}
 	close(server.datagramChannel)
 	server.Wait()
 	c.Check(handler.LastLogParts["hostname"], Equals, "hostname")
@@ -299,7 +299,7 @@ func (s *ServerSuite) TestUDPAutomatic5424Plus6587OctetCount(c *C) {
 	server.SetTimeout(10)
 	server.goParseDatagrams()
 	framedSyslog := []byte(fmt.Sprintf("%d %s", len(exampleRFC5424Syslog), exampleRFC5424Syslog))
-	server.datagramChannel <- DatagramMessage{[]byte(framedSyslog), "0.0.0.0"}
+	server.datagramChannel <- DatagramMessage{message: []byte(framedSyslog), client: "0.0.0.0"}
 	close(server.datagramChannel)
 	server.Wait()
 	c.Check(handler.LastLogParts["hostname"], Equals, "mymachine.example.com")
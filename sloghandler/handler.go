@@ -0,0 +1,263 @@
+//go:build go1.21
+// +build go1.21
+
+// Package sloghandler provides a log/slog handler sending the records as
+// RFC5424 syslog messages, e.g. to a go-syslog server.
+//
+// The attributes become the parameters of a structured data element whose
+// SD-ID is made of a name and a private enterprise number, e.g. attrs@32473.
+// Groups become elements of their own, their names being appended to the
+// SD-ID, e.g. attrs.request@32473. The source of the records, when enabled,
+// goes to the origin element, as file, line and function parameters.
+//
+// Over stream transports (tcp, unix, TLS), the messages are framed with octet
+// counting as in RFC6587. When the collector cannot be reached, the messages
+// are written to a fallback writer, os.Stderr by default
+package sloghandler
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+const (
+	// SDIDDefault is the default name of the SD-ID of the attributes
+	SDIDDefault = "attrs"
+	// EnterpriseNumberDefault is the default private enterprise number of the
+	// SD-ID of the attributes, the one reserved for documentation by RFC5612
+	EnterpriseNumberDefault = 32473
+)
+
+// Handler is a slog.Handler sending the records as RFC5424 messages
+type Handler struct {
+	sender *sender
+	opts   Options
+	sdID   string
+	attrs  []groupedAttr
+	groups []string
+}
+
+type groupedAttr struct {
+	groups []string
+	attr   slog.Attr
+}
+
+// Options of a Handler, the zero value being valid
+type Options struct {
+	// Minimum level of the records sent, slog.LevelInfo if nil
+	Level slog.Leveler
+	// Facility of the messages, syslog.User if syslog.Kern, the latter being
+	// reserved to the kernel
+	Facility syslog.Facility
+	// Hostname of the messages, os.Hostname() if empty
+	Hostname string
+	// App name of the messages, the name of the executable if empty
+	AppName string
+	// Name of the SD-ID of the attributes, SDIDDefault if empty
+	SDID string
+	// Private enterprise number of the SD-ID of the attributes,
+	// EnterpriseNumberDefault if 0
+	EnterpriseNumber int
+	// Adds the source of the records to the messages
+	AddSource bool
+
+	// TLS configuration, to send the messages over TLS with a stream network
+	TLSConfig *tls.Config
+	// Timeout of the connection to the collector and of the writes,
+	// DialTimeoutDefault if 0
+	DialTimeout time.Duration
+	// Delay before trying to connect again after a failure,
+	// RetryIntervalDefault if 0. The messages go to Fallback meanwhile
+	RetryInterval time.Duration
+	// Writer of the messages that could not be sent, os.Stderr if nil
+	Fallback io.Writer
+	// Sends the messages from a background goroutine, Handle never blocking
+	// on the network. When the queue is full, the messages go to Fallback
+	Async bool
+	// Size of the queue of the asynchronous mode, QueueSizeDefault if 0
+	QueueSize int
+}
+
+// New returns a new Handler sending the records to the collector at the given
+// address, over network, which can be "tcp", "tcp4", "tcp6", "udp", "udp4",
+// "udp6", "unix" or "unixgram". The connection is established on the first
+// record
+func New(network string, addr string, opts *Options) *Handler {
+	if opts == nil {
+		opts = &Options{}
+	}
+	h := &Handler{opts: *opts}
+
+	if h.opts.Facility == syslog.Kern {
+		h.opts.Facility = syslog.User
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelInfo
+	}
+	if h.opts.Hostname == "" {
+		h.opts.Hostname, _ = os.Hostname()
+	}
+	if h.opts.AppName == "" {
+		h.opts.AppName = filepath.Base(os.Args[0])
+	}
+	if h.opts.SDID == "" {
+		h.opts.SDID = SDIDDefault
+	}
+	if h.opts.EnterpriseNumber == 0 {
+		h.opts.EnterpriseNumber = EnterpriseNumberDefault
+	}
+	h.sdID = sdName(h.opts.SDID)
+
+	h.sender = newSender(network, addr, &h.opts)
+	return h
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = make([]groupedAttr, len(h.attrs), len(h.attrs)+len(attrs))
+	copy(h2.attrs, h.attrs)
+	for _, a := range attrs {
+		h2.attrs = append(h2.attrs, groupedAttr{h.groups, a})
+	}
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = make([]string, len(h.groups), len(h.groups)+1)
+	copy(h2.groups, h.groups)
+	h2.groups = append(h2.groups, name)
+	return &h2
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	msg, err := h.format(r)
+	if err != nil {
+		return err
+	}
+	return h.sender.send(msg)
+}
+
+// Close sends the queued messages in asynchronous mode, and closes the
+// connection. It is shared by all the handlers derived from this one
+func (h *Handler) Close() error {
+	return h.sender.close()
+}
+
+// Renders a record as an RFC5424 message, without trailer
+func (h *Handler) format(r slog.Record) ([]byte, error) {
+	sd := newStructuredData()
+	for _, ga := range h.attrs {
+		h.addAttr(sd, ga.groups, ga.attr)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		h.addAttr(sd, h.groups, a)
+		return true
+	})
+	if h.opts.AddSource && r.PC != 0 {
+		frames := runtime.CallersFrames([]uintptr{r.PC})
+		frame, _ := frames.Next()
+		sd.add("origin", "file", frame.File)
+		sd.add("origin", "line", strconv.Itoa(frame.Line))
+		sd.add("origin", "function", frame.Function)
+	}
+
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	logParts := format.LogParts{
+		"priority":        int(h.opts.Facility)*8 + int(severity(r.Level)),
+		"timestamp":       t,
+		"hostname":        h.opts.Hostname,
+		"app_name":        h.opts.AppName,
+		"proc_id":         strconv.Itoa(os.Getpid()),
+		"structured_data": sd.String(),
+		"message":         r.Message,
+	}
+
+	msg, err := syslog.TemplateRFC5424.Encode(logParts)
+	if err != nil {
+		return nil, err
+	}
+	// The template ends with a new line
+	return msg[:len(msg)-1], nil
+}
+
+// Adds an attribute to the element of its groups, the attributes of a group
+// going to the element of the group, unless it has no key
+func (h *Handler) addAttr(sd *structuredData, groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			groups = append(groups[:len(groups):len(groups)], a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			h.addAttr(sd, groups, ga)
+		}
+		return
+	}
+
+	sd.add(h.sdIDFor(groups), a.Key, attrValue(a.Value))
+}
+
+// Returns the SD-ID of the attributes of the given groups, the name being
+// truncated to fit in the 32 characters of an SD-ID
+func (h *Handler) sdIDFor(groups []string) string {
+	var b strings.Builder
+	b.WriteString(h.sdID)
+	for _, g := range groups {
+		b.WriteByte('.')
+		b.WriteString(sdName(g))
+	}
+	name := b.String()
+	suffix := "@" + strconv.Itoa(h.opts.EnterpriseNumber)
+	if max := sdNameMaxLength - len(suffix); len(name) > max {
+		name = name[:max]
+	}
+	return name + suffix
+}
+
+// Returns the severity of a level, the levels between the standard ones being
+// mapped to the severity of the lower one, except for notice between info and
+// warn, and critical above error
+func severity(level slog.Level) syslog.Severity {
+	switch {
+	case level >= slog.LevelError+4:
+		return syslog.Critical
+	case level >= slog.LevelError:
+		return syslog.Error
+	case level >= slog.LevelWarn:
+		return syslog.Warning
+	case level >= slog.LevelInfo+2:
+		return syslog.Notice
+	case level >= slog.LevelInfo:
+		return syslog.Informational
+	}
+	return syslog.Debug
+}
@@ -0,0 +1,199 @@
+//go:build go1.21
+// +build go1.21
+
+package sloghandler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/internal/syslogparser/rfc5424"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type HandlerSuite struct{}
+
+var _ = Suite(&HandlerSuite{})
+
+// Collects the messages received over TCP, framed with octet counting
+func listenTCP(c *C) (string, <-chan format.LogParts) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	messages := make(chan format.LogParts, 16)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		f := &format.RFC6587{}
+		scanner := bufio.NewScanner(conn)
+		scanner.Split(f.GetSplitFunc())
+		for scanner.Scan() {
+			messages <- parse(scanner.Bytes())
+		}
+		close(messages)
+	}()
+	return l.Addr().String(), messages
+}
+
+func parse(line []byte) format.LogParts {
+	p := (&format.RFC5424{}).GetParser(line)
+	if err := p.Parse(); err != nil {
+		return format.LogParts{"error": err}
+	}
+	return p.Dump()
+}
+
+func receive(c *C, messages <-chan format.LogParts) format.LogParts {
+	select {
+	case logParts := <-messages:
+		c.Assert(logParts["error"], IsNil)
+		return logParts
+	case <-time.After(5 * time.Second):
+		c.Fatal("no message received")
+	}
+	return nil
+}
+
+func sd(c *C, logParts format.LogParts) map[string]map[string]string {
+	elements, err := rfc5424.ParseStructuredDataElements(logParts["structured_data"].(string))
+	c.Assert(err, IsNil)
+	return elements
+}
+
+func (s *HandlerSuite) TestTCP(c *C) {
+	addr, messages := listenTCP(c)
+	h := New("tcp", addr, &Options{
+		Level:    slog.LevelDebug,
+		Facility: 16,
+		Hostname: "myhost",
+		AppName:  "myapp",
+	})
+	defer h.Close()
+	logger := slog.New(h)
+
+	logger.Info("hello world", "user", "alice", "count", 3)
+	logParts := receive(c, messages)
+	c.Check(logParts["priority"], Equals, 16*8+6)
+	c.Check(logParts["hostname"], Equals, "myhost")
+	c.Check(logParts["app_name"], Equals, "myapp")
+	c.Check(logParts["message"], Equals, "hello world")
+	c.Check(sd(c, logParts), DeepEquals, map[string]map[string]string{
+		"attrs@32473": {"user": "alice", "count": "3"},
+	})
+
+	logger.With("service", "api").WithGroup("request").Warn("slow", "path", `/a "b"]`,
+		slog.Group("client", "ip", "192.0.2.1"))
+	logParts = receive(c, messages)
+	c.Check(logParts["priority"], Equals, 16*8+4)
+	c.Check(sd(c, logParts), DeepEquals, map[string]map[string]string{
+		"attrs@32473":                {"service": "api"},
+		"attrs.request@32473":        {"path": `/a "b"]`},
+		"attrs.request.client@32473": {"ip": "192.0.2.1"},
+	})
+
+	logger.Debug("no attributes")
+	logParts = receive(c, messages)
+	c.Check(logParts["priority"], Equals, 16*8+7)
+	c.Check(logParts["structured_data"], Equals, "-")
+}
+
+func (s *HandlerSuite) TestUDP(c *C) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	h := New("udp", conn.LocalAddr().String(), &Options{
+		SDID:             "app",
+		EnterpriseNumber: 1234,
+		AddSource:        true,
+	})
+	defer h.Close()
+	slog.New(h).Error("failed", "err", "boom")
+
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	c.Assert(err, IsNil)
+	logParts := parse(buf[:n])
+	c.Assert(logParts["error"], IsNil)
+	c.Check(logParts["priority"], Equals, 8+3)
+
+	elements := sd(c, logParts)
+	c.Check(elements["app@1234"], DeepEquals, map[string]string{"err": "boom"})
+	c.Check(strings.HasSuffix(elements["origin"]["file"], "handler_test.go"), Equals, true)
+	c.Check(elements["origin"]["function"], Matches, ".*TestUDP")
+}
+
+func (s *HandlerSuite) TestFallback(c *C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	addr := l.Addr().String()
+	l.Close()
+
+	var fallback bytes.Buffer
+	h := New("tcp", addr, &Options{Fallback: &fallback, Hostname: "myhost"})
+	defer h.Close()
+	c.Check(h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "lost", 0)), IsNil)
+	c.Check(h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "lost again", 0)), IsNil)
+
+	lines := strings.Split(strings.TrimSuffix(fallback.String(), "\n"), "\n")
+	c.Assert(lines, HasLen, 2)
+	c.Check(lines[0], Matches, `<14>1 \S+ myhost \S+ \d+ - - lost`)
+	c.Check(lines[1], Matches, `<14>1 .* lost again`)
+}
+
+func (s *HandlerSuite) TestAsync(c *C) {
+	addr, messages := listenTCP(c)
+	h := New("tcp", addr, &Options{Async: true})
+	logger := slog.New(h)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			logger.Info("async", "i", i)
+		}(i)
+	}
+	wg.Wait()
+	c.Assert(h.Close(), IsNil)
+
+	received := 0
+	for logParts := range messages {
+		c.Check(logParts["message"], Equals, "async")
+		received++
+	}
+	c.Check(received, Equals, 4)
+}
+
+func (s *HandlerSuite) TestSDName(c *C) {
+	c.Check(sdName(`a b=c]d"e`), Equals, "a_b_c_d_e")
+	c.Check(sdName("é"), Equals, "__")
+	c.Check(sdName(""), Equals, "_")
+	c.Check(sdName(strings.Repeat("x", 40)), HasLen, 32)
+
+	h := New("udp", "127.0.0.1:0", &Options{})
+	c.Check(h.sdIDFor([]string{strings.Repeat("g", 40)}), Equals, "attrs."+strings.Repeat("g", 20)+"@32473")
+}
+
+func (s *HandlerSuite) TestSeverity(c *C) {
+	c.Check(int(severity(slog.LevelDebug)), Equals, 7)
+	c.Check(int(severity(slog.LevelInfo)), Equals, 6)
+	c.Check(int(severity(slog.LevelInfo+2)), Equals, 5)
+	c.Check(int(severity(slog.LevelWarn)), Equals, 4)
+	c.Check(int(severity(slog.LevelError)), Equals, 3)
+	c.Check(int(severity(slog.LevelError+4)), Equals, 2)
+}
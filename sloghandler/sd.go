@@ -0,0 +1,94 @@
+//go:build go1.21
+// +build go1.21
+
+package sloghandler
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const sdNameMaxLength = 32
+
+// Structured data elements, in order of appearance
+type structuredData struct {
+	ids    []string
+	params map[string][]sdParam
+}
+
+type sdParam struct {
+	name  string
+	value string
+}
+
+func newStructuredData() *structuredData {
+	return &structuredData{params: make(map[string][]sdParam)}
+}
+
+func (sd *structuredData) add(id string, name string, value string) {
+	if _, ok := sd.params[id]; !ok {
+		sd.ids = append(sd.ids, id)
+	}
+	sd.params[id] = append(sd.params[id], sdParam{sdName(name), value})
+}
+
+// String returns the structured data as in a message, "-" if empty
+func (sd *structuredData) String() string {
+	if len(sd.ids) == 0 {
+		return "-"
+	}
+
+	var b strings.Builder
+	for _, id := range sd.ids {
+		b.WriteByte('[')
+		b.WriteString(id)
+		for _, p := range sd.params[id] {
+			b.WriteByte(' ')
+			b.WriteString(p.name)
+			b.WriteString(`="`)
+			writeParamValue(&b, p.value)
+			b.WriteByte('"')
+		}
+		b.WriteByte(']')
+	}
+	return b.String()
+}
+
+// Writes a PARAM-VALUE, escaping '"', '\' and ']'
+func writeParamValue(b *strings.Builder, value string) {
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '"', '\\', ']':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// Returns name as a valid SD-NAME: up to 32 printable US-ASCII characters
+// except '=', ' ', ']' and '"', the others being replaced by '_'
+func sdName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	b := []byte(name)
+	if len(b) > sdNameMaxLength {
+		b = b[:sdNameMaxLength]
+	}
+	for i, c := range b {
+		if c < 33 || c > 126 || c == '=' || c == ']' || c == '"' {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func attrValue(v slog.Value) string {
+	if v.Kind() == slog.KindTime {
+		return v.Time().Format(time.RFC3339Nano)
+	}
+	return v.String()
+}
@@ -0,0 +1,178 @@
+//go:build go1.21
+// +build go1.21
+
+package sloghandler
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+const (
+	// DialTimeoutDefault is the default timeout of the connection to the
+	// collector, and of the writes
+	DialTimeoutDefault = 5 * time.Second
+	// RetryIntervalDefault is the default delay before trying to connect again
+	// after a failure
+	RetryIntervalDefault = 5 * time.Second
+	// QueueSizeDefault is the default size of the queue of the asynchronous
+	// mode
+	QueueSizeDefault = 1024
+)
+
+// Sends the messages to the collector, shared by the derived handlers
+type sender struct {
+	network string
+	addr    string
+	opts    *Options
+	stream  bool
+
+	mu          sync.Mutex
+	conn        net.Conn
+	lastFailure time.Time
+
+	queueMu sync.RWMutex
+	queue   chan []byte
+	closed  bool
+	done    chan struct{}
+
+	fallbackMu sync.Mutex
+}
+
+func newSender(network string, addr string, opts *Options) *sender {
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = DialTimeoutDefault
+	}
+	if opts.RetryInterval == 0 {
+		opts.RetryInterval = RetryIntervalDefault
+	}
+	if opts.Fallback == nil {
+		opts.Fallback = os.Stderr
+	}
+	if opts.QueueSize == 0 {
+		opts.QueueSize = QueueSizeDefault
+	}
+
+	s := &sender{
+		network: network,
+		addr:    addr,
+		opts:    opts,
+		stream:  !strings.HasPrefix(network, "udp") && network != "unixgram",
+	}
+	if opts.Async {
+		s.queue = make(chan []byte, opts.QueueSize)
+		s.done = make(chan struct{})
+		go s.run()
+	}
+	return s
+}
+
+func (s *sender) run() {
+	defer close(s.done)
+	for msg := range s.queue {
+		s.write(msg)
+	}
+}
+
+func (s *sender) send(msg []byte) error {
+	if s.queue == nil {
+		return s.write(msg)
+	}
+
+	s.queueMu.RLock()
+	defer s.queueMu.RUnlock()
+	if s.closed {
+		return s.fallback(msg)
+	}
+	select {
+	case s.queue <- msg:
+		return nil
+	default:
+		return s.fallback(msg)
+	}
+}
+
+// Writes a message to the connection, connecting again once if it failed, or
+// to the fallback writer
+func (s *sender) write(msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	framed := msg
+	if s.stream {
+		framed = format.AppendOctetCounted(nil, msg)
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if !s.lastFailure.IsZero() && time.Since(s.lastFailure) < s.opts.RetryInterval {
+				break
+			}
+			if err := s.dial(); err != nil {
+				s.lastFailure = time.Now()
+				break
+			}
+		}
+
+		s.conn.SetWriteDeadline(time.Now().Add(s.opts.DialTimeout))
+		if _, err := s.conn.Write(framed); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	return s.fallback(msg)
+}
+
+func (s *sender) dial() error {
+	dialer := &net.Dialer{Timeout: s.opts.DialTimeout}
+	var conn net.Conn
+	var err error
+	if s.opts.TLSConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, s.network, s.addr, s.opts.TLSConfig)
+	} else {
+		conn, err = dialer.Dial(s.network, s.addr)
+	}
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	s.lastFailure = time.Time{}
+	return nil
+}
+
+func (s *sender) fallback(msg []byte) error {
+	b := make([]byte, 0, len(msg)+1)
+	b = append(append(b, msg...), '\n')
+	s.fallbackMu.Lock()
+	defer s.fallbackMu.Unlock()
+	_, err := s.opts.Fallback.Write(b)
+	return err
+}
+
+func (s *sender) close() error {
+	if s.queue != nil {
+		s.queueMu.Lock()
+		if !s.closed {
+			s.closed = true
+			close(s.queue)
+		}
+		s.queueMu.Unlock()
+		<-s.done
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,250 @@
+package syslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+// Encoder serializes messages, e.g. to write them to files
+type Encoder interface {
+	Encode(logParts format.LogParts) ([]byte, error)
+}
+
+var (
+	// Template of an RFC3164 line
+	TemplateRFC3164 = MustParseTemplate("<{{priority}}>{{timestamp:rfc3164}} {{hostname|-}} {{tag,app_name|-}}: {{content,message}}\n")
+	// Template of an RFC5424 line
+	TemplateRFC5424 = MustParseTemplate("<{{priority}}>1 {{timestamp:rfc3339|-}} {{hostname|-}} {{app_name,tag|-}} {{proc_id|-}} {{msg_id|-}} {{structured_data|-}} {{message,content}}\n")
+	// Template of a JSON object holding all the fields, one per line
+	TemplateJSONLines = MustParseTemplate("{{*:json}}\n")
+)
+
+var ErrTemplateUnclosed = errors.New("template: unclosed {{")
+
+const timestampRFC5424 = "2006-01-02T15:04:05.999999Z07:00"
+
+var templateOptions = map[string]bool{
+	"lower":   true,
+	"upper":   true,
+	"json":    true,
+	"rfc3339": true,
+	"rfc3164": true,
+	"date":    true,
+	"year":    true,
+	"month":   true,
+	"day":     true,
+	"hour":    true,
+	"unix":    true,
+}
+
+// Template renders messages following a text with {{property}} placeholders,
+// a property being written as
+//
+//	{{field1,field2:option|default}}
+//
+// The value is the one of the first field set and not empty, or else the
+// default. The special field * stands for the whole message. The options are:
+//
+//	lower, upper    change the case of the value
+//	json            encode the value in JSON
+//	rfc3339         RFC3339 timestamp, with up to microseconds
+//	rfc3164         RFC3164 timestamp, e.g. "Jan  2 15:04:05"
+//	date            2006-01-02
+//	year, month,    single components of a timestamp, zero padded
+//	day, hour
+//	unix            seconds since the Unix epoch
+//
+// Timestamps are rendered as RFC3339 with nanoseconds when no option is given
+type Template struct {
+	text  string
+	parts []templatePart
+}
+
+type templatePart struct {
+	literal string
+	fields  []string
+	option  string
+	def     string
+}
+
+// ParseTemplate parses a template
+func ParseTemplate(text string) (*Template, error) {
+	t := &Template{text: text}
+	s := text
+	for len(s) > 0 {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			t.parts = append(t.parts, templatePart{literal: s})
+			break
+		}
+		if start > 0 {
+			t.parts = append(t.parts, templatePart{literal: s[:start]})
+		}
+		s = s[start+2:]
+
+		end := strings.Index(s, "}}")
+		if end < 0 {
+			return nil, ErrTemplateUnclosed
+		}
+		part, err := parseTemplateProperty(s[:end])
+		if err != nil {
+			return nil, err
+		}
+		t.parts = append(t.parts, part)
+		s = s[end+2:]
+	}
+	return t, nil
+}
+
+// MustParseTemplate is like ParseTemplate but panics on error
+func MustParseTemplate(text string) *Template {
+	t, err := ParseTemplate(text)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func parseTemplateProperty(property string) (templatePart, error) {
+	var part templatePart
+
+	if i := strings.IndexByte(property, '|'); i >= 0 {
+		part.def = property[i+1:]
+		property = property[:i]
+	}
+	if i := strings.IndexByte(property, ':'); i >= 0 {
+		part.option = strings.TrimSpace(property[i+1:])
+		property = property[:i]
+		if !templateOptions[part.option] {
+			return part, fmt.Errorf("template: unknown option %q", part.option)
+		}
+	}
+	for _, field := range strings.Split(property, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			part.fields = append(part.fields, field)
+		}
+	}
+	if len(part.fields) == 0 {
+		return part, fmt.Errorf("template: no field in {{%s}}", property)
+	}
+
+	return part, nil
+}
+
+func (t *Template) String() string {
+	return t.text
+}
+
+// Encode renders the template for a message
+func (t *Template) Encode(logParts format.LogParts) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.execute(&buf, logParts, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Renders the template for a message, passing the values through escape if
+// not nil
+func (t *Template) execute(buf *bytes.Buffer, logParts format.LogParts, escape func(string) string) error {
+	for _, part := range t.parts {
+		if part.fields == nil {
+			buf.WriteString(part.literal)
+			continue
+		}
+
+		value, err := part.render(logParts)
+		if err != nil {
+			return err
+		}
+		if escape != nil {
+			value = escape(value)
+		}
+		buf.WriteString(value)
+	}
+	return nil
+}
+
+func (part *templatePart) render(logParts format.LogParts) (string, error) {
+	var value interface{}
+	for _, field := range part.fields {
+		if field == "*" {
+			value = map[string]interface{}(logParts)
+			break
+		}
+		if v, ok := logParts[field]; ok && !isEmptyValue(v) {
+			value = v
+			break
+		}
+	}
+	if value == nil {
+		return part.def, nil
+	}
+
+	switch part.option {
+	case "json":
+		b, err := json.Marshal(value)
+		return string(b), err
+	case "lower":
+		return strings.ToLower(templateString(value)), nil
+	case "upper":
+		return strings.ToUpper(templateString(value)), nil
+	}
+
+	t, ok := value.(time.Time)
+	if !ok {
+		return templateString(value), nil
+	}
+	switch part.option {
+	case "rfc3339":
+		return t.Format(timestampRFC5424), nil
+	case "rfc3164":
+		return t.Format(time.Stamp), nil
+	case "date":
+		return t.Format("2006-01-02"), nil
+	case "year":
+		return t.Format("2006"), nil
+	case "month":
+		return t.Format("01"), nil
+	case "day":
+		return t.Format("02"), nil
+	case "hour":
+		return t.Format("15"), nil
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10), nil
+	}
+	return t.Format(time.RFC3339Nano), nil
+}
+
+func isEmptyValue(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case time.Time:
+		return v.IsZero()
+	}
+	return false
+}
+
+func templateString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
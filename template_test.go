@@ -0,0 +1,117 @@
+package syslog
+
+import (
+	"bytes"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/sleepinggenius2/go-syslog.v2/format"
+)
+
+type TemplateSuite struct{}
+
+var _ = Suite(&TemplateSuite{})
+
+var templateTimestamp = time.Date(2026, time.March, 4, 5, 6, 7, 123456789, time.UTC)
+
+func (s *TemplateSuite) TestPresets(c *C) {
+	rfc3164 := format.LogParts{
+		"priority":  34,
+		"timestamp": templateTimestamp,
+		"hostname":  "mymachine",
+		"tag":       "su",
+		"content":   "'su root' failed for lonvick on /dev/pts/8",
+	}
+	rfc5424 := format.LogParts{
+		"priority":        165,
+		"timestamp":       templateTimestamp,
+		"hostname":        "mymachine.example.com",
+		"app_name":        "evntslog",
+		"proc_id":         "",
+		"msg_id":          "ID47",
+		"structured_data": `[exampleSDID@32473 iut="3"]`,
+		"message":         "An application event log entry...",
+	}
+
+	fixtures := []struct {
+		template *Template
+		logParts format.LogParts
+		expected string
+	}{
+		{TemplateRFC3164, rfc3164, "<34>Mar  4 05:06:07 mymachine su: 'su root' failed for lonvick on /dev/pts/8\n"},
+		{TemplateRFC3164, rfc5424, "<165>Mar  4 05:06:07 mymachine.example.com evntslog: An application event log entry...\n"},
+		{TemplateRFC5424, rfc3164, "<34>1 2026-03-04T05:06:07.123456Z mymachine su - - - 'su root' failed for lonvick on /dev/pts/8\n"},
+		{TemplateRFC5424, rfc5424, "<165>1 2026-03-04T05:06:07.123456Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut=\"3\"] An application event log entry...\n"},
+		{TemplateJSONLines, format.LogParts{"priority": 34, "timestamp": templateTimestamp, "tag": "su"}, `{"priority":34,"tag":"su","timestamp":"2026-03-04T05:06:07.123456789Z"}` + "\n"},
+	}
+
+	for _, f := range fixtures {
+		obtained, err := f.template.Encode(f.logParts)
+		c.Assert(err, IsNil)
+		c.Check(string(obtained), Equals, f.expected)
+	}
+}
+
+func (s *TemplateSuite) TestOptions(c *C) {
+	logParts := format.LogParts{
+		"timestamp": templateTimestamp,
+		"hostname":  "MyMachine",
+		"severity":  3,
+		"empty":     "",
+	}
+
+	fixtures := []struct {
+		text     string
+		expected string
+	}{
+		{"{{hostname}}", "MyMachine"},
+		{"{{hostname:lower}}/{{hostname:upper}}", "mymachine/MYMACHINE"},
+		{"{{missing}}", ""},
+		{"{{missing|none}}", "none"},
+		{"{{empty,missing,hostname|none}}", "MyMachine"},
+		{"{{ missing , hostname }}", "MyMachine"},
+		{"{{severity}} {{severity:json}}", "3 3"},
+		{"{{hostname:json}}", `"MyMachine"`},
+		{"{{timestamp}}", "2026-03-04T05:06:07.123456789Z"},
+		{"{{timestamp:rfc3339}}", "2026-03-04T05:06:07.123456Z"},
+		{"{{timestamp:rfc3164}}", "Mar  4 05:06:07"},
+		{"{{timestamp:date}}", "2026-03-04"},
+		{"{{timestamp:year}}/{{timestamp:month}}/{{timestamp:day}}/{{timestamp:hour}}", "2026/03/04/05"},
+		{"{{timestamp:unix}}", "1772600767"},
+		{"{{hostname:date}}", "MyMachine"},
+		{"a {b} }}", "a {b} }}"},
+	}
+
+	for _, f := range fixtures {
+		t, err := ParseTemplate(f.text)
+		c.Assert(err, IsNil, Commentf("%s", f.text))
+		obtained, err := t.Encode(logParts)
+		c.Assert(err, IsNil)
+		c.Check(string(obtained), Equals, f.expected, Commentf("%s", f.text))
+		c.Check(t.String(), Equals, f.text)
+	}
+}
+
+func (s *TemplateSuite) TestParseErrors(c *C) {
+	_, err := ParseTemplate("{{hostname")
+	c.Check(err, Equals, ErrTemplateUnclosed)
+
+	_, err = ParseTemplate("{{hostname:nope}}")
+	c.Check(err, ErrorMatches, `template: unknown option "nope"`)
+
+	_, err = ParseTemplate("{{|default}}")
+	c.Check(err, ErrorMatches, `template: no field in {{}}`)
+
+	c.Check(func() { MustParseTemplate("{{") }, PanicMatches, `template: unclosed {{`)
+}
+
+func (s *TemplateSuite) TestPathSafe(c *C) {
+	t := MustParseTemplate("/var/log/{{hostname}}/{{app_name}}.log")
+	var buf bytes.Buffer
+	c.Assert(t.execute(&buf, format.LogParts{"hostname": "..", "app_name": "../../etc/passwd"}, pathSafe), IsNil)
+	c.Check(buf.String(), Equals, "/var/log/_/.._.._etc_passwd.log")
+
+	buf.Reset()
+	c.Assert(t.execute(&buf, format.LogParts{"hostname": "", "app_name": ""}, pathSafe), IsNil)
+	c.Check(buf.String(), Equals, "/var/log/_/_.log")
+}